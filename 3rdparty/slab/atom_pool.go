@@ -9,6 +9,7 @@ import (
 
 // AtomPool is a lock-free slab allocation memory pool.
 type AtomPool struct {
+	stats
 	pages   []atomPage
 	minSize int
 	maxSize int
@@ -20,7 +21,7 @@ type AtomPool struct {
 // factor is used to control growth of chunk size.
 // pageSize is the memory size of each slab class.
 func NewAtomPool(minSize, maxSize, factor, pageSize int) *AtomPool {
-	pool := &AtomPool{make([]atomPage, 0, 10), minSize, maxSize}
+	pool := &AtomPool{pages: make([]atomPage, 0, 10), minSize: minSize, maxSize: maxSize}
 	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
 		c := atomPage{
 			size:   chunkSize,
@@ -47,6 +48,7 @@ func NewAtomPool(minSize, maxSize, factor, pageSize int) *AtomPool {
 
 // Alloc try alloc a []byte from internal slab class if no free chunk in slab class Alloc will make one.
 func (pool *AtomPool) Alloc(size int) []byte {
+	pool.alloc()
 	if size <= pool.maxSize {
 		for i := 0; i < len(pool.pages); i++ {
 			if pool.pages[i].size >= size {
@@ -58,6 +60,7 @@ func (pool *AtomPool) Alloc(size int) []byte {
 			}
 		}
 	}
+	pool.miss()
 	return make([]byte, size)
 }
 
@@ -4,6 +4,7 @@ import "unsafe"
 
 // ChanPool is a chan based slab allocation memory pool.
 type ChanPool struct {
+	stats
 	pages   []chanPage
 	minSize int
 	maxSize int
@@ -15,7 +16,7 @@ type ChanPool struct {
 // factor is used to control growth of chunk size.
 // pageSize is the memory size of each slab class.
 func NewChanPool(minSize, maxSize, factor, pageSize int) *ChanPool {
-	pool := &ChanPool{make([]chanPage, 0, 10), minSize, maxSize}
+	pool := &ChanPool{pages: make([]chanPage, 0, 10), minSize: minSize, maxSize: maxSize}
 	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
 		c := chanPage{
 			size:   chunkSize,
@@ -39,6 +40,7 @@ func NewChanPool(minSize, maxSize, factor, pageSize int) *ChanPool {
 
 // Alloc try alloc a []byte from internal slab class if no free chunk in slab class Alloc will make one.
 func (pool *ChanPool) Alloc(size int) []byte {
+	pool.alloc()
 	if size <= pool.maxSize {
 		for i := 0; i < len(pool.pages); i++ {
 			if pool.pages[i].size >= size {
@@ -50,6 +52,7 @@ func (pool *ChanPool) Alloc(size int) []byte {
 			}
 		}
 	}
+	pool.miss()
 	return make([]byte, size)
 }
 
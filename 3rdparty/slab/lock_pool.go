@@ -8,6 +8,7 @@ import (
 
 // LockPool is a lock-free slab allocation memory pool.
 type LockPool struct {
+	stats
 	pages   []lockPage
 	minSize int
 	maxSize int
@@ -23,7 +24,7 @@ func NewLockPool(minSize, maxSize, factor, pageSize int) *LockPool {
 	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
 		n++
 	}
-	pool := &LockPool{make([]lockPage, n), minSize, maxSize}
+	pool := &LockPool{pages: make([]lockPage, n), minSize: minSize, maxSize: maxSize}
 	n = 0
 	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
 		c := &pool.pages[n]
@@ -49,6 +50,7 @@ func NewLockPool(minSize, maxSize, factor, pageSize int) *LockPool {
 
 // Alloc try alloc a []byte from internal slab class if no free chunk in slab class Alloc will make one.
 func (pool *LockPool) Alloc(size int) []byte {
+	pool.alloc()
 	if size <= pool.maxSize {
 		for i := 0; i < len(pool.pages); i++ {
 			if pool.pages[i].size >= size {
@@ -60,6 +62,7 @@ func (pool *LockPool) Alloc(size int) []byte {
 			}
 		}
 	}
+	pool.miss()
 	return make([]byte, size)
 }
 
@@ -1,11 +1,39 @@
 package slab
 
+import "sync/atomic"
+
 // Pool memory pool interface protocol
 type Pool interface {
 	Alloc(int) []byte
 	Free([]byte)
 }
 
+// Stats the allocation stats of a pool
+type Stats struct {
+	Allocs uint64 // total number of Alloc calls
+	Misses uint64 // number of Alloc calls which make new memory instead of reusing a free chunk
+}
+
+// StatsPool a Pool reporting its allocation stats
+type StatsPool interface {
+	Pool
+	Stats() Stats
+}
+
+// stats counts the allocations, it should be the first field of a pool to keep 64-bit alignment
+type stats struct {
+	allocs uint64
+	misses uint64
+}
+
+func (s *stats) alloc() { atomic.AddUint64(&s.allocs, 1) }
+func (s *stats) miss()  { atomic.AddUint64(&s.misses, 1) }
+
+// Stats get the allocation stats
+func (s *stats) Stats() Stats {
+	return Stats{Allocs: atomic.LoadUint64(&s.allocs), Misses: atomic.LoadUint64(&s.misses)}
+}
+
 // NoPool a non-pool memory allocator
 type NoPool struct{}
 
@@ -21,3 +49,7 @@ var _ Pool = (*NoPool)(nil)
 var _ Pool = (*ChanPool)(nil)
 var _ Pool = (*SyncPool)(nil)
 var _ Pool = (*AtomPool)(nil)
+var _ StatsPool = (*ChanPool)(nil)
+var _ StatsPool = (*SyncPool)(nil)
+var _ StatsPool = (*AtomPool)(nil)
+var _ StatsPool = (*LockPool)(nil)
@@ -4,6 +4,7 @@ import "sync"
 
 // SyncPool is a sync.Pool base slab allocation memory pool
 type SyncPool struct {
+	stats
 	pages     []sync.Pool
 	pagesSize []int
 	minSize   int
@@ -20,15 +21,17 @@ func NewSyncPool(minSize, maxSize, factor int) *SyncPool {
 		n++
 	}
 	pool := &SyncPool{
-		make([]sync.Pool, n),
-		make([]int, n),
-		minSize, maxSize,
+		pages:     make([]sync.Pool, n),
+		pagesSize: make([]int, n),
+		minSize:   minSize,
+		maxSize:   maxSize,
 	}
 	n = 0
 	for chunkSize := minSize; chunkSize <= maxSize; chunkSize *= factor {
 		pool.pagesSize[n] = chunkSize
 		pool.pages[n].New = func(size int) func() interface{} {
 			return func() interface{} {
+				pool.miss()
 				buf := make([]byte, size)
 				return &buf
 			}
@@ -42,6 +45,7 @@ func NewSyncPool(minSize, maxSize, factor int) *SyncPool {
 
 // Alloc try alloc a []byte from internal slab class if no free chunk in slab class Alloc will make one.
 func (pool *SyncPool) Alloc(size int) []byte {
+	pool.alloc()
 	if size <= pool.maxSize {
 		for i := 0; i < len(pool.pagesSize); i++ {
 			if pool.pagesSize[i] >= size {
@@ -50,6 +54,7 @@ func (pool *SyncPool) Alloc(size int) []byte {
 			}
 		}
 	}
+	pool.miss()
 	return make([]byte, size)
 }
 
@@ -7,52 +7,130 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/utils/config"
+	"github.com/overtalk/bgo/utils/net"
 )
 
 var (
-	// some info about git which should be set in `go build`
+	// some info about git which should be set in `go build`, they are collected into core.BuildInfo,
+	// eg: -ldflags "-X github.com/overtalk/bgo/app.version=v1.0.0 -X github.com/overtalk/bgo/app.commit=xxx"
 	commit  string
 	branch  string
 	version = "no-version"
 
 	showVersion bool
+	checkConfig bool
 	confPath    string
 )
 
+// restartTimeout the max time to wait for the child process to start serving in a graceful restart
+const restartTimeout = time.Minute
+
+// overrideFlag the repeatable flag to override module configs, eg: -c modules.mysql.password=xxx
+type overrideFlag []string
+
+func (f *overrideFlag) String() string { return fmt.Sprint(*f) }
+
+func (f *overrideFlag) Set(value string) error {
+	if err := configutil.SetOverride(value); err != nil {
+		return err
+	}
+	*f = append(*f, value)
+	return nil
+}
+
 func parseFlags() error {
-	flag.StringVar(&confPath, "p", "", "config dir path")
+	var overrides overrideFlag
+	flag.StringVar(&confPath, "p", "", "config file path, {xml|json|yaml|yml|toml}")
+	flag.Var(&overrides, "c", "override a module config, eg: -c modules.mysql.password=xxx, it takes precedence over env BGO_MODULES_MYSQL_PASSWORD")
 	flag.BoolVar(&showVersion, "v", false, "show version")
+	flag.BoolVar(&checkConfig, "check-config", false, "validate the configs of all modules and exit")
 	flag.Parse()
 	return nil
 }
 
 func printVersion() {
-	fmt.Printf("Version : %s \nBranch : %s \nCommitID : %s\n", version, branch, commit)
+	fmt.Println(core.GetBuildInfo())
 }
 
 func Start() {
 	if err := parseFlags(); err != nil {
 		log.Fatal(err)
 	}
+	core.SetBuildInfo(version, branch, commit)
 
 	if showVersion {
 		printVersion()
 		return
 	}
 
+	Run(core.GetCore())
+}
+
+// Run run the server with the module manager until SIGINT or SIGTERM,
+// SIGHUP reloads the configs and SIGUSR2 restarts the server gracefully.
+// the flags should be parsed before.
+func Run(mgr *core.ModuleManager) {
+	if checkConfig {
+		if err := mgr.CheckConfig(confPath); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("config is ok")
+		return
+	}
+
+	// all handled signals are registered before starting, otherwise a SIGHUP or SIGUSR2
+	// arriving during the startup kills the process by default. The signals are queued
+	// in the channels and handled after the startup finishes.
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	core.GetCore().SetNotifyChan(sigChan)
-	core.GetCore().SetConfigPath(confPath)
+	// reload the config on SIGHUP
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	// graceful restart on SIGUSR2
+	usr2Chan := make(chan os.Signal, 1)
+	notifyRestart(usr2Chan)
+	mgr.SetNotifyChan(sigChan)
+	mgr.SetConfigPath(confPath)
 
-	if err := core.GetCore().Start(); err != nil {
+	if err := mgr.Start(); err != nil {
 		log.Fatal(err)
 	}
-	defer core.GetCore().Stop()
+	// the parent process stops serving after a graceful restart
+	if err := netutil.NotifyReady(); err != nil {
+		log.Println(err)
+	}
+	defer func() {
+		if err := mgr.Stop(); err != nil {
+			log.Println(err)
+		}
+	}()
 
-	core.GetCore().Ticker()
+	mgr.Ticker()
 
-	<-sigChan
+	for {
+		select {
+		case <-hupChan:
+			if result, err := mgr.Reload(); err != nil {
+				log.Println(err)
+			} else if len(result.NeedRestart) > 0 {
+				log.Printf("modules need a restart to apply the new config : %v\n", result.NeedRestart)
+			}
+		case <-usr2Chan:
+			// the child process resumes the listeners, this process stops accepting
+			// and drains the active connections in Stop
+			process, err := netutil.Restart(restartTimeout)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			log.Printf("graceful restart, new process : %d\n", process.Pid)
+			return
+		case <-sigChan:
+			return
+		}
+	}
 }
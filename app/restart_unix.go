@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package app
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyRestart relay SIGUSR2 to c for the graceful restart
+func notifyRestart(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
@@ -0,0 +1,8 @@
+package app
+
+import (
+	"os"
+)
+
+// notifyRestart the graceful restart is not supported on windows
+func notifyRestart(c chan<- os.Signal) {}
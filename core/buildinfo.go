@@ -0,0 +1,168 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// defaultVersion the version of a build without `-ldflags`
+const defaultVersion = "no-version"
+
+// ModuleVersion the version of a module linked into the binary
+type ModuleVersion struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+}
+
+// BuildInfo the build metadata of the binary, the version, branch and commit
+// are set by the app with `-ldflags`, the others are collected at runtime
+type BuildInfo struct {
+	Version   string          `json:"version"`
+	Branch    string          `json:"branch,omitempty"`
+	Commit    string          `json:"commit,omitempty"`
+	GoVersion string          `json:"go_version"`
+	Path      string          `json:"path,omitempty"`
+	Main      *ModuleVersion  `json:"main,omitempty"`
+	Deps      []ModuleVersion `json:"deps,omitempty"`
+}
+
+var (
+	buildInfoLock sync.RWMutex
+	buildInfo     *BuildInfo
+)
+
+// SetBuildInfo set the version, branch and commit of the binary
+func SetBuildInfo(version, branch, commit string) {
+	info := newBuildInfo()
+	if len(version) > 0 {
+		info.Version = version
+	}
+	info.Branch, info.Commit = branch, commit
+
+	buildInfoLock.Lock()
+	buildInfo = info
+	buildInfoLock.Unlock()
+}
+
+// GetBuildInfo get the build metadata of the binary, don't modify it
+func GetBuildInfo() *BuildInfo {
+	buildInfoLock.RLock()
+	info := buildInfo
+	buildInfoLock.RUnlock()
+	if info != nil {
+		return info
+	}
+
+	buildInfoLock.Lock()
+	defer buildInfoLock.Unlock()
+	if buildInfo == nil {
+		buildInfo = newBuildInfo()
+	}
+	return buildInfo
+}
+
+func newBuildInfo() *BuildInfo {
+	info := &BuildInfo{Version: defaultVersion, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Path = bi.Path
+	info.Main = newModuleVersion(&bi.Main)
+	for _, dep := range bi.Deps {
+		info.Deps = append(info.Deps, *newModuleVersion(dep))
+	}
+	return info
+}
+
+func newModuleVersion(m *debug.Module) *ModuleVersion {
+	// the replacement is the module actually linked
+	if m.Replace != nil {
+		m = m.Replace
+	}
+	return &ModuleVersion{Path: m.Path, Version: m.Version, Sum: m.Sum}
+}
+
+// Brief get a copy without the dependencies, it's small enough to be sent in a handshake
+func (this *BuildInfo) Brief() *BuildInfo {
+	brief := *this
+	brief.Main, brief.Deps = nil, nil
+	return &brief
+}
+
+// GetDepVersion get the version of a linked module, empty if not found
+func (this *BuildInfo) GetDepVersion(path string) string {
+	if this.Main != nil && this.Main.Path == path {
+		return this.Main.Version
+	}
+	for _, dep := range this.Deps {
+		if dep.Path == path {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// Fields get the log fields of the build metadata
+func (this *BuildInfo) Fields() []zap.Field {
+	return []zap.Field{
+		zap.String("version", this.Version),
+		zap.String("branch", this.Branch),
+		zap.String("commit", this.Commit),
+		zap.String("go_version", this.GoVersion),
+	}
+}
+
+func (this *BuildInfo) String() string {
+	return fmt.Sprintf("Version : %s \nBranch : %s \nCommitID : %s\nGoVersion : %s",
+		this.Version, this.Branch, this.Commit, this.GoVersion)
+}
+
+// IsRelease check whether the version is set by `-ldflags`
+func (this *BuildInfo) IsRelease() bool {
+	return len(this.Version) > 0 && this.Version != defaultVersion
+}
+
+// MajorVersion get the major version, eg: "v1" of "v1.2.3", empty if it's not a release
+func (this *BuildInfo) MajorVersion() string {
+	if !this.IsRelease() {
+		return ""
+	}
+	version := this.Version
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if i := strings.IndexByte(version, '.'); i > 0 {
+		version = version[:i]
+	}
+	return version
+}
+
+// CheckMajorVersion check whether the remote build has the same major version as the local one,
+// a build without a release version is always compatible
+func CheckMajorVersion(remote *BuildInfo) error {
+	local := GetBuildInfo()
+	if !local.IsRelease() || !remote.IsRelease() {
+		return nil
+	}
+	if local.MajorVersion() != remote.MajorVersion() {
+		return fmt.Errorf("incompatible version %s, local version %s", remote.Version, local.Version)
+	}
+	return nil
+}
+
+// BuildInfoHandler the http handler responding the build metadata
+func BuildInfoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(GetBuildInfo())
+	})
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestBuildInfo(t *testing.T) {
+	SetBuildInfo("v1.2.3", "master", "abcdef")
+	defer SetBuildInfo("", "", "")
+
+	info := GetBuildInfo()
+	if info.Version != "v1.2.3" || info.Branch != "master" || info.Commit != "abcdef" {
+		t.Errorf("info = %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("go version = %s", info.GoVersion)
+	}
+	if brief := info.Brief(); brief.Deps != nil || brief.Main != nil || brief.Version != info.Version {
+		t.Errorf("brief = %+v", brief)
+	}
+}
+
+func TestCheckMajorVersion(t *testing.T) {
+	SetBuildInfo("v1.2.3", "", "")
+	defer SetBuildInfo("", "", "")
+
+	tests := []struct {
+		version string
+		ok      bool
+	}{
+		{"v1.0.0", true},
+		{"1.9", true},
+		{"v2.0.0", false},
+		{"", true},
+		{defaultVersion, true},
+	}
+	for _, test := range tests {
+		err := CheckMajorVersion(&BuildInfo{Version: test.version})
+		if (err == nil) != test.ok {
+			t.Errorf("%s : err = %v", test.version, err)
+		}
+	}
+}
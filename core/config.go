@@ -3,12 +3,39 @@ package core
 import "encoding/xml"
 
 type Config struct {
-	XMLName xml.Name `xml:"xml"`
+	XMLName xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
 	Modules struct {
-		ModuleConfBaseDir string `xml:"module_conf_base_dir,attr"`
-		Module            []struct {
-			Name string `xml:"name,attr"`
-			Conf string `xml:"conf,attr"`
-		} `xml:"module"`
-	} `xml:"modules"`
+		ModuleConfBaseDir string `xml:"module_conf_base_dir,attr" json:"module_conf_base_dir" yaml:"module_conf_base_dir" toml:"module_conf_base_dir" default:"."`
+		// InitTimeout default timeout of Init for all modules, eg: 30s, no timeout by default
+		InitTimeout string `xml:"init_timeout,attr" json:"init_timeout" yaml:"init_timeout" toml:"init_timeout"`
+		// PreTickerTimeout default timeout of PreTicker for all modules, no timeout by default
+		PreTickerTimeout string `xml:"pre_ticker_timeout,attr" json:"pre_ticker_timeout" yaml:"pre_ticker_timeout" toml:"pre_ticker_timeout"`
+		// PreShutTimeout default timeout of PreShut for all modules, 30s by default
+		PreShutTimeout string `xml:"pre_shut_timeout,attr" json:"pre_shut_timeout" yaml:"pre_shut_timeout" toml:"pre_shut_timeout"`
+		// ShutTimeout default timeout of Shut for all modules, 30s by default
+		ShutTimeout string `xml:"shut_timeout,attr" json:"shut_timeout" yaml:"shut_timeout" toml:"shut_timeout"`
+		// HealthTimeout timeout of each module health check, eg: 1s
+		HealthTimeout string `xml:"health_timeout,attr" json:"health_timeout" yaml:"health_timeout" toml:"health_timeout"`
+		// HealthCache duration to cache the readiness result, eg: 1s
+		HealthCache string `xml:"health_cache,attr" json:"health_cache" yaml:"health_cache" toml:"health_cache"`
+		Module      []struct {
+			Name string `xml:"name,attr" json:"name" yaml:"name" toml:"name" validate:"required"`
+			Conf string `xml:"conf,attr" json:"conf" yaml:"conf" toml:"conf"`
+			// Depends names of the modules it depends on, separated by commas
+			Depends string `xml:"depends,attr" json:"depends" yaml:"depends" toml:"depends"`
+			// timeout of each lifecycle phase for this module, eg: 5s, the default one is used if it's empty
+			InitTimeout      string `xml:"init_timeout,attr" json:"init_timeout" yaml:"init_timeout" toml:"init_timeout"`
+			PreTickerTimeout string `xml:"pre_ticker_timeout,attr" json:"pre_ticker_timeout" yaml:"pre_ticker_timeout" toml:"pre_ticker_timeout"`
+			PreShutTimeout   string `xml:"pre_shut_timeout,attr" json:"pre_shut_timeout" yaml:"pre_shut_timeout" toml:"pre_shut_timeout"`
+			ShutTimeout      string `xml:"shut_timeout,attr" json:"shut_timeout" yaml:"shut_timeout" toml:"shut_timeout"`
+			// Restart policy when the ticker panics or fails, {restart|stop|shutdown}
+			Restart string `xml:"restart,attr" json:"restart" yaml:"restart" toml:"restart"`
+			// Schedule mode of the ticker, {fixed-delay|fixed-rate}
+			Schedule string `xml:"schedule,attr" json:"schedule" yaml:"schedule" toml:"schedule"`
+			// Cron expression of the ticker, eg: "0 4 * * *"
+			Cron string `xml:"cron,attr" json:"cron" yaml:"cron" toml:"cron"`
+			// Jitter random delay added to each run of the ticker, eg: 100ms
+			Jitter string `xml:"jitter,attr" json:"jitter" yaml:"jitter" toml:"jitter"`
+		} `xml:"module" json:"module" yaml:"module" toml:"module"`
+	} `xml:"modules" json:"modules" yaml:"modules" toml:"modules"`
 }
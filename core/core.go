@@ -1,10 +1,12 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -12,28 +14,84 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/overtalk/bgo/pkg/log"
-	"github.com/overtalk/bgo/utils/xml"
+	"github.com/overtalk/bgo/utils/config"
 )
 
 var (
-	once          sync.Once
-	pluginManager *ModuleManager
+	once              sync.Once
+	pluginManagerLock sync.RWMutex
+	pluginManager     *ModuleManager
 )
 
 type ModuleManager struct {
 	notifyChan chan os.Signal
+	cfgLock    sync.RWMutex
+	reloadLock sync.Mutex
 	cfg        *Config
 	configPath string             // basic config path
+	moduleLock sync.RWMutex       // guards moduleList, modules and the dependencies of each module
 	moduleList map[string]IModule // module instances
+	modules    []IModule          // module instances sorted by dependencies
+
+	// default timeout of each lifecycle phase and the timeouts of each module
+	timeouts       map[string]time.Duration
+	moduleTimeouts map[string]map[string]time.Duration
+	// phase of the hook still executing for each module
+	hookLock sync.Mutex
+	hooks    map[string]string
+	// restart policy and schedule of each module ticker declared in config
+	policies  map[string]RestartPolicy
+	schedules map[string]Schedule
+	scheduler *Scheduler
+
+	// lifecycle state of each module
+	stateLock sync.RWMutex
+	states    map[string]ModuleState
+
+	// timeout of each health check and the duration to cache the readiness result
+	healthTimeout  time.Duration
+	healthCacheTTL time.Duration
+	health         healthCache
 }
 
+// NewModuleManager create a module manager without any module,
+// modules should be registered explicitly by RegisterModule or RegisterFromFactory.
+func NewModuleManager() *ModuleManager {
+	return &ModuleManager{
+		moduleList:     make(map[string]IModule),
+		timeouts:       copyTimeouts(defaultPhaseTimeouts),
+		moduleTimeouts: make(map[string]map[string]time.Duration),
+		hooks:          make(map[string]string),
+		policies:       make(map[string]RestartPolicy),
+		schedules:      make(map[string]Schedule),
+		scheduler:      NewScheduler(SystemClock),
+		states:         make(map[string]ModuleState),
+		healthTimeout:  defaultHealthTimeout,
+		healthCacheTTL: defaultHealthCacheTTL,
+	}
+}
+
+// GetCore get the default module manager, all modules with registered factories are registered into it.
+// It's kept for compatibility, NewModuleManager is preferred to create isolated managers.
 func GetCore() *ModuleManager {
 	once.Do(func() {
-		pluginManager = &ModuleManager{
-			moduleList: make(map[string]IModule),
+		mgr := NewModuleManager()
+		if err := mgr.RegisterFromFactory(); err != nil {
+			log.Fatal(err)
 		}
+
+		pluginManagerLock.Lock()
+		pluginManager = mgr
+		pluginManagerLock.Unlock()
 	})
 
+	return defaultManager()
+}
+
+// defaultManager get the default module manager, nil if it's not created yet
+func defaultManager() *ModuleManager {
+	pluginManagerLock.RLock()
+	defer pluginManagerLock.RUnlock()
 	return pluginManager
 }
 
@@ -45,37 +103,68 @@ func (this *ModuleManager) RegisterModule(moduleName string, module IModule) {
 
 	}
 
+	if m, ok := module.(iManagedModule); ok {
+		m.setManager(this)
+	}
+	this.moduleLock.Lock()
 	this.moduleList[moduleName] = module
+	this.moduleLock.Unlock()
+	this.setModuleState(moduleName, StateRegistered)
 }
 
 func (this *ModuleManager) DeregisterModule(name string) {
 	moduleToDel := this.FindModule(name)
 	if moduleToDel != nil {
-		if err := moduleToDel.PreShut(); err != nil {
+		this.scheduler.Cancel(name)
+
+		if err := this.runPhase(moduleToDel, PhasePreShut); err != nil {
 			logpkg.Error("preShut error", zap.Error(err), zap.String("module", name))
 		}
 
-		if err := moduleToDel.Shut(); err != nil {
+		if err := this.runPhase(moduleToDel, PhaseShut); err != nil {
 			logpkg.Error("shut error", zap.Error(err), zap.String("module", name))
 		}
 
+		this.moduleLock.Lock()
 		delete(this.moduleList, name)
+		modules := make([]IModule, 0, len(this.modules))
+		for _, module := range this.modules {
+			if module != moduleToDel {
+				modules = append(modules, module)
+			}
+		}
+		this.modules = modules
+		this.moduleLock.Unlock()
+		this.stateLock.Lock()
+		delete(this.states, name)
+		this.stateLock.Unlock()
 	}
 
 	logpkg.Debug("deregister module", zap.String("module", name))
 }
 
 func (this *ModuleManager) FindModule(name string) IModule {
+	this.moduleLock.RLock()
+	defer this.moduleLock.RUnlock()
 	return this.moduleList[name]
 }
 
+// dependOn declare the dependencies of a module under the module lock, as they are read by GetModuleInfos
+func (this *ModuleManager) dependOn(module IModule, names ...string) error {
+	this.moduleLock.Lock()
+	defer this.moduleLock.Unlock()
+	return dependOn(module, names...)
+}
+
 func (this *ModuleManager) Start() error {
+	logpkg.Info("starting", GetBuildInfo().Fields()...)
 	for moduleName, _ := range this.moduleList {
 		logpkg.Debug("register module", zap.String("module", moduleName))
 	}
 
 	funcMap := []func() error{
 		this.loadConfig,
+		this.sortModules,
 		this.init,
 		this.loadRelatedModules,
 		this.preTicker,
@@ -83,7 +172,7 @@ func (this *ModuleManager) Start() error {
 
 	for _, function := range funcMap {
 		if err := function(); err != nil {
-			return err
+			return this.rollback(err)
 		}
 	}
 
@@ -91,49 +180,226 @@ func (this *ModuleManager) Start() error {
 }
 
 func (this *ModuleManager) Ticker() {
-	for _, module := range this.moduleList {
-		go func(notifyChan chan os.Signal, module IModule) {
-			for {
-				duration, flag := module.Ticker()
-				switch flag {
-				case Continue:
-					time.Sleep(duration)
-				case Stop:
-					logpkg.Debug("module stop ticker", zap.String("module", module.GetName()))
-					return
-				default:
-					logpkg.Debug("module shutdown server", zap.String("module", module.GetName()))
-					notifyChan <- syscall.SIGINT
-				}
-			}
-		}(this.notifyChan, module)
+	for _, module := range this.modules {
+		this.scheduler.Schedule(module.GetName(), this.getSchedule(module), this.tickerJob(module))
 	}
 }
 
+// Shutdown notify the server to shut down
+func (this *ModuleManager) Shutdown() {
+	if this.notifyChan == nil {
+		return
+	}
+	select {
+	case this.notifyChan <- syscall.SIGINT:
+	default:
+		// a shutdown is pending
+	}
+}
+
+// Stop shut down all modules, every module is attempted in every phase
+// and all errors are returned together as Errors.
 func (this *ModuleManager) Stop() error {
-	funcMap := []func() error{
+	funcMap := []func() Errors{
 		this.preShut,
 		this.shut,
 	}
 
+	// cancel all tickers and wait the running ones before shutting down
+	this.scheduler.Stop()
+	this.cfgLock.RLock()
+	timeout := this.timeouts[PhasePreShut]
+	this.cfgLock.RUnlock()
+	if running := this.scheduler.WaitTimeout(timeout); len(running) > 0 {
+		logpkg.Error("tickers still running after the timeout", zap.Strings("modules", running), zap.Duration("timeout", timeout))
+	}
+
+	var errs Errors
 	for _, function := range funcMap {
-		if err := function(); err != nil {
-			fmt.Println(err)
+		errs = append(errs, function()...)
+	}
+
+	for _, e := range errs {
+		if timeoutErr, ok := e.Err.(*TimeoutError); ok {
+			logpkg.Error("module overran shutdown timeout", zap.String("module", e.Module), zap.String("phase", e.Phase), zap.Duration("timeout", timeoutErr.Timeout))
+		} else {
+			logpkg.Error("module shutdown error", zap.String("module", e.Module), zap.String("phase", e.Phase), zap.Error(e.Err))
 		}
 	}
 
-	return nil
+	return errs.errorOrNil()
 }
 
 func (this *ModuleManager) SetNotifyChan(notifyChan chan os.Signal) { this.notifyChan = notifyChan }
 
 func (this *ModuleManager) SetConfigPath(path string) {
 	this.configPath = path
-	cfg := &Config{}
-	if err := xmlutil.ParseXml(path, cfg); err != nil {
+	if err := this.parseConfig(path); err != nil {
 		logpkg.Fatal("load core config error", zap.Error(err), zap.Any("path", path))
 	}
+}
+
+// CheckConfig parse the basic config and the configs of all modules without starting any module,
+// all errors are returned together.
+func (this *ModuleManager) CheckConfig(path string) error {
+	this.configPath = path
+	if err := this.parseConfig(path); err != nil {
+		return err
+	}
+
+	var errs Errors
+	for _, module := range this.cfg.Modules.Module {
+		m := this.FindModule(module.Name)
+		if m == nil {
+			errs.add(module.Name, PhaseLoadConfig, errors.New("module not registered"))
+			continue
+		}
+		if err := m.LoadConfig(filepath.Join(this.cfg.Modules.ModuleConfBaseDir, module.Conf)); err != nil {
+			errs.add(module.Name, PhaseLoadConfig, err)
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// parseConfig parse the basic config and apply the settings of all modules
+func (this *ModuleManager) parseConfig(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	var (
+		timeouts       = copyTimeouts(defaultPhaseTimeouts)
+		moduleTimeouts = make(map[string]map[string]time.Duration)
+		policies       = make(map[string]RestartPolicy)
+		schedules      = make(map[string]Schedule)
+	)
+
+	defaults, err := parsePhaseTimeouts(map[string]string{
+		PhaseInit:      cfg.Modules.InitTimeout,
+		PhasePreTicker: cfg.Modules.PreTickerTimeout,
+		PhasePreShut:   cfg.Modules.PreShutTimeout,
+		PhaseShut:      cfg.Modules.ShutTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("parse modules timeout : %v", err)
+	}
+	for phase, timeout := range defaults {
+		timeouts[phase] = timeout
+	}
+	healthTimeout, healthCacheTTL := defaultHealthTimeout, defaultHealthCacheTTL
+	if len(cfg.Modules.HealthTimeout) > 0 {
+		timeout, err := time.ParseDuration(cfg.Modules.HealthTimeout)
+		if err != nil {
+			return fmt.Errorf("parse health timeout : %v", err)
+		}
+		healthTimeout = timeout
+	}
+	if len(cfg.Modules.HealthCache) > 0 {
+		ttl, err := time.ParseDuration(cfg.Modules.HealthCache)
+		if err != nil {
+			return fmt.Errorf("parse health cache : %v", err)
+		}
+		healthCacheTTL = ttl
+	}
+	for _, module := range cfg.Modules.Module {
+		// bind the config path to the module to apply its env & flag overrides
+		configutil.Bind(filepath.Join(cfg.Modules.ModuleConfBaseDir, module.Conf), module.Name)
+		moduleTimeout, err := parsePhaseTimeouts(map[string]string{
+			PhaseInit:      module.InitTimeout,
+			PhasePreTicker: module.PreTickerTimeout,
+			PhasePreShut:   module.PreShutTimeout,
+			PhaseShut:      module.ShutTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("parse timeout of module %s : %v", module.Name, err)
+		}
+		if len(moduleTimeout) > 0 {
+			moduleTimeouts[module.Name] = moduleTimeout
+		}
+		if len(module.Restart) > 0 {
+			policy, err := ParseRestartPolicy(module.Restart)
+			if err != nil {
+				return fmt.Errorf("parse restart policy of module %s : %v", module.Name, err)
+			}
+			policies[module.Name] = policy
+		}
+		if len(module.Schedule) > 0 || len(module.Cron) > 0 || len(module.Jitter) > 0 {
+			schedule, err := parseSchedule(module.Schedule, module.Cron, module.Jitter)
+			if err != nil {
+				return fmt.Errorf("parse schedule of module %s : %v", module.Name, err)
+			}
+			schedules[module.Name] = schedule
+		}
+	}
+
+	this.cfgLock.Lock()
 	this.cfg = cfg
+	this.timeouts = timeouts
+	this.moduleTimeouts = moduleTimeouts
+	this.policies = policies
+	this.schedules = schedules
+	this.healthTimeout = healthTimeout
+	this.healthCacheTTL = healthCacheTTL
+	this.cfgLock.Unlock()
+	return nil
+}
+
+// getSchedule get the schedule of a module ticker
+func (this *ModuleManager) getSchedule(module IModule) Schedule {
+	this.cfgLock.RLock()
+	schedule, ok := this.schedules[module.GetName()]
+	this.cfgLock.RUnlock()
+	if ok {
+		return schedule
+	}
+	if m, ok := module.(IScheduledModule); ok {
+		return m.Schedule()
+	}
+	return Schedule{Mode: FixedDelay}
+}
+
+// getTimeout get the timeout of a lifecycle phase for a module, 0 means no timeout
+func (this *ModuleManager) getTimeout(name, phase string) time.Duration {
+	this.cfgLock.RLock()
+	defer this.cfgLock.RUnlock()
+	if timeout, ok := this.moduleTimeouts[name][phase]; ok {
+		return timeout
+	}
+	return this.timeouts[phase]
+}
+
+// runPhase run a lifecycle hook of the module with the timeout of the phase,
+// the module is marked as executing the phase until the hook returns.
+func (this *ModuleManager) runPhase(module IModule, phase string) error {
+	name := module.GetName()
+	this.hookLock.Lock()
+	this.hooks[name] = phase
+	this.hookLock.Unlock()
+
+	return runPhase(module, phase, this.getTimeout(name, phase), func() {
+		this.hookLock.Lock()
+		if this.hooks[name] == phase {
+			delete(this.hooks, name)
+		}
+		this.hookLock.Unlock()
+	})
+}
+
+// runningHook get the phase of the hook still executing for the module
+func (this *ModuleManager) runningHook(name string) (string, bool) {
+	this.hookLock.Lock()
+	defer this.hookLock.Unlock()
+	phase, ok := this.hooks[name]
+	return phase, ok
+}
+
+func copyTimeouts(timeouts map[string]time.Duration) map[string]time.Duration {
+	ret := make(map[string]time.Duration, len(timeouts))
+	for phase, timeout := range timeouts {
+		ret[phase] = timeout
+	}
+	return ret
 }
 
 // ------------------- private func -------------------
@@ -146,36 +412,74 @@ func (this *ModuleManager) loadConfig() error {
 		}
 		if err := m.LoadConfig(path); err != nil {
 			logpkg.Error("load config", zap.String("module", module.Name), zap.Error(err))
-			return err
+			return &ModuleError{Module: module.Name, Phase: PhaseLoadConfig, Err: err}
+		}
+		var deps []string
+		for _, dep := range strings.Split(module.Depends, ",") {
+			if dep = strings.TrimSpace(dep); len(dep) > 0 {
+				deps = append(deps, dep)
+			}
+		}
+		if len(deps) > 0 {
+			if err := this.dependOn(m, deps...); err != nil {
+				logpkg.Error("load config", zap.String("module", module.Name), zap.Error(err))
+				return &ModuleError{Module: module.Name, Phase: PhaseLoadConfig, Err: err}
+			}
 		}
 	}
 
 	return nil
 }
 
+func (this *ModuleManager) sortModules() error {
+	if err := this.injectDependencies(); err != nil {
+		logpkg.Error("inject modules", zap.Error(err))
+		return err
+	}
+	modules, err := sortModules(this.moduleList)
+	if err != nil {
+		logpkg.Error("sort modules", zap.Error(err))
+		return err
+	}
+
+	this.moduleLock.Lock()
+	this.modules = modules
+	this.moduleLock.Unlock()
+	for _, module := range modules {
+		logpkg.Debug("module order", zap.String("module", module.GetName()), zap.Strings("dependencies", dependenciesOf(module)))
+	}
+	return nil
+}
+
 func (this *ModuleManager) init() error {
 	// initialize all modules
-	for _, module := range this.moduleList {
+	for _, module := range this.modules {
 		if module == nil {
 			continue
 		}
 
-		if err := module.Init(); err != nil {
-			return err
+		if err := this.runPhase(module, PhaseInit); err != nil {
+			this.setModuleState(module.GetName(), StateFailed)
+			return &ModuleError{Module: module.GetName(), Phase: PhaseInit, Err: err}
 		}
+		this.setModuleState(module.GetName(), StateInitialized)
 	}
 
 	return nil
 }
 
 func (this *ModuleManager) loadRelatedModules() error {
-	for _, module := range this.moduleList {
+	for _, module := range this.modules {
 		if module == nil {
 			continue
 		}
 
+		if err := this.inject(module); err != nil {
+			logpkg.Error("inject modules", zap.String("module", module.GetName()), zap.Error(err))
+			return &ModuleError{Module: module.GetName(), Phase: PhaseLoadRelatedModules, Err: err}
+		}
 		if err := module.LoadRelatedModules(); err != nil {
-			return err
+			return &ModuleError{Module: module.GetName(), Phase: PhaseLoadRelatedModules, Err: err}
 		}
 	}
 
@@ -183,44 +487,76 @@ func (this *ModuleManager) loadRelatedModules() error {
 }
 
 func (this *ModuleManager) preTicker() error {
-	for name, module := range this.moduleList {
+	for _, module := range this.modules {
 		if module == nil {
 			continue
 		}
 
-		if err := module.PreTicker(); err != nil {
-			logpkg.Error("pre-ticker error", zap.Any("module", name))
-			return err
+		if err := this.runPhase(module, PhasePreTicker); err != nil {
+			logpkg.Error("pre-ticker error", zap.Any("module", module.GetName()), zap.Error(err))
+			return &ModuleError{Module: module.GetName(), Phase: PhasePreTicker, Err: err}
 		}
+		this.setModuleState(module.GetName(), StateRunning)
 	}
 
 	return nil
 }
 
-func (this *ModuleManager) preShut() error {
-	for _, module := range this.moduleList {
+func (this *ModuleManager) preShut() Errors {
+	return this.shutModules(this.modules, PhasePreShut)
+}
+
+func (this *ModuleManager) shut() Errors {
+	return this.shutModules(this.modules, PhaseShut)
+}
+
+// shutModules run a shutdown phase for the modules in the reverse order of starting,
+// all modules are attempted even if some of them fail or overrun the timeout.
+// A module whose previous hook is still executing is marked failed and skipped.
+func (this *ModuleManager) shutModules(modules []IModule, phase string) Errors {
+	var errs Errors
+	for i := len(modules) - 1; i >= 0; i-- {
+		module := modules[i]
 		if module == nil {
 			continue
 		}
 
-		if err := module.PreShut(); err != nil {
-			return err
+		if running, ok := this.runningHook(module.GetName()); ok {
+			this.setModuleState(module.GetName(), StateFailed)
+			errs.add(module.GetName(), phase, &HookRunningError{Module: module.GetName(), Phase: running})
+			continue
+		}
+		if err := this.runPhase(module, phase); err != nil {
+			this.setModuleState(module.GetName(), StateFailed)
+			errs.add(module.GetName(), phase, err)
+			continue
+		}
+		if phase == PhaseShut && this.GetModuleState(module.GetName()) != StateFailed {
+			this.setModuleState(module.GetName(), StateStopped)
 		}
 	}
 
-	return nil
+	return errs
 }
 
-func (this *ModuleManager) shut() error {
-	for _, module := range this.moduleList {
-		if module == nil {
-			continue
-		}
+// rollback shut down the modules which have finished Init after a failed start
+func (this *ModuleManager) rollback(cause error) error {
+	var errs Errors
+	errs.add("", "start", cause)
 
-		if err := module.Shut(); err != nil {
-			return err
+	var started []IModule
+	for _, module := range this.modules {
+		switch this.GetModuleState(module.GetName()) {
+		case StateInitialized, StateRunning:
+			started = append(started, module)
 		}
 	}
 
-	return nil
+	if len(started) > 0 {
+		logpkg.Error("start failed, roll back started modules", zap.Error(cause), zap.Int("count", len(started)))
+		errs = append(errs, this.shutModules(started, PhasePreShut)...)
+		errs = append(errs, this.shutModules(started, PhaseShut)...)
+	}
+
+	return errs
 }
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type recordModule struct {
+	Module
+	records *[]string
+	failOn  string
+}
+
+func (m *recordModule) do(phase string) error {
+	*m.records = append(*m.records, m.GetName()+"."+phase)
+	if phase == m.failOn {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (m *recordModule) Init() error      { return m.do(PhaseInit) }
+func (m *recordModule) PreTicker() error { return m.do(PhasePreTicker) }
+func (m *recordModule) PreShut() error   { return m.do(PhasePreShut) }
+func (m *recordModule) Shut() error      { return m.do(PhaseShut) }
+
+func newTestManager() *ModuleManager {
+	mgr := NewModuleManager()
+	mgr.cfg = &Config{}
+	for _, phase := range []string{PhaseInit, PhasePreTicker, PhasePreShut, PhaseShut} {
+		mgr.timeouts[phase] = time.Second
+	}
+	return mgr
+}
+
+func TestStopAttemptsAllModules(t *testing.T) {
+	var records []string
+	mgr := newTestManager()
+	mgr.RegisterModule("a", &recordModule{records: &records, failOn: PhaseShut})
+	mgr.RegisterModule("b", &recordModule{records: &records, failOn: PhasePreShut})
+	mgr.RegisterModule("c", &recordModule{records: &records})
+	if err := mgr.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	records = nil
+	err := mgr.Stop()
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("unexpected error : %v", err)
+	}
+	if errs[0].Module != "b" || errs[0].Phase != PhasePreShut || errs[1].Module != "a" || errs[1].Phase != PhaseShut {
+		t.Errorf("unexpected errors : %v", errs)
+	}
+	if len(records) != 6 {
+		t.Errorf("all modules should be attempted in every phase : %v", records)
+	}
+}
+
+func TestStartRollback(t *testing.T) {
+	var records []string
+	mgr := newTestManager()
+	mgr.RegisterModule("a", &recordModule{records: &records})
+	mgr.RegisterModule("b", &recordModule{records: &records, failOn: PhaseInit})
+	mgr.RegisterModule("c", &recordModule{records: &records})
+
+	if err := mgr.Start(); err == nil {
+		t.Fatal("start should fail")
+	}
+
+	expected := []string{"a.init", "b.init", "a.preShut", "a.shut"}
+	if len(records) != len(expected) {
+		t.Fatalf("records = %v, want %v", records, expected)
+	}
+	for i := range expected {
+		if records[i] != expected[i] {
+			t.Fatalf("records = %v, want %v", records, expected)
+		}
+	}
+	if state := mgr.GetModuleState("a"); state != StateStopped {
+		t.Errorf("state of a = %v, want %v", state, StateStopped)
+	}
+	if state := mgr.GetModuleState("c"); state != StateRegistered {
+		t.Errorf("state of c = %v, want %v", state, StateRegistered)
+	}
+}
+
+func TestGetModuleInfosDuringStart(t *testing.T) {
+	var records []string
+	mgr := newTestManager()
+	mgr.RegisterModule("inject", &injectModule{})
+	mgr.RegisterModule("record", &recordModule{records: &records})
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				mgr.GetModuleInfos()
+			}
+		}
+	}()
+
+	err := mgr.Start()
+	close(stop)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos := mgr.GetModuleInfos()
+	if len(infos) != 2 || infos[0].Name != "record" || infos[1].Name != "inject" {
+		t.Fatalf("infos = %+v", infos)
+	}
+	if deps := infos[1].Dependencies; len(deps) != 1 || deps[0] != "record" {
+		t.Errorf("dependencies of inject = %v", deps)
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+// sortModules sorts the modules topologically by their dependencies,
+// a module is always placed after all the modules it depends on.
+// Modules without dependency relations are sorted by name to keep the order stable.
+func sortModules(modules map[string]IModule) ([]IModule, error) {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		ret   = make([]IModule, 0, len(modules))
+		state = make(map[string]int, len(modules))
+		path  []string
+		visit func(name string) error
+	)
+
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			// find the start of the cycle in current path
+			start := 0
+			for i, n := range path {
+				if n == name {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("module dependency cycle : %s", strings.Join(cycle, " -> "))
+		}
+
+		module := modules[name]
+		state[name] = visiting
+		path = append(path, name)
+
+		deps := append([]string{}, dependenciesOf(module)...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, ok := modules[dep]; !ok {
+				return fmt.Errorf("module %s depends on unregistered module %s", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+		ret = append(ret, module)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestModules(deps map[string][]string) map[string]IModule {
+	modules := make(map[string]IModule, len(deps))
+	for name, d := range deps {
+		module := new(Module)
+		module.SetName(name)
+		module.DependOn(d...)
+		modules[name] = module
+	}
+	return modules
+}
+
+func TestSortModules(t *testing.T) {
+	modules := newTestModules(map[string][]string{
+		"game":              {"modules.mysql", "internal.token"},
+		"internal.token":    {"internal.lrucache"},
+		"internal.lrucache": nil,
+		"modules.mysql":     nil,
+	})
+
+	sorted, err := sortModules(modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := make(map[string]int, len(sorted))
+	for i, module := range sorted {
+		index[module.GetName()] = i
+	}
+	for name, module := range modules {
+		for _, dep := range dependenciesOf(module) {
+			if index[dep] > index[name] {
+				t.Errorf("module %s should be started before %s", dep, name)
+			}
+		}
+	}
+}
+
+func TestSortModulesCycle(t *testing.T) {
+	modules := newTestModules(map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"a"},
+	})
+
+	_, err := sortModules(modules)
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("unexpected error : %v", err)
+	}
+}
+
+func TestSortModulesMissing(t *testing.T) {
+	modules := newTestModules(map[string][]string{
+		"internal.token": {"internal.lrucache"},
+	})
+
+	_, err := sortModules(modules)
+	if err == nil || !strings.Contains(err.Error(), "internal.lrucache") {
+		t.Errorf("unexpected error : %v", err)
+	}
+}
+
+// plainModule a module implementing IModule without embedding Module
+type plainModule struct{ name string }
+
+func (m *plainModule) LoadConfig(path string) error    { return nil }
+func (m *plainModule) Init() error                     { return nil }
+func (m *plainModule) LoadRelatedModules() error       { return nil }
+func (m *plainModule) PreTicker() error                { return nil }
+func (m *plainModule) Ticker() (time.Duration, Action) { return 0, Stop }
+func (m *plainModule) PreShut() error                  { return nil }
+func (m *plainModule) Shut() error                     { return nil }
+func (m *plainModule) GetName() string                 { return m.name }
+func (m *plainModule) SetName(name string)             { m.name = name }
+
+func TestSortModulesNotDependent(t *testing.T) {
+	modules := newTestModules(map[string][]string{"game": {"plain"}})
+	modules["plain"] = &plainModule{name: "plain"}
+
+	sorted, err := sortModules(modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sorted) != 2 || sorted[0].GetName() != "plain" {
+		t.Errorf("unexpected order : %v", sorted)
+	}
+	if err := dependOn(modules["plain"], "game"); err == nil {
+		t.Error("expect an error declaring the dependencies of a module not implementing IDependent")
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModuleError an error returned by a module in a lifecycle phase
+type ModuleError struct {
+	Module string
+	Phase  string
+	Err    error
+}
+
+func (e *ModuleError) Error() string {
+	if _, ok := e.Err.(*TimeoutError); ok {
+		return e.Err.Error()
+	}
+	if len(e.Module) == 0 {
+		return fmt.Sprintf("%s : %v", e.Phase, e.Err)
+	}
+	return fmt.Sprintf("module %s %s : %v", e.Module, e.Phase, e.Err)
+}
+
+// Cause get the underlying error
+func (e *ModuleError) Cause() error { return e.Err }
+
+// Errors collects the errors of all modules
+type Errors []*ModuleError
+
+func (errs Errors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add append an error if it's not nil
+func (errs *Errors) add(module, phase string, err error) {
+	if err == nil {
+		return
+	}
+	if e, ok := err.(*ModuleError); ok {
+		*errs = append(*errs, e)
+		return
+	}
+	if e, ok := err.(Errors); ok {
+		*errs = append(*errs, e...)
+		return
+	}
+	*errs = append(*errs, &ModuleError{Module: module, Phase: phase, Err: err})
+}
+
+// errorOrNil returns nil if there is no error
+func (errs Errors) errorOrNil() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
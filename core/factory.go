@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Factory creates a new instance of a module
+type Factory func() IModule
+
+var (
+	factoryLock sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// RegisterFactory registers the factory of a module, it's usually called in the init func of the module package.
+// If the default manager has been created, a new instance is registered into it as well.
+func RegisterFactory(name string, factory Factory) {
+	factoryLock.Lock()
+	if _, ok := factories[name]; ok {
+		factoryLock.Unlock()
+		log.Fatalf("repeated module factory : %s\n", name)
+	}
+	factories[name] = factory
+	factoryLock.Unlock()
+
+	if mgr := defaultManager(); mgr != nil {
+		mgr.RegisterModule(name, factory())
+	}
+}
+
+// GetFactory get the factory of a module
+func GetFactory(name string) (Factory, bool) {
+	factoryLock.RLock()
+	defer factoryLock.RUnlock()
+	factory, ok := factories[name]
+	return factory, ok
+}
+
+// GetFactoryNames get the names of all registered factories in order
+func GetFactoryNames() []string {
+	factoryLock.RLock()
+	defer factoryLock.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterFromFactory creates new instances of the modules by their factories and registers them,
+// all factories are used if no name is given.
+func (this *ModuleManager) RegisterFromFactory(names ...string) error {
+	if len(names) == 0 {
+		names = GetFactoryNames()
+	}
+
+	for _, name := range names {
+		factory, ok := GetFactory(name)
+		if !ok {
+			return fmt.Errorf("module factory %s is not registered", name)
+		}
+		this.RegisterModule(name, factory())
+	}
+	return nil
+}
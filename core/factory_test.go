@@ -0,0 +1,38 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestRegisterFromFactory(t *testing.T) {
+	var records []string
+	RegisterFactory("test.factory", func() IModule { return &recordModule{records: &records} })
+
+	mgr1 := newTestManager()
+	mgr2 := newTestManager()
+	if err := mgr1.RegisterFromFactory("test.factory"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr2.RegisterFromFactory("test.factory"); err != nil {
+		t.Fatal(err)
+	}
+
+	m1, m2 := mgr1.FindModule("test.factory"), mgr2.FindModule("test.factory")
+	if m1 == nil || m2 == nil || m1 == m2 {
+		t.Fatalf("expect two isolated instances, got %p and %p", m1, m2)
+	}
+	if m1.(*recordModule).GetManager() != mgr1 || m2.(*recordModule).GetManager() != mgr2 {
+		t.Errorf("wrong manager of the modules")
+	}
+
+	if err := mgr1.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if mgr1.GetModuleState("test.factory") != StateRunning || mgr2.GetModuleState("test.factory") != StateRegistered {
+		t.Errorf("states = %s, %s", mgr1.GetModuleState("test.factory"), mgr2.GetModuleState("test.factory"))
+	}
+
+	if err := mgr1.RegisterFromFactory("test.missing"); err == nil {
+		t.Error("expect an error for missing factory")
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthTimeout the default timeout of each health check
+	defaultHealthTimeout = time.Second
+	// defaultHealthCacheTTL the default duration to cache the readiness result
+	defaultHealthCacheTTL = time.Second
+)
+
+// IHealthChecker is an optional interface for modules to report whether they are healthy,
+// eg: whether the db can be pinged. The ctx is cancelled when the check timeout is reached.
+type IHealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// CheckResult the health result of a module
+type CheckResult struct {
+	Module   string        `json:"module"`
+	State    string        `json:"state"`
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// HealthReport the aggregated health result of all modules
+type HealthReport struct {
+	Healthy   bool          `json:"healthy"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Modules   []CheckResult `json:"modules"`
+}
+
+// healthCache caches the last readiness report
+type healthCache struct {
+	lock   sync.Mutex
+	report *HealthReport
+}
+
+// Liveness report whether the process is alive, which means no module has failed,
+// it doesn't call the health checks so it's cheap.
+func (this *ModuleManager) Liveness() *HealthReport {
+	report := &HealthReport{Healthy: true, CheckedAt: time.Now()}
+	for _, info := range this.GetModuleInfos() {
+		result := CheckResult{Module: info.Name, State: info.State, Healthy: info.State != StateFailed.String()}
+		if !result.Healthy {
+			result.Error = "module failed"
+			report.Healthy = false
+		}
+		report.Modules = append(report.Modules, result)
+	}
+	return report
+}
+
+// Readiness report whether the server is ready to serve, which means all modules are running
+// and their health checks pass. The checks run concurrently, each within the health timeout,
+// and the report is cached for a while to keep probes cheap.
+// As the cached report is shared by all callers, the checks are detached from the ctx of the
+// caller and bounded by the health timeout only, so a cancelled probe can't fail the report.
+func (this *ModuleManager) Readiness(_ context.Context) *HealthReport {
+	this.cfgLock.RLock()
+	timeout, ttl := this.healthTimeout, this.healthCacheTTL
+	this.cfgLock.RUnlock()
+
+	this.health.lock.Lock()
+	defer this.health.lock.Unlock()
+	if report := this.health.report; report != nil && time.Since(report.CheckedAt) < ttl {
+		return report
+	}
+
+	infos := this.GetModuleInfos()
+	report := &HealthReport{Healthy: true, CheckedAt: time.Now(), Modules: make([]CheckResult, len(infos))}
+
+	var wg sync.WaitGroup
+	for i, info := range infos {
+		result := &report.Modules[i]
+		result.Module, result.State, result.Healthy = info.Name, info.State, true
+		if info.State != StateRunning.String() {
+			result.Healthy, result.Error = false, "module is not running"
+			continue
+		}
+
+		checker, ok := this.FindModule(info.Name).(IHealthChecker)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			start := time.Now()
+			if err := runHealthCheck(context.Background(), name, checker, timeout); err != nil {
+				result.Healthy, result.Error = false, err.Error()
+			}
+			result.Duration = time.Since(start)
+		}(info.Name)
+	}
+	wg.Wait()
+
+	for _, result := range report.Modules {
+		if !result.Healthy {
+			report.Healthy = false
+			break
+		}
+	}
+	this.health.report = report
+	return report
+}
+
+// runHealthCheck run the health check of the module, it returns a *TimeoutError
+// without waiting for the check any more if it overruns the timeout.
+func runHealthCheck(ctx context.Context, name string, checker IHealthChecker, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- checker.HealthCheck(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return &TimeoutError{Module: name, Phase: PhaseHealthCheck, Timeout: timeout}
+	}
+}
+
+// LivenessHandler the http handler of the liveness probe, it responds 503 if not healthy
+func (this *ModuleManager) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, this.Liveness())
+	})
+}
+
+// ReadinessHandler the http handler of the readiness probe, it responds 503 if not ready
+func (this *ModuleManager) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, this.Readiness(r.Context()))
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report *HealthReport) {
+	code := http.StatusOK
+	if !report.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type healthModule struct {
+	Module
+	checks int32
+	err    error
+	block  bool
+}
+
+func (m *healthModule) HealthCheck(ctx context.Context) error {
+	atomic.AddInt32(&m.checks, 1)
+	if m.block {
+		<-ctx.Done()
+	}
+	return m.err
+}
+
+func TestReadiness(t *testing.T) {
+	mgr := newTestManager()
+	mgr.healthTimeout = 10 * time.Millisecond
+	mgr.healthCacheTTL = time.Hour
+	ok := &healthModule{}
+	bad := &healthModule{err: errors.New("ping failed")}
+	mgr.RegisterModule("ok", ok)
+	mgr.RegisterModule("bad", bad)
+	mgr.RegisterModule("slow", &healthModule{block: true})
+
+	// not ready before started
+	if report := mgr.Readiness(context.Background()); report.Healthy || atomic.LoadInt32(&ok.checks) != 0 {
+		t.Errorf("expect not ready before started, report %+v", report)
+	}
+	if report := mgr.Liveness(); !report.Healthy {
+		t.Errorf("expect alive, report %+v", report)
+	}
+
+	if err := mgr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	mgr.health.report = nil
+	report := mgr.Readiness(context.Background())
+	if report.Healthy {
+		t.Errorf("expect not ready")
+	}
+	for _, result := range report.Modules {
+		switch result.Module {
+		case "ok":
+			if !result.Healthy {
+				t.Errorf("ok : %+v", result)
+			}
+		case "bad":
+			if result.Healthy || result.Error != "ping failed" {
+				t.Errorf("bad : %+v", result)
+			}
+		case "slow":
+			if result.Healthy || result.Error == "" {
+				t.Errorf("slow : %+v", result)
+			}
+		}
+	}
+
+	// cached
+	mgr.Readiness(context.Background())
+	if atomic.LoadInt32(&ok.checks) != 1 {
+		t.Errorf("expect cached report, checks = %d", atomic.LoadInt32(&ok.checks))
+	}
+
+	// a cancelled caller doesn't fail the checks of the cached report
+	mgr.health.report = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, result := range mgr.Readiness(ctx).Modules {
+		if result.Module == "ok" && !result.Healthy {
+			t.Errorf("ok with a cancelled caller : %+v", result)
+		}
+	}
+	mgr.Readiness(context.Background())
+	if atomic.LoadInt32(&ok.checks) != 2 {
+		t.Errorf("expect cached report, checks = %d", atomic.LoadInt32(&ok.checks))
+	}
+
+	w := httptest.NewRecorder()
+	mgr.ReadinessHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("code = %d", w.Code)
+	}
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// the tag of the module fields to be injected, eg:
+//
+//	type CXXModule struct {
+//		core.Module
+//		Token itoken.ITokenModule       `module:"internal.token"`
+//		Cache ilrucache.ILrucacheModule `module:"internal.lrucache,optional"`
+//	}
+//
+// the tagged fields must be exported, they are filled before LoadRelatedModules is called,
+// required modules are implicit dependencies, optional ones are dependencies only if registered.
+const injectTag = "module"
+
+// injectField a module field to be injected
+type injectField struct {
+	name     string // the name of the module to inject
+	optional bool
+	field    reflect.StructField
+	value    reflect.Value
+}
+
+// injectFields get all fields tagged with module of the module
+func injectFields(module IModule) []*injectField {
+	value := reflect.ValueOf(module)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	value = value.Elem()
+
+	var fields []*injectField
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := field.Tag.Lookup(injectTag)
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		f := &injectField{name: strings.TrimSpace(parts[0]), field: field, value: value.Field(i)}
+		for _, opt := range parts[1:] {
+			if strings.TrimSpace(opt) == "optional" {
+				f.optional = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// resolve find the module to be injected into the field, nil means an optional module not registered
+func (this *ModuleManager) resolve(f *injectField) (IModule, error) {
+	if !f.value.CanSet() {
+		return nil, fmt.Errorf("field %s : unexported field can't be injected with module %s", f.field.Name, f.name)
+	}
+
+	dep := this.FindModule(f.name)
+	if dep == nil {
+		if f.optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("field %s : module %s is not registered", f.field.Name, f.name)
+	}
+
+	if t := reflect.TypeOf(dep); !t.AssignableTo(f.field.Type) {
+		return nil, fmt.Errorf("field %s : module %s (%s) does not implement %s", f.field.Name, f.name, t, f.field.Type)
+	}
+	return dep, nil
+}
+
+// injectDependencies check the modules to be injected and declare them as dependencies,
+// so that the errors are reported before any module is initialized
+func (this *ModuleManager) injectDependencies() error {
+	names := make([]string, 0, len(this.moduleList))
+	for name := range this.moduleList {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs Errors
+	for _, name := range names {
+		module := this.moduleList[name]
+		for _, f := range injectFields(module) {
+			dep, err := this.resolve(f)
+			if err != nil {
+				errs.add(name, PhaseLoadRelatedModules, err)
+				continue
+			}
+			if dep != nil {
+				if err := this.dependOn(module, f.name); err != nil {
+					errs.add(name, PhaseLoadRelatedModules, err)
+				}
+			}
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// inject fill the fields tagged with module
+func (this *ModuleManager) inject(module IModule) error {
+	for _, f := range injectFields(module) {
+		dep, err := this.resolve(f)
+		if err != nil {
+			return err
+		}
+		if dep == nil {
+			continue
+		}
+
+		f.value.Set(reflect.ValueOf(dep))
+	}
+	return nil
+}
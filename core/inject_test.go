@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+type iRecordModule interface {
+	IModule
+	do(phase string) error
+}
+
+type injectModule struct {
+	Module
+	Record   iRecordModule `module:"record"`
+	Optional iRecordModule `module:"missing,optional"`
+}
+
+func TestInject(t *testing.T) {
+	var records []string
+	mgr := newTestManager()
+	mgr.RegisterModule("inject", &injectModule{})
+	mgr.RegisterModule("record", &recordModule{records: &records})
+	if err := mgr.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := mgr.FindModule("inject").(*injectModule)
+	if m.Record == nil || m.Record.GetName() != "record" {
+		t.Errorf("record module is not injected")
+	}
+	if m.Optional != nil {
+		t.Errorf("optional module should be nil")
+	}
+	if deps := m.GetDependencies(); len(deps) != 1 || deps[0] != "record" {
+		t.Errorf("dependencies = %v", deps)
+	}
+	// the injected module is initialized first
+	if records[0] != "record."+PhaseInit {
+		t.Errorf("records = %v", records)
+	}
+}
+
+type badInjectModule struct {
+	Module
+	Record iRecordModule `module:"plain"`
+}
+
+type unexportedInjectModule struct {
+	Module
+	record iRecordModule `module:"record"`
+}
+
+func TestInjectError(t *testing.T) {
+	mgr := newTestManager()
+	mgr.RegisterModule("bad", &badInjectModule{})
+	mgr.RegisterModule("plain", &Module{})
+	err := mgr.Start()
+	if err == nil || !strings.Contains(err.Error(), "does not implement") {
+		t.Errorf("expect an interface error, got %v", err)
+	}
+
+	mgr = newTestManager()
+	mgr.RegisterModule("bad", &badInjectModule{})
+	err = mgr.Start()
+	if err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("expect a not registered error, got %v", err)
+	}
+
+	var records []string
+	mgr = newTestManager()
+	mgr.RegisterModule("unexported", &unexportedInjectModule{})
+	mgr.RegisterModule("record", &recordModule{records: &records})
+	err = mgr.Start()
+	if err == nil || !strings.Contains(err.Error(), "unexported field") {
+		t.Errorf("expect an unexported field error, got %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("no module should be initialized : %v", records)
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// lifecycle phases, a timeout can be configured for Init, PreTicker, PreShut and Shut
+const (
+	PhaseLoadConfig         = "loadConfig"
+	PhaseInit               = "init"
+	PhaseLoadRelatedModules = "loadRelatedModules"
+	PhasePreTicker          = "preTicker"
+	PhasePreShut            = "preShut"
+	PhaseShut               = "shut"
+	PhaseReload             = "reload"
+	PhaseHealthCheck        = "healthCheck"
+)
+
+// defaultPhaseTimeouts the default timeout of each lifecycle phase, a phase not listed has no timeout.
+// The startup phases are not bounded since a module may take long to warm up,
+// the shutdown phases are bounded so that a stuck module can't block the exit.
+var defaultPhaseTimeouts = map[string]time.Duration{
+	PhasePreShut: 30 * time.Second,
+	PhaseShut:    30 * time.Second,
+}
+
+// IContextModule is an optional interface for modules, the context passed to
+// each hook is cancelled when the phase timeout of the module is reached.
+// If a module implements it, these hooks are called instead of the plain ones.
+type IContextModule interface {
+	InitContext(ctx context.Context) error
+	PreTickerContext(ctx context.Context) error
+	PreShutContext(ctx context.Context) error
+	ShutContext(ctx context.Context) error
+}
+
+// TimeoutError is returned when a module overruns the timeout of a lifecycle phase
+type TimeoutError struct {
+	Module  string
+	Phase   string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("module %s overran phase %s (timeout %s)", e.Module, e.Phase, e.Timeout)
+}
+
+// IsTimeout check whether the error is caused by a phase timeout
+func IsTimeout(err error) bool {
+	_, ok := err.(*TimeoutError)
+	return ok
+}
+
+// phaseFunc get the hook of the module for the phase
+func phaseFunc(module IModule, phase string) func(ctx context.Context) error {
+	if m, ok := module.(IContextModule); ok {
+		switch phase {
+		case PhaseInit:
+			return m.InitContext
+		case PhasePreTicker:
+			return m.PreTickerContext
+		case PhasePreShut:
+			return m.PreShutContext
+		case PhaseShut:
+			return m.ShutContext
+		}
+	}
+
+	var f func() error
+	switch phase {
+	case PhaseInit:
+		f = module.Init
+	case PhasePreTicker:
+		f = module.PreTicker
+	case PhasePreShut:
+		f = module.PreShut
+	case PhaseShut:
+		f = module.Shut
+	default:
+		return func(context.Context) error { return fmt.Errorf("unknown phase %s", phase) }
+	}
+	return func(context.Context) error { return f() }
+}
+
+// HookRunningError is returned when a phase is skipped because a previous hook
+// of the module overran its timeout and is still executing
+type HookRunningError struct {
+	Module string
+	Phase  string // the phase of the hook still executing
+}
+
+func (e *HookRunningError) Error() string {
+	return fmt.Sprintf("module %s is still executing phase %s", e.Module, e.Phase)
+}
+
+// runPhase run a lifecycle hook of the module, it returns a *TimeoutError
+// without waiting for the hook any more if the hook overruns the timeout.
+// done is called when the hook returns if it's not nil, even after the timeout.
+func runPhase(module IModule, phase string, timeout time.Duration, done func()) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func(f func(ctx context.Context) error) {
+		err := f(ctx)
+		if done != nil {
+			done()
+		}
+		result <- err
+	}(phaseFunc(module, phase))
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return &TimeoutError{Module: module.GetName(), Phase: phase, Timeout: timeout}
+	}
+}
+
+// parsePhaseTimeouts parse the timeout of each phase, the phases with an empty value are skipped
+func parsePhaseTimeouts(values map[string]string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for phase, value := range values {
+		if len(value) == 0 {
+			continue
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("phase %s : %v", phase, err)
+		}
+		timeouts[phase] = timeout
+	}
+	return timeouts, nil
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type blockingModule struct {
+	Module
+	shutCtxErr chan error
+}
+
+func (m *blockingModule) InitContext(ctx context.Context) error      { return nil }
+func (m *blockingModule) PreTickerContext(ctx context.Context) error { return nil }
+func (m *blockingModule) PreShutContext(ctx context.Context) error   { return nil }
+func (m *blockingModule) ShutContext(ctx context.Context) error {
+	<-ctx.Done()
+	m.shutCtxErr <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestRunPhaseTimeout(t *testing.T) {
+	module := &blockingModule{shutCtxErr: make(chan error, 1)}
+	module.SetName("blocking")
+
+	if err := runPhase(module, PhaseInit, 10*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runPhase(module, PhaseShut, 10*time.Millisecond, nil)
+	if !IsTimeout(err) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if e := err.(*TimeoutError); e.Module != "blocking" || e.Phase != PhaseShut {
+		t.Errorf("unexpected timeout error : %v", e)
+	}
+	if err := <-module.shutCtxErr; err != context.DeadlineExceeded {
+		t.Errorf("context should be cancelled, got %v", err)
+	}
+}
+
+func TestRunPhasePlainModule(t *testing.T) {
+	module := new(Module)
+	module.SetName("plain")
+	for _, phase := range []string{PhaseInit, PhasePreTicker, PhasePreShut, PhaseShut} {
+		if err := runPhase(module, phase, 0, nil); err != nil {
+			t.Errorf("phase %s : %v", phase, err)
+		}
+	}
+}
+
+type stuckModule struct {
+	Module
+	release chan struct{}
+	shut    bool
+}
+
+func (m *stuckModule) PreTicker() error {
+	<-m.release
+	return nil
+}
+
+func (m *stuckModule) Shut() error {
+	m.shut = true
+	return nil
+}
+
+func TestDefaultPhaseTimeouts(t *testing.T) {
+	mgr := NewModuleManager()
+	if timeout := mgr.getTimeout("a", PhaseInit); timeout != 0 {
+		t.Errorf("init should have no timeout by default, got %s", timeout)
+	}
+	if timeout := mgr.getTimeout("a", PhasePreTicker); timeout != 0 {
+		t.Errorf("preTicker should have no timeout by default, got %s", timeout)
+	}
+	if timeout := mgr.getTimeout("a", PhaseShut); timeout != defaultPhaseTimeouts[PhaseShut] {
+		t.Errorf("shut timeout = %s, want %s", timeout, defaultPhaseTimeouts[PhaseShut])
+	}
+
+	mgr.moduleTimeouts["a"] = map[string]time.Duration{PhaseInit: time.Second}
+	if timeout := mgr.getTimeout("a", PhaseInit); timeout != time.Second {
+		t.Errorf("init timeout of a = %s, want %s", timeout, time.Second)
+	}
+	if timeout := mgr.getTimeout("b", PhaseInit); timeout != 0 {
+		t.Errorf("init timeout of b = %s, want no timeout", timeout)
+	}
+}
+
+func TestRollbackSkipsRunningHook(t *testing.T) {
+	module := &stuckModule{release: make(chan struct{})}
+	defer close(module.release)
+
+	mgr := newTestManager()
+	mgr.timeouts[PhasePreTicker] = 10 * time.Millisecond
+	mgr.RegisterModule("stuck", module)
+
+	err := mgr.Start()
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("unexpected error : %v", err)
+	}
+
+	var skipped bool
+	for _, e := range errs {
+		if e, ok := e.Err.(*HookRunningError); ok && e.Phase == PhasePreTicker {
+			skipped = true
+		}
+	}
+	if !skipped {
+		t.Errorf("rollback should skip the module still executing preTicker : %v", errs)
+	}
+	if module.shut {
+		t.Error("shut should not run while preTicker is still executing")
+	}
+	if state := mgr.GetModuleState("stuck"); state != StateFailed {
+		t.Errorf("state = %v, want %v", state, StateFailed)
+	}
+}
@@ -1,6 +1,10 @@
 package core
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Action uint8
 
@@ -23,7 +27,43 @@ type IModule interface {
 	SetName(name string)
 }
 
-type Module struct{ name string }
+// IDependent is implemented by the modules declaring their dependencies, eg: by embedding Module,
+// the modules not implementing it depend on nothing
+type IDependent interface {
+	// GetDependencies returns the names of the modules which should be
+	// started before this one and shut down after it
+	GetDependencies() []string
+	DependOn(names ...string)
+}
+
+// dependenciesOf get the dependencies of a module
+func dependenciesOf(module IModule) []string {
+	if dependent, ok := module.(IDependent); ok {
+		return dependent.GetDependencies()
+	}
+	return nil
+}
+
+// dependOn declare the dependencies of a module, it fails if the module doesn't implement IDependent
+func dependOn(module IModule, names ...string) error {
+	dependent, ok := module.(IDependent)
+	if !ok {
+		return fmt.Errorf("module %s does not implement IDependent to depend on %s", module.GetName(), strings.Join(names, ","))
+	}
+	dependent.DependOn(names...)
+	return nil
+}
+
+// iManagedModule is implemented by modules embedding Module to know the manager they are registered into
+type iManagedModule interface {
+	setManager(manager *ModuleManager)
+}
+
+type Module struct {
+	name         string
+	dependencies []string
+	manager      *ModuleManager
+}
 
 func (module *Module) LoadConfig(path string) error    { return nil }
 func (module *Module) Init() error                     { return nil }
@@ -34,3 +74,29 @@ func (module *Module) PreShut() error                  { return nil }
 func (module *Module) Shut() error                     { return nil }
 func (module *Module) GetName() string                 { return module.name }
 func (module *Module) SetName(name string)             { module.name = name }
+func (module *Module) GetDependencies() []string       { return module.dependencies }
+
+// GetManager get the manager the module is registered into,
+// modules should use it instead of GetCore to find other modules
+func (module *Module) GetManager() *ModuleManager { return module.manager }
+
+func (module *Module) setManager(manager *ModuleManager) { module.manager = manager }
+
+// DependOn declares the modules this module depends on, duplicated names are ignored
+func (module *Module) DependOn(names ...string) {
+	for _, name := range names {
+		if name == "" || name == module.name {
+			continue
+		}
+		existed := false
+		for _, dep := range module.dependencies {
+			if dep == name {
+				existed = true
+				break
+			}
+		}
+		if !existed {
+			module.dependencies = append(module.dependencies, name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/pkg/log"
+)
+
+// IReloadable is an optional interface for modules supporting to reload their config at runtime,
+// path is the module config path same as the one passed to LoadConfig.
+type IReloadable interface {
+	Reload(path string) error
+}
+
+// ReloadResult the result of a reload
+type ReloadResult struct {
+	Reloaded    []string `json:"reloaded"`     // modules which have applied the new config
+	NeedRestart []string `json:"need_restart"` // modules which need a restart to apply the new config
+}
+
+// Reload re-parse the basic config and deliver the new config to each module through its Reload hook,
+// modules not implementing IReloadable are reported as needing a restart.
+func (this *ModuleManager) Reload() (*ReloadResult, error) {
+	this.reloadLock.Lock()
+	defer this.reloadLock.Unlock()
+
+	if err := this.parseConfig(this.configPath); err != nil {
+		logpkg.Error("reload core config error", zap.Error(err), zap.String("path", this.configPath))
+		return nil, &ModuleError{Phase: PhaseReload, Err: err}
+	}
+
+	this.cfgLock.RLock()
+	cfg := this.cfg
+	this.cfgLock.RUnlock()
+
+	var (
+		errs   Errors
+		result = &ReloadResult{}
+	)
+	for _, module := range cfg.Modules.Module {
+		m := this.FindModule(module.Name)
+		if m == nil {
+			errs.add(module.Name, PhaseReload, errors.New("module not registered"))
+			continue
+		}
+
+		reloadable, ok := m.(IReloadable)
+		if !ok {
+			result.NeedRestart = append(result.NeedRestart, module.Name)
+			continue
+		}
+
+		path := filepath.Join(cfg.Modules.ModuleConfBaseDir, module.Conf)
+		if err := reloadable.Reload(path); err != nil {
+			logpkg.Error("reload module config error", zap.String("module", module.Name), zap.Error(err))
+			errs.add(module.Name, PhaseReload, err)
+			continue
+		}
+		result.Reloaded = append(result.Reloaded, module.Name)
+	}
+
+	logpkg.Info("reload config", zap.Strings("reloaded", result.Reloaded), zap.Strings("need_restart", result.NeedRestart))
+	return result, errs.errorOrNil()
+}
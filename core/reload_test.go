@@ -0,0 +1,58 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type reloadModule struct {
+	Module
+	paths []string
+}
+
+func (m *reloadModule) Reload(path string) error {
+	m.paths = append(m.paths, path)
+	return nil
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bgo-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.xml")
+	conf := `<xml>
+    <modules module_conf_base_dir="` + dir + `">
+        <module name="a" conf="a.xml" />
+        <module name="b" conf="b.xml" />
+    </modules>
+</xml>`
+	if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := newTestManager()
+	a := &reloadModule{}
+	var records []string
+	mgr.RegisterModule("a", a)
+	mgr.RegisterModule("b", &recordModule{records: &records})
+	mgr.SetConfigPath(path)
+
+	result, err := mgr.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Reloaded) != 1 || result.Reloaded[0] != "a" {
+		t.Errorf("reloaded = %v", result.Reloaded)
+	}
+	if len(result.NeedRestart) != 1 || result.NeedRestart[0] != "b" {
+		t.Errorf("need restart = %v", result.NeedRestart)
+	}
+	if len(a.paths) != 1 || a.paths[0] != filepath.Join(dir, "a.xml") {
+		t.Errorf("reload paths = %v", a.paths)
+	}
+}
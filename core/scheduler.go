@@ -0,0 +1,268 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the time for a Scheduler, it can be replaced in tests
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer a timer created by a Clock
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type systemClock struct{}
+type systemTimer struct{ *time.Timer }
+
+// SystemClock a Clock using the system time
+var SystemClock Clock = systemClock{}
+
+func (systemClock) Now() time.Time                 { return time.Now() }
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+func (t systemTimer) C() <-chan time.Time          { return t.Timer.C }
+
+// ScheduleMode how the next run time of a job is calculated
+type ScheduleMode uint8
+
+const (
+	// FixedDelay the next run starts after the delay since the end of the last run
+	FixedDelay ScheduleMode = iota
+	// FixedRate the next run starts after the delay since the start of the last run,
+	// so the period doesn't drift by the run time
+	FixedRate
+)
+
+// ParseScheduleMode parse a mode from {fixed-delay|fixed-rate}
+func ParseScheduleMode(s string) (ScheduleMode, error) {
+	switch s {
+	case "fixed-delay":
+		return FixedDelay, nil
+	case "fixed-rate":
+		return FixedRate, nil
+	}
+	return FixedDelay, fmt.Errorf("invalid schedule mode : %s", s)
+}
+
+// Schedule describes when a job runs
+type Schedule struct {
+	Mode ScheduleMode
+	// Cron if set, the job runs at the times matched by it and the delay returned by the job is ignored
+	Cron *CronExpr
+	// Jitter a random duration in [0, Jitter) added to each delay
+	Jitter time.Duration
+}
+
+// parseSchedule parse a schedule from the config attributes
+func parseSchedule(mode, cron, jitter string) (Schedule, error) {
+	var (
+		schedule Schedule
+		err      error
+	)
+	if len(mode) > 0 {
+		if schedule.Mode, err = ParseScheduleMode(mode); err != nil {
+			return schedule, err
+		}
+	}
+	if len(cron) > 0 {
+		if schedule.Cron, err = ParseCron(cron); err != nil {
+			return schedule, err
+		}
+	}
+	if len(jitter) > 0 {
+		if schedule.Jitter, err = time.ParseDuration(jitter); err != nil {
+			return schedule, err
+		}
+	}
+	return schedule, nil
+}
+
+// IScheduledModule is an optional interface for a module to declare how its ticker is scheduled,
+// the schedule in config takes precedence over it.
+type IScheduledModule interface {
+	Schedule() Schedule
+}
+
+// Job a scheduled job, it returns the delay before the next run and whether to go on
+type Job func() (time.Duration, Action)
+
+// retry is returned by a job to run again after the returned delay whatever the schedule is
+const retry Action = 0xFF
+
+// Scheduler runs jobs by their schedules, all jobs are cancelled immediately when it's stopped
+type Scheduler struct {
+	clock Clock
+
+	lock    sync.Mutex
+	jobs    map[string]chan struct{} // cancel chan of each job
+	running map[chan struct{}]string // name of each job whose goroutine hasn't exited
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewScheduler create a Scheduler with a clock
+func NewScheduler(clock Clock) *Scheduler {
+	return &Scheduler{
+		clock:    clock,
+		jobs:     make(map[string]chan struct{}),
+		running:  make(map[chan struct{}]string),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Schedule start a job, it runs until it returns Stop/Shutdown or is cancelled,
+// a job with the same name is cancelled before.
+// The first run starts immediately unless a cron expression is set.
+func (s *Scheduler) Schedule(name string, schedule Schedule, job Job) {
+	cancel := make(chan struct{})
+	s.lock.Lock()
+	if old, ok := s.jobs[name]; ok {
+		close(old)
+	}
+	s.jobs[name] = cancel
+	s.running[cancel] = name
+	s.lock.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.remove(name, cancel)
+		s.run(schedule, job, cancel)
+	}()
+}
+
+// Cancel cancel a job by its name
+func (s *Scheduler) Cancel(name string) {
+	s.lock.Lock()
+	if cancel, ok := s.jobs[name]; ok {
+		close(cancel)
+		delete(s.jobs, name)
+	}
+	s.lock.Unlock()
+}
+
+// IsScheduled check whether a job is still scheduled
+func (s *Scheduler) IsScheduled(name string) bool {
+	s.lock.Lock()
+	_, ok := s.jobs[name]
+	s.lock.Unlock()
+	return ok
+}
+
+// Stop cancel all jobs, a running job is not interrupted but won't run again
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+// Wait wait all job goroutines to exit
+func (s *Scheduler) Wait() { s.wg.Wait() }
+
+// WaitTimeout wait all job goroutines to exit within the timeout, 0 means no timeout.
+// It returns the names of the jobs still running when the timeout is reached.
+func (s *Scheduler) WaitTimeout(timeout time.Duration) []string {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	if timeout <= 0 {
+		<-done
+		return nil
+	}
+
+	timer := s.clock.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C():
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	names := make([]string, 0, len(s.running))
+	for _, name := range s.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Scheduler) remove(name string, cancel chan struct{}) {
+	s.lock.Lock()
+	if s.jobs[name] == cancel {
+		delete(s.jobs, name)
+	}
+	delete(s.running, cancel)
+	s.lock.Unlock()
+}
+
+// wait wait until the time or the job is cancelled, returns false if cancelled
+func (s *Scheduler) wait(d time.Duration, cancel chan struct{}) bool {
+	timer := s.clock.NewTimer(d)
+	select {
+	case <-timer.C():
+		return true
+	case <-cancel:
+	case <-s.stopChan:
+	}
+	timer.Stop()
+	return false
+}
+
+func (s *Scheduler) run(schedule Schedule, job Job, cancel chan struct{}) {
+	next := s.clock.Now()
+	if schedule.Cron != nil {
+		next = schedule.Cron.Next(next)
+	}
+
+	for {
+		if next.IsZero() {
+			// cron expression matches nothing
+			return
+		}
+
+		delay := next.Sub(s.clock.Now())
+		if schedule.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(schedule.Jitter)))
+		}
+		if !s.wait(delay, cancel) {
+			return
+		}
+
+		d, action := job()
+		switch action {
+		case Continue:
+		case retry:
+			next = s.clock.Now().Add(d)
+			continue
+		default:
+			return
+		}
+
+		switch {
+		case schedule.Cron != nil:
+			next = schedule.Cron.Next(s.clock.Now())
+		case schedule.Mode == FixedRate:
+			if now := s.clock.Now(); next.Add(d).Before(now) {
+				// the run overran some periods, skip them
+				next = now
+			} else {
+				next = next.Add(d)
+			}
+		default:
+			next = s.clock.Now().Add(d)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers chan *fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	delay   time.Duration
+	c       chan time.Time
+	stopped chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:    time.Date(2020, 7, 1, 3, 0, 0, 0, time.UTC),
+		timers: make(chan *fakeTimer, 16),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, delay: d, c: make(chan time.Time, 1), stopped: make(chan struct{})}
+	c.timers <- t
+	return t
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+func (t *fakeTimer) Stop() bool          { close(t.stopped); return true }
+
+// fire advance the clock to the timer and fire it
+func (t *fakeTimer) fire() {
+	t.clock.Advance(t.delay)
+	t.c <- t.clock.Now()
+}
+
+func (c *fakeClock) nextTimer(tb testing.TB) *fakeTimer {
+	select {
+	case t := <-c.timers:
+		return t
+	case <-time.After(time.Second):
+		tb.Fatal("no timer created")
+	}
+	return nil
+}
+
+func testScheduleMode(t *testing.T, mode ScheduleMode, expected time.Duration) {
+	clock := newFakeClock()
+	s := NewScheduler(clock)
+	calls := 0
+	s.Schedule("job", Schedule{Mode: mode}, func() (time.Duration, Action) {
+		calls++
+		// the job runs for 3 seconds
+		clock.Advance(3 * time.Second)
+		if calls > 2 {
+			return 0, Stop
+		}
+		return 10 * time.Second, Continue
+	})
+
+	if timer := clock.nextTimer(t); timer.delay != 0 {
+		t.Fatalf("first run should start immediately, delay = %v", timer.delay)
+	} else {
+		timer.fire()
+	}
+	for i := 0; i < 2; i++ {
+		timer := clock.nextTimer(t)
+		if timer.delay != expected {
+			t.Fatalf("delay = %v, want %v", timer.delay, expected)
+		}
+		timer.fire()
+	}
+	s.Wait()
+	if s.IsScheduled("job") {
+		t.Error("job should be removed after it returns Stop")
+	}
+}
+
+func TestSchedulerFixedRate(t *testing.T) {
+	testScheduleMode(t, FixedRate, 7*time.Second)
+}
+
+func TestSchedulerFixedDelay(t *testing.T) {
+	testScheduleMode(t, FixedDelay, 10*time.Second)
+}
+
+func TestSchedulerCron(t *testing.T) {
+	clock := newFakeClock()
+	s := NewScheduler(clock)
+	s.Schedule("cron", Schedule{Cron: MustParseCron("0 4 * * *")}, func() (time.Duration, Action) {
+		return 0, Continue
+	})
+
+	// from 03:00 to 04:00
+	if timer := clock.nextTimer(t); timer.delay != time.Hour {
+		t.Fatalf("delay = %v, want 1h", timer.delay)
+	} else {
+		timer.fire()
+	}
+	if timer := clock.nextTimer(t); timer.delay != 24*time.Hour {
+		t.Fatalf("delay = %v, want 24h", timer.delay)
+	}
+	s.Stop()
+	s.Wait()
+}
+
+func TestSchedulerStop(t *testing.T) {
+	clock := newFakeClock()
+	s := NewScheduler(clock)
+	s.Schedule("job", Schedule{}, func() (time.Duration, Action) { return time.Hour, Continue })
+
+	clock.nextTimer(t).fire()
+	timer := clock.nextTimer(t)
+	s.Stop()
+	select {
+	case <-timer.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("timer should be stopped immediately")
+	}
+	s.Wait()
+}
+
+func TestSchedulerWaitTimeout(t *testing.T) {
+	clock := newFakeClock()
+	s := NewScheduler(clock)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Schedule("stuck", Schedule{}, func() (time.Duration, Action) {
+		close(started)
+		<-release
+		return 0, Stop
+	})
+
+	clock.nextTimer(t).fire()
+	<-started
+	s.Stop()
+
+	result := make(chan []string, 1)
+	go func() { result <- s.WaitTimeout(time.Second) }()
+	clock.nextTimer(t).fire()
+	if running := <-result; len(running) != 1 || running[0] != "stuck" {
+		t.Errorf("running = %v, want [stuck]", running)
+	}
+
+	close(release)
+	if running := s.WaitTimeout(0); len(running) != 0 {
+		t.Errorf("running = %v, want none", running)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	loc := time.UTC
+	cases := []struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"0 4 * * *", time.Date(2020, 7, 1, 4, 0, 0, 0, loc), time.Date(2020, 7, 2, 4, 0, 0, 0, loc)},
+		{"*/15 * * * *", time.Date(2020, 7, 1, 4, 7, 30, 0, loc), time.Date(2020, 7, 1, 4, 15, 0, 0, loc)},
+		{"30 23 31 12 *", time.Date(2020, 7, 1, 0, 0, 0, 0, loc), time.Date(2020, 12, 31, 23, 30, 0, 0, loc)},
+		{"0 0 * * 1-5", time.Date(2020, 7, 3, 12, 0, 0, 0, loc), time.Date(2020, 7, 6, 0, 0, 0, 0, loc)},
+		{"0 0 29 2 *", time.Date(2020, 3, 1, 0, 0, 0, 0, loc), time.Date(2024, 2, 29, 0, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		if next := MustParseCron(c.expr).Next(c.from); !next.Equal(c.expected) {
+			t.Errorf("%s : next of %v = %v, want %v", c.expr, c.from, next, c.expected)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "a * * * *", "*/0 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%s should be invalid", expr)
+		}
+	}
+}
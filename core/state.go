@@ -0,0 +1,90 @@
+package core
+
+import "sort"
+
+// ModuleState the lifecycle state of a module
+type ModuleState int32
+
+const (
+	StateRegistered  ModuleState = iota // registered but not initialized
+	StateInitialized                    // Init has been done
+	StateRunning                        // PreTicker has been done
+	StateStopped                        // Shut has been done
+	StateFailed                         // failed in some phase
+)
+
+func (s ModuleState) String() string {
+	switch s {
+	case StateRegistered:
+		return "registered"
+	case StateInitialized:
+		return "initialized"
+	case StateRunning:
+		return "running"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// GetModuleState get the lifecycle state of a module
+func (this *ModuleManager) GetModuleState(name string) ModuleState {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
+	return this.states[name]
+}
+
+func (this *ModuleManager) setModuleState(name string, state ModuleState) {
+	this.stateLock.Lock()
+	this.states[name] = state
+	this.stateLock.Unlock()
+}
+
+// ModuleInfo the runtime information of a module
+type ModuleInfo struct {
+	Name         string   `json:"name"`
+	State        string   `json:"state"`
+	Dependencies []string `json:"dependencies"`
+	Ticking      bool     `json:"ticking"` // whether the ticker is scheduled
+}
+
+// GetModuleInfos get the information of all modules, in start order if the modules have been sorted
+func (this *ModuleManager) GetModuleInfos() []ModuleInfo {
+	infos := this.moduleSnapshot()
+	for i := range infos {
+		infos[i].State = this.GetModuleState(infos[i].Name).String()
+		infos[i].Ticking = this.scheduler.IsScheduled(infos[i].Name)
+	}
+	return infos
+}
+
+// moduleSnapshot get the names and the dependencies of all modules under the module lock
+func (this *ModuleManager) moduleSnapshot() []ModuleInfo {
+	this.moduleLock.RLock()
+	defer this.moduleLock.RUnlock()
+
+	modules := this.modules
+	if len(modules) != len(this.moduleList) {
+		names := make([]string, 0, len(this.moduleList))
+		for name := range this.moduleList {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		modules = make([]IModule, 0, len(names))
+		for _, name := range names {
+			modules = append(modules, this.moduleList[name])
+		}
+	}
+
+	infos := make([]ModuleInfo, 0, len(modules))
+	for _, module := range modules {
+		infos = append(infos, ModuleInfo{
+			Name:         module.GetName(),
+			Dependencies: append([]string(nil), dependenciesOf(module)...),
+		})
+	}
+	return infos
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/pkg/log"
+)
+
+// RestartPolicy decides what to do when a module ticker panics or returns an error
+type RestartPolicy uint8
+
+const (
+	PolicyRestart  RestartPolicy = iota // restart the ticker with backoff
+	PolicyStop                          // stop the ticker
+	PolicyShutdown                      // shutdown the server
+)
+
+const (
+	minRestartBackoff = 100 * time.Millisecond
+	maxRestartBackoff = 30 * time.Second
+)
+
+// ParseRestartPolicy parse a policy from {restart|stop|shutdown}
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch s {
+	case "restart":
+		return PolicyRestart, nil
+	case "stop":
+		return PolicyStop, nil
+	case "shutdown":
+		return PolicyShutdown, nil
+	}
+	return PolicyRestart, fmt.Errorf("invalid restart policy : %s", s)
+}
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case PolicyRestart:
+		return "restart"
+	case PolicyStop:
+		return "stop"
+	case PolicyShutdown:
+		return "shutdown"
+	}
+	return "unknown"
+}
+
+// IRestartPolicyModule is an optional interface for a module to declare its restart policy,
+// the policy in config takes precedence over it.
+type IRestartPolicyModule interface {
+	RestartPolicy() RestartPolicy
+}
+
+// IErrorTicker is an optional interface for a module whose ticker may return a run-time error,
+// if a module implements it, TickerWithError is called instead of Ticker.
+type IErrorTicker interface {
+	TickerWithError() (time.Duration, Action, error)
+}
+
+// getRestartPolicy get the restart policy of a module
+func (this *ModuleManager) getRestartPolicy(module IModule) RestartPolicy {
+	this.cfgLock.RLock()
+	policy, ok := this.policies[module.GetName()]
+	this.cfgLock.RUnlock()
+	if ok {
+		return policy
+	}
+	if m, ok := module.(IRestartPolicyModule); ok {
+		return m.RestartPolicy()
+	}
+	return PolicyRestart
+}
+
+// runTicker run the ticker of a module once, a panic is recovered and returned as an error
+func runTicker(module IModule) (duration time.Duration, action Action, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logpkg.Error("module ticker panic", zap.String("module", module.GetName()), zap.Any("panic", r), zap.Stack("stack"))
+			err = fmt.Errorf("ticker panic : %v", r)
+		}
+	}()
+
+	if ticker, ok := module.(IErrorTicker); ok {
+		return ticker.TickerWithError()
+	}
+	duration, action = module.Ticker()
+	return
+}
+
+// tickerJob wrap the ticker of a module as a scheduled job applying its restart policy on failure
+func (this *ModuleManager) tickerJob(module IModule) Job {
+	policy := this.getRestartPolicy(module)
+	var backoff time.Duration
+
+	return func() (time.Duration, Action) {
+		duration, action, err := runTicker(module)
+		if err != nil {
+			logpkg.Error("module ticker error", zap.String("module", module.GetName()), zap.Error(err), zap.Stringer("policy", policy))
+			switch policy {
+			case PolicyStop:
+				return 0, Stop
+			case PolicyShutdown:
+				this.Shutdown()
+				return 0, Shutdown
+			default:
+				// binary exponential backoff
+				if backoff < minRestartBackoff {
+					backoff = minRestartBackoff
+				} else if backoff *= 2; backoff > maxRestartBackoff {
+					backoff = maxRestartBackoff
+				}
+				logpkg.Info("restart module ticker", zap.String("module", module.GetName()), zap.Duration("backoff", backoff))
+				return backoff, retry
+			}
+		}
+
+		backoff = 0
+		switch action {
+		case Continue:
+		case Stop:
+			logpkg.Debug("module stop ticker", zap.String("module", module.GetName()))
+		default:
+			logpkg.Debug("module shutdown server", zap.String("module", module.GetName()))
+			this.Shutdown()
+			action = Shutdown
+		}
+		return duration, action
+	}
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type panicModule struct {
+	Module
+	calls  int
+	panics int
+	policy RestartPolicy
+}
+
+func (m *panicModule) RestartPolicy() RestartPolicy { return m.policy }
+
+func (m *panicModule) Ticker() (time.Duration, Action) {
+	m.calls++
+	if m.calls <= m.panics {
+		panic("ticker panic")
+	}
+	return time.Second, Continue
+}
+
+type errorModule struct{ Module }
+
+func (m *errorModule) TickerWithError() (time.Duration, Action, error) {
+	return 0, Continue, errors.New("ticker error")
+}
+
+func TestTickerJobRestart(t *testing.T) {
+	mgr := newTestManager()
+	module := &panicModule{panics: 2, policy: PolicyRestart}
+	mgr.RegisterModule("panic", module)
+
+	job := mgr.tickerJob(module)
+	for _, backoff := range []time.Duration{minRestartBackoff, 2 * minRestartBackoff} {
+		if d, action := job(); action != retry || d != backoff {
+			t.Fatalf("job() = (%v, %v), want (%v, retry)", d, action, backoff)
+		}
+	}
+	if d, action := job(); action != Continue || d != time.Second {
+		t.Fatalf("job() = (%v, %v), ticker should be restarted", d, action)
+	}
+}
+
+func TestTickerJobStop(t *testing.T) {
+	mgr := newTestManager()
+	module := &panicModule{panics: 1, policy: PolicyRestart}
+	mgr.RegisterModule("panic", module)
+	// policy in config takes precedence over the module's
+	mgr.policies["panic"] = PolicyStop
+
+	if _, action := mgr.tickerJob(module)(); action != Stop {
+		t.Errorf("ticker should be stopped after a panic, action = %v", action)
+	}
+}
+
+func TestTickerJobShutdown(t *testing.T) {
+	mgr := newTestManager()
+	mgr.SetNotifyChan(make(chan os.Signal, 1))
+	module := new(errorModule)
+	mgr.RegisterModule("error", module)
+	mgr.policies["error"] = PolicyShutdown
+
+	if _, action := mgr.tickerJob(module)(); action != Shutdown {
+		t.Errorf("action = %v, want Shutdown", action)
+	}
+	select {
+	case <-mgr.notifyChan:
+	default:
+		t.Error("server should be notified to shut down")
+	}
+}
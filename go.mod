@@ -3,6 +3,7 @@ module github.com/overtalk/bgo
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/aliyun/aliyun-oss-go-sdk v2.1.3+incompatible
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/funny/utest v0.0.0-20161029064919-43870a374500
@@ -10,6 +11,7 @@ require (
 	github.com/go-redis/redis v6.15.8+incompatible
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/golang/protobuf v1.4.1
+	github.com/klauspost/compress v1.11.13
 	github.com/lestrrat-go/file-rotatelogs v2.3.0+incompatible
 	github.com/lestrrat-go/strftime v1.0.1 // indirect
 	github.com/pkg/errors v0.8.1
@@ -19,4 +21,5 @@ require (
 	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e // indirect
 	google.golang.org/grpc v1.30.0
 	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.2.8
 )
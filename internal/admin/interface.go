@@ -0,0 +1,25 @@
+package iadmin
+
+import (
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/service/tunnel"
+)
+
+const ModuleName = "internal.admin"
+
+// StatsFunc returns the stats of a component, it should be json encodable
+type StatsFunc func() interface{}
+
+// IStatsJSON is implemented by the modules whose stats are listed automatically, eg: ILrucacheModule
+type IStatsJSON interface {
+	StatsJSON() string
+}
+
+type IAdminModule interface {
+	core.IModule
+
+	// RegisterStats register the stats of a component, eg: the conn number of a NetService
+	RegisterStats(name string, fn StatsFunc)
+	// SetBackendSessionMgr set the BackendSessionMgr to pause/resume, the default one is used if not set
+	SetBackendSessionMgr(mgr *tunnel.BackendSessionMgr)
+}
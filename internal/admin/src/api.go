@@ -0,0 +1,172 @@
+package cadmin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/internal/admin"
+	"github.com/overtalk/bgo/pkg/log"
+)
+
+func (this *CAdminModule) registerHandlers() {
+	// probes
+	this.mux.Handle("/healthz", this.GetManager().LivenessHandler())
+	this.mux.Handle("/readyz", this.GetManager().ReadinessHandler())
+
+	// introspection
+	this.mux.HandleFunc("/admin/modules", this.get(this.handleModules))
+	this.mux.HandleFunc("/admin/stats", this.get(this.handleStats))
+	this.mux.HandleFunc("/admin/build", this.get(this.handleBuild))
+
+	// actions, authenticated by the token
+	this.mux.HandleFunc("/admin/log/level", this.handleLogLevel)
+	this.mux.HandleFunc("/admin/reload", this.action(this.handleReload))
+	this.mux.HandleFunc("/admin/backend/pause", this.action(this.handleBackendState(true)))
+	this.mux.HandleFunc("/admin/backend/resume", this.action(this.handleBackendState(false)))
+	this.mux.HandleFunc("/admin/shutdown", this.action(this.handleShutdown))
+}
+
+// get only allows GET requests
+func (this *CAdminModule) get(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// action only allows authenticated POST requests
+func (this *CAdminModule) action(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !this.authenticate(r) {
+			logpkg.Warn("unauthorized admin action", zap.String("path", r.URL.Path), zap.String("remote", r.RemoteAddr))
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		logpkg.Info("admin action", zap.String("path", r.URL.Path), zap.String("remote", r.RemoteAddr))
+		h(w, r)
+	}
+}
+
+// authenticate check the token in the header "Authorization: Bearer <token>"
+func (this *CAdminModule) authenticate(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return len(token) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(this.cfg.Token)) == 1
+}
+
+// handleModules list all modules with their lifecycle state and ticker status
+func (this *CAdminModule) handleModules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, this.GetManager().GetModuleInfos())
+}
+
+// handleBuild show the build metadata, eg: the version and the module versions
+func (this *CAdminModule) handleBuild(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, core.GetBuildInfo())
+}
+
+// handleStats list the stats of the modules implementing IStatsJSON and the registered components
+func (this *CAdminModule) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]interface{})
+	for _, info := range this.GetManager().GetModuleInfos() {
+		if m, ok := this.GetManager().FindModule(info.Name).(iadmin.IStatsJSON); ok {
+			stats[info.Name] = json.RawMessage(m.StatsJSON())
+		}
+	}
+
+	this.statsLock.RLock()
+	for name, fn := range this.stats {
+		stats[name] = fn()
+	}
+	this.statsLock.RUnlock()
+
+	if mgr := this.getBackendSessionMgr(); mgr != nil {
+		stats["backend"] = map[string]interface{}{"service_off": mgr.IsServiceOff()}
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleLogLevel get the log levels by GET, or change it by POST with the form value "level",
+// the level of a package is changed with the form value "package"
+func (this *CAdminModule) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeLogLevels(w)
+		return
+	}
+
+	this.action(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if pkg := r.FormValue("package"); len(pkg) > 0 {
+			err = logpkg.SetPackageLevel(pkg, r.FormValue("level"))
+		} else {
+			err = logpkg.SetLevel(r.FormValue("level"))
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeLogLevels(w)
+	})(w, r)
+}
+
+func writeLogLevels(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"level":    logpkg.GetLevel(),
+		"packages": logpkg.GetPackageLevels(),
+	})
+}
+
+// handleReload reload the configs of all modules
+func (this *CAdminModule) handleReload(w http.ResponseWriter, r *http.Request) {
+	result, err := this.GetManager().Reload()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"result": result, "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+}
+
+// handleBackendState pause or resume the service of BackendSessionMgr
+func (this *CAdminModule) handleBackendState(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mgr := this.getBackendSessionMgr()
+		if mgr == nil {
+			writeError(w, http.StatusNotFound, "backend session manager is not available")
+			return
+		}
+		if pause {
+			mgr.SetServiceOff()
+		} else {
+			mgr.SetServiceOn()
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"service_off": mgr.IsServiceOff()})
+	}
+}
+
+// handleShutdown start a graceful shutdown
+func (this *CAdminModule) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "shutting down"})
+	this.GetManager().Shutdown()
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logpkg.Error("write admin response error", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,103 @@
+package cadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/service/tunnel"
+)
+
+func newTestAdmin(t *testing.T) *CAdminModule {
+	module := &CAdminModule{cfg: &Config{Host: "127.0.0.1", Port: 9999, Token: "secret"}}
+	core.NewModuleManager().RegisterModule("internal.admin", module)
+	if err := module.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return module
+}
+
+func serve(module *CAdminModule, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	module.mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestModules(t *testing.T) {
+	module := newTestAdmin(t)
+	w := serve(module, http.MethodGet, "/admin/modules", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d", w.Code)
+	}
+
+	var infos []core.ModuleInfo
+	if err := json.NewDecoder(w.Body).Decode(&infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name != "internal.admin" || infos[0].State != "registered" {
+		t.Errorf("infos = %+v", infos)
+	}
+}
+
+func TestStats(t *testing.T) {
+	module := newTestAdmin(t)
+	module.RegisterStats("conn", func() interface{} { return 10 })
+	w := serve(module, http.MethodGet, "/admin/stats", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"conn":10`) {
+		t.Errorf("code = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBuild(t *testing.T) {
+	core.SetBuildInfo("v1.2.3", "master", "abcdef")
+	module := newTestAdmin(t)
+	w := serve(module, http.MethodGet, "/admin/build", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d", w.Code)
+	}
+
+	var info core.BuildInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "v1.2.3" || info.Commit != "abcdef" || len(info.GoVersion) == 0 {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestActions(t *testing.T) {
+	module := newTestAdmin(t)
+	mgr := tunnel.NewBackendSessionMgr()
+	module.SetBackendSessionMgr(mgr)
+
+	if w := serve(module, http.MethodPost, "/admin/backend/pause", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect unauthorized, code = %d", w.Code)
+	}
+	if w := serve(module, http.MethodPost, "/admin/backend/pause", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect unauthorized, code = %d", w.Code)
+	}
+	if w := serve(module, http.MethodGet, "/admin/backend/pause", "secret"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expect method not allowed, code = %d", w.Code)
+	}
+
+	if w := serve(module, http.MethodPost, "/admin/backend/pause", "secret"); w.Code != http.StatusOK || !mgr.IsServiceOff() {
+		t.Errorf("pause : code = %d, service off = %v", w.Code, mgr.IsServiceOff())
+	}
+	if w := serve(module, http.MethodPost, "/admin/backend/resume", "secret"); w.Code != http.StatusOK || mgr.IsServiceOff() {
+		t.Errorf("resume : code = %d, service off = %v", w.Code, mgr.IsServiceOff())
+	}
+
+	if w := serve(module, http.MethodPost, "/admin/log/level?level=info", "secret"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "info") {
+		t.Errorf("log level : code = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := serve(module, http.MethodPost, "/admin/log/level?level=bad", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad log level : code = %d", w.Code)
+	}
+}
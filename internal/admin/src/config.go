@@ -0,0 +1,32 @@
+package cadmin
+
+import (
+	"encoding/xml"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/overtalk/bgo/utils/config"
+)
+
+type Config struct {
+	XMLName xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	Host    string   `xml:"host" json:"host" yaml:"host" toml:"host" default:"127.0.0.1"`
+	Port    int      `xml:"port" json:"port" yaml:"port" toml:"port" validate:"required,min=1,max=65535"`
+	// Token the token to authenticate the actions, passed by the header "Authorization: Bearer <token>"
+	Token string `xml:"token" json:"token" yaml:"token" toml:"token" secret:"true" validate:"required"`
+}
+
+// MarshalLogObject log the config with its secrets redacted
+func (this *Config) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return configutil.MarshalLogObject(enc, this)
+}
+
+func (this *CAdminModule) LoadConfig(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	this.cfg = cfg
+	return nil
+}
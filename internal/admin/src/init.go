@@ -0,0 +1,91 @@
+package cadmin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/internal/admin"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/pkg/service/tunnel"
+	"github.com/overtalk/bgo/utils/net"
+)
+
+// shutdownTimeout the max time to wait for the active requests when shutting down
+const shutdownTimeout = 5 * time.Second
+
+func init() {
+	core.RegisterFactory(iadmin.ModuleName, func() core.IModule {
+		var module iadmin.IAdminModule = new(CAdminModule)
+		return module
+	})
+}
+
+type CAdminModule struct {
+	core.Module
+
+	cfg *Config
+	mux *http.ServeMux
+	svr *http.Server
+
+	statsLock  sync.RWMutex
+	stats      map[string]iadmin.StatsFunc
+	backendMgr *tunnel.BackendSessionMgr
+}
+
+func (this *CAdminModule) Init() error {
+	this.stats = make(map[string]iadmin.StatsFunc)
+	this.mux = http.NewServeMux()
+	this.svr = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", this.cfg.Host, this.cfg.Port),
+		Handler: this.mux,
+	}
+	this.registerHandlers()
+	return nil
+}
+
+func (this *CAdminModule) PreTicker() error {
+	go func() {
+		logpkg.Info("start admin http server", zap.String("addr", this.svr.Addr))
+		l, err := netutil.Listen("tcp", this.svr.Addr)
+		if err != nil {
+			logpkg.Fatal("start admin http server error", zap.Error(err))
+		}
+		if err := this.svr.Serve(l); err != nil && err != http.ErrServerClosed {
+			logpkg.Fatal("start admin http server error", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (this *CAdminModule) Shut() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return this.svr.Shutdown(ctx)
+}
+
+func (this *CAdminModule) RegisterStats(name string, fn iadmin.StatsFunc) {
+	this.statsLock.Lock()
+	this.stats[name] = fn
+	this.statsLock.Unlock()
+}
+
+func (this *CAdminModule) SetBackendSessionMgr(mgr *tunnel.BackendSessionMgr) {
+	this.statsLock.Lock()
+	this.backendMgr = mgr
+	this.statsLock.Unlock()
+}
+
+func (this *CAdminModule) getBackendSessionMgr() *tunnel.BackendSessionMgr {
+	this.statsLock.RLock()
+	defer this.statsLock.RUnlock()
+	if this.backendMgr != nil {
+		return this.backendMgr
+	}
+	return tunnel.GetBackendSessionMgr()
+}
@@ -4,25 +4,31 @@ import (
 	"encoding/xml"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/overtalk/bgo/internal/aliyun/oss"
 	"github.com/overtalk/bgo/pkg/log"
-	"github.com/overtalk/bgo/utils/xml"
+	"github.com/overtalk/bgo/utils/config"
 )
 
 type Config struct {
-	XMLName          xml.Name `xml:"xml"`
-	Ak               string   `xml:"ak"`
-	Sk               string   `xml:"sk"`
-	Endpoint         string   `xml:"endpoint"`
-	InternetEndpoint string   `xml:"internetEndpoint"`
-	Bucket           string   `xml:"bucket"`
-	Dir              string   `xml:"dir"`
+	XMLName          xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	Ak               string   `xml:"ak" json:"ak" yaml:"ak" toml:"ak" secret:"true" validate:"required"`
+	Sk               string   `xml:"sk" json:"sk" yaml:"sk" toml:"sk" secret:"true" validate:"required"`
+	Endpoint         string   `xml:"endpoint" json:"endpoint" yaml:"endpoint" toml:"endpoint" validate:"required"`
+	InternetEndpoint string   `xml:"internetEndpoint" json:"internetEndpoint" yaml:"internetEndpoint" toml:"internetEndpoint"`
+	Bucket           string   `xml:"bucket" json:"bucket" yaml:"bucket" toml:"bucket" validate:"required"`
+	Dir              string   `xml:"dir" json:"dir" yaml:"dir" toml:"dir"`
+}
+
+// MarshalLogObject log the config with its secrets redacted
+func (this *Config) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return configutil.MarshalLogObject(enc, this)
 }
 
 func (this *CAliyunOssModule) LoadConfig(path string) error {
 	cfg := &Config{}
-	if err := xmlutil.ParseXml(path, cfg); err != nil {
+	if err := configutil.Load(path, cfg); err != nil {
 		return err
 	}
 	this.cfg = cfg
@@ -8,8 +8,10 @@ import (
 )
 
 func init() {
-	var module ialiyunoss.IAliyunOssModule = new(CAliyunOssModule)
-	core.GetCore().RegisterModule(ialiyunoss.ModuleName, module)
+	core.RegisterFactory(ialiyunoss.ModuleName, func() core.IModule {
+		var module ialiyunoss.IAliyunOssModule = new(CAliyunOssModule)
+		return module
+	})
 }
 
 type CAliyunOssModule struct {
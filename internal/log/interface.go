@@ -0,0 +1,16 @@
+package ilog
+
+import (
+	"github.com/overtalk/bgo/core"
+)
+
+const ModuleName = "internal.log"
+
+type ILogModule interface {
+	core.IModule
+
+	// SetLevel change the level of a package at runtime, the global level is changed if pkg is empty
+	SetLevel(pkg, level string) error
+	// GetLevels get the global level keyed by "" and the levels of the packages
+	GetLevels() map[string]string
+}
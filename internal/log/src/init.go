@@ -0,0 +1,76 @@
+package clog
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/internal/log"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/utils/config"
+)
+
+func init() {
+	core.RegisterFactory(ilog.ModuleName, func() core.IModule {
+		var module ilog.ILogModule = new(CLogModule)
+		return module
+	})
+}
+
+// CLogModule build the global logger of logpkg, it should be the first module in the config
+// so that the other modules log with it from Init.
+type CLogModule struct {
+	core.Module
+
+	cfg *logpkg.Config
+}
+
+func (this *CLogModule) LoadConfig(path string) error {
+	cfg := &logpkg.Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	this.cfg = cfg
+	return nil
+}
+
+func (this *CLogModule) Init() error {
+	if err := logpkg.Init(this.cfg); err != nil {
+		return err
+	}
+	logpkg.Info("logger initialized", zap.String("level", logpkg.GetLevel()), zap.Int("sinks", len(this.cfg.Sinks)))
+	return nil
+}
+
+// Reload rebuild the logger with the new config
+func (this *CLogModule) Reload(path string) error {
+	cfg := &logpkg.Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+	if err := logpkg.Init(cfg); err != nil {
+		return err
+	}
+
+	this.cfg = cfg
+	return nil
+}
+
+func (this *CLogModule) Shut() error {
+	// the error of syncing stdout is meaningless
+	logpkg.Logger().Sync()
+	return nil
+}
+
+func (this *CLogModule) SetLevel(pkg, level string) error {
+	if len(pkg) == 0 {
+		return logpkg.SetLevel(level)
+	}
+	return logpkg.SetPackageLevel(pkg, level)
+}
+
+func (this *CLogModule) GetLevels() map[string]string {
+	levels := logpkg.GetPackageLevels()
+	levels[""] = logpkg.GetLevel()
+	return levels
+}
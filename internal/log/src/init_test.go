@@ -0,0 +1,38 @@
+package clog
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/log"
+)
+
+func TestLoadConfig(t *testing.T) {
+	defer logpkg.ResetPackageLevels()
+	defer logpkg.SetLevel("debug")
+
+	module := new(CLogModule)
+	if err := module.LoadConfig(filepath.Join("..", "..", "..", "build", "conf", "examples", "log.xml")); err != nil {
+		t.Fatal(err)
+	}
+	if len(module.cfg.Sinks) != 4 || module.cfg.Sinks[0].Output != "stdout" || module.cfg.Sinks[2].Encoding != "console" ||
+		module.cfg.Sinks[3].Format != "rfc5424" || module.cfg.Sinks[3].Network != "tcp" {
+		t.Errorf("sinks = %+v", module.cfg.Sinks)
+	}
+	if module.cfg.Sampling.Initial != 100 || len(module.cfg.Packages) != 2 {
+		t.Errorf("config = %+v", module.cfg)
+	}
+
+	// the file sinks are not built, the log dir doesn't exist
+	module.cfg.Sinks = module.cfg.Sinks[:1]
+	if err := module.Init(); err != nil {
+		t.Fatal(err)
+	}
+	levels := module.GetLevels()
+	if levels[""] != "info" || levels["tunnel"] != "warn" {
+		t.Errorf("levels = %v", levels)
+	}
+	if err := module.SetLevel("tunnel", "error"); err != nil || module.GetLevels()["tunnel"] != "error" {
+		t.Errorf("set level : %v, levels = %v", err, module.GetLevels())
+	}
+}
@@ -35,6 +35,7 @@ func (lru *CLrucacheModule) SetDefaultTTL(ttl time.Duration) { lru.ttl = ttl }
 // Get returns a value from the cache, and marks the entry as most
 // recently used.
 func (lru *CLrucacheModule) Get(key string) (v interface{}, ok bool) {
+	defer func() { lru.observeGet(ok) }()
 	lru.mu.Lock()
 	element := lru.table[key]
 	if element == nil {
@@ -174,6 +175,7 @@ func (lru *CLrucacheModule) checkCapacity() {
 		lru.list.Remove(delElem)
 		delete(lru.table, delValue.key)
 		lru.size--
+		cacheEvictions.With(lru.GetName()).Inc()
 	}
 }
 
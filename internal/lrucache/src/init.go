@@ -15,8 +15,10 @@ const (
 )
 
 func init() {
-	var module ilrucache.ILrucacheModule = new(CLrucacheModule)
-	core.GetCore().RegisterModule(ilrucache.ModuleName, module)
+	core.RegisterFactory(ilrucache.ModuleName, func() core.IModule {
+		var module ilrucache.ILrucacheModule = new(CLrucacheModule)
+		return module
+	})
 }
 
 // LRUCache is a typical LRU cache implementation.  If the cache
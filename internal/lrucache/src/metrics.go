@@ -0,0 +1,22 @@
+package clrucache
+
+import (
+	"github.com/overtalk/bgo/pkg/metrics"
+)
+
+var (
+	cacheRequests  = metricspkg.NewCounterVec("bgo_lrucache_requests_total", "Total number of Get requests of each cache by result.", "cache", "result")
+	cacheEvictions = metricspkg.NewCounterVec("bgo_lrucache_evictions_total", "Total number of items evicted by the capacity of each cache.", "cache")
+)
+
+func init() {
+	metricspkg.MustRegister(cacheRequests, cacheEvictions)
+}
+
+func (lru *CLrucacheModule) observeGet(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.With(lru.GetName(), result).Inc()
+}
@@ -0,0 +1,15 @@
+package imetrics
+
+import (
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/metrics"
+)
+
+const ModuleName = "internal.metrics"
+
+type IMetricsModule interface {
+	core.IModule
+
+	// GetRegistry get the registry served by the module
+	GetRegistry() *metricspkg.Registry
+}
@@ -0,0 +1,24 @@
+package cmetrics
+
+import (
+	"encoding/xml"
+
+	"github.com/overtalk/bgo/utils/config"
+)
+
+type Config struct {
+	XMLName xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	Host    string   `xml:"host" json:"host" yaml:"host" toml:"host" default:"0.0.0.0"`
+	Port    int      `xml:"port" json:"port" yaml:"port" toml:"port" validate:"required,min=1,max=65535"`
+	Path    string   `xml:"path" json:"path" yaml:"path" toml:"path" default:"/metrics"`
+}
+
+func (this *CMetricsModule) LoadConfig(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	this.cfg = cfg
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmetrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/internal/metrics"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/pkg/metrics"
+	"github.com/overtalk/bgo/utils/net"
+)
+
+// shutdownTimeout the max time to wait for the active scrapes when shutting down
+const shutdownTimeout = 5 * time.Second
+
+func init() {
+	core.RegisterFactory(imetrics.ModuleName, func() core.IModule {
+		var module imetrics.IMetricsModule = new(CMetricsModule)
+		return module
+	})
+}
+
+type CMetricsModule struct {
+	core.Module
+
+	cfg      *Config
+	registry *metricspkg.Registry
+	svr      *http.Server
+}
+
+func (this *CMetricsModule) Init() error {
+	this.registry = metricspkg.DefaultRegistry
+	mux := http.NewServeMux()
+	mux.Handle(this.cfg.Path, this.registry.Handler())
+	this.svr = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", this.cfg.Host, this.cfg.Port),
+		Handler: mux,
+	}
+	return nil
+}
+
+func (this *CMetricsModule) PreTicker() error {
+	go func() {
+		logpkg.Info("start metrics http server", zap.String("addr", this.svr.Addr), zap.String("path", this.cfg.Path))
+		l, err := netutil.Listen("tcp", this.svr.Addr)
+		if err != nil {
+			logpkg.Fatal("start metrics http server error", zap.Error(err))
+		}
+		if err := this.svr.Serve(l); err != nil && err != http.ErrServerClosed {
+			logpkg.Fatal("start metrics http server error", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (this *CMetricsModule) Shut() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return this.svr.Shutdown(ctx)
+}
+
+func (this *CMetricsModule) GetRegistry() *metricspkg.Registry { return this.registry }
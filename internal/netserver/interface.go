@@ -0,0 +1,19 @@
+package inetserver
+
+import (
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/service/tcp"
+	"github.com/overtalk/bgo/pkg/service/zd"
+)
+
+const ModuleName = "internal.netserver"
+
+type INetServerModule interface {
+	core.IModule
+
+	// RegisterHandler register a connection handler referenced by the services in the config,
+	// it should be called before PreTicker, eg: in LoadRelatedModules
+	RegisterHandler(name string, h tcp.HandlerFunc)
+	// GetServer get the underlying NetServer, eg: to get the conn number of a service
+	GetServer() *zd.NetServer
+}
@@ -0,0 +1,65 @@
+package cnetserver
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/overtalk/bgo/utils/config"
+)
+
+type ServiceConfig struct {
+	Name       string `xml:"name" json:"name" yaml:"name" toml:"name" validate:"required"`
+	Network    string `xml:"network" json:"network" yaml:"network" toml:"network" default:"tcp" validate:"oneof=tcp|tcp4|tcp6|unix"`
+	Address    string `xml:"address" json:"address" yaml:"address" toml:"address" validate:"required"`
+	MaxConn    int    `xml:"maxConn" json:"maxConn" yaml:"maxConn" toml:"maxConn" validate:"min=0"`
+	ReadSynced bool   `xml:"readSynced" json:"readSynced" yaml:"readSynced" toml:"readSynced"`
+	// Handler the name of the handler registered by RegisterHandler
+	Handler string `xml:"handler" json:"handler" yaml:"handler" toml:"handler" validate:"required"`
+}
+
+type Config struct {
+	XMLName xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	// the max seconds to wait for the active connections when shutting down,
+	// it should be less than the timeout of the PreShut phase(30s by default)
+	ExitTimeout int             `xml:"exitTimeout" json:"exitTimeout" yaml:"exitTimeout" toml:"exitTimeout" default:"25" validate:"min=0"`
+	Services    []ServiceConfig `xml:"services>service" json:"services" yaml:"services" toml:"services"`
+}
+
+func (this *CNetServerModule) LoadConfig(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	this.cfg = cfg
+	return nil
+}
+
+// Reload reload the config, only maxConn of the services can be changed at runtime
+func (this *CNetServerModule) Reload(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	if len(cfg.Services) != len(this.cfg.Services) {
+		return fmt.Errorf("services changed, a restart is required")
+	}
+	for i, service := range cfg.Services {
+		old := this.cfg.Services[i]
+		if service.Name != old.Name || service.Network != old.Network || service.Address != old.Address ||
+			service.ReadSynced != old.ReadSynced || service.Handler != old.Handler {
+			return fmt.Errorf("service %s changed, a restart is required", old.Name)
+		}
+	}
+
+	if this.server != nil {
+		for _, service := range cfg.Services {
+			if ns := this.server.GetService(service.Name); ns != nil {
+				ns.SetMaxConnNum(service.MaxConn)
+			}
+		}
+	}
+	this.cfg = cfg
+	return nil
+}
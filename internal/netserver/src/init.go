@@ -0,0 +1,115 @@
+package cnetserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/internal/admin"
+	"github.com/overtalk/bgo/internal/netserver"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/pkg/service/tcp"
+	"github.com/overtalk/bgo/pkg/service/zd"
+)
+
+func init() {
+	core.RegisterFactory(inetserver.ModuleName, func() core.IModule {
+		var module inetserver.INetServerModule = new(CNetServerModule)
+		return module
+	})
+}
+
+type CNetServerModule struct {
+	core.Module
+
+	cfg      *Config
+	server   *zd.NetServer
+	handlers map[string]tcp.HandlerFunc
+	// the conn numbers of the services are listed in the admin stats
+	Admin iadmin.IAdminModule `module:"internal.admin,optional"`
+}
+
+func (this *CNetServerModule) Init() error {
+	this.server = zd.NewNetServer()
+	this.server.SetExitTimeout(time.Duration(this.cfg.ExitTimeout) * time.Second)
+	if this.Admin != nil {
+		this.Admin.RegisterStats(inetserver.ModuleName, this.stats)
+	}
+	return nil
+}
+
+// stats the conn numbers of the services
+func (this *CNetServerModule) stats() interface{} {
+	stats := make(map[string]interface{})
+	for _, name := range this.server.GetServiceNames() {
+		service := this.server.GetService(name)
+		stats[name] = map[string]interface{}{
+			"conn_num":     service.GetConnNum(),
+			"max_conn_num": service.GetMaxConnNum(),
+		}
+	}
+	return stats
+}
+
+// PreTicker add the services declared in the config and start serving,
+// the handlers are registered by other modules before
+func (this *CNetServerModule) PreTicker() error {
+	for _, service := range this.cfg.Services {
+		handler, ok := this.handlers[service.Handler]
+		if !ok {
+			return fmt.Errorf("service %s : handler %s is not registered", service.Name, service.Handler)
+		}
+		err := this.server.AddService(tcp.NewServiceWithOption(tcp.ServiceOption{
+			Listener: tcp.ListenerOption{
+				Name:       service.Name,
+				Network:    service.Network,
+				Address:    service.Address,
+				MaxConn:    service.MaxConn,
+				ReadSynced: service.ReadSynced,
+			},
+			Handler: handler,
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := this.server.Start(); err != nil {
+		return err
+	}
+	for _, service := range this.cfg.Services {
+		logpkg.Info("start net service", zap.String("name", service.Name), zap.String("network", service.Network), zap.String("address", this.server.GetService(service.Name).GetAddr()))
+	}
+	return nil
+}
+
+// PreShut stop accepting and wait for the active connections within exitTimeout
+func (this *CNetServerModule) PreShut() error {
+	if this.server == nil {
+		return nil
+	}
+	return this.server.Stop()
+}
+
+// HealthCheck check whether all services are accepting connections
+func (this *CNetServerModule) HealthCheck(ctx context.Context) error {
+	for _, name := range this.server.GetServiceNames() {
+		if !this.server.GetService(name).IsListening() {
+			return fmt.Errorf("net service %s is not listening", name)
+		}
+	}
+	return nil
+}
+
+// RegisterHandler the handlers may be registered before Init of this module
+func (this *CNetServerModule) RegisterHandler(name string, h tcp.HandlerFunc) {
+	if this.handlers == nil {
+		this.handlers = make(map[string]tcp.HandlerFunc)
+	}
+	this.handlers[name] = h
+}
+
+func (this *CNetServerModule) GetServer() *zd.NetServer { return this.server }
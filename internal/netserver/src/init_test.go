@@ -0,0 +1,98 @@
+package cnetserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `<xml>
+    <exitTimeout>1</exitTimeout>
+    <services>
+        <service>
+            <name>echo</name>
+            <address>127.0.0.1:0</address>
+            <handler>echo</handler>
+        </service>
+    </services>
+</xml>`
+
+func newTestModule(t *testing.T) *CNetServerModule {
+	dir, err := ioutil.TempDir("", "netserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "netserver.xml")
+	if err := ioutil.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	module := new(CNetServerModule)
+	if err := module.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := module.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return module
+}
+
+func TestConfig(t *testing.T) {
+	module := newTestModule(t)
+	if len(module.cfg.Services) != 1 {
+		t.Fatalf("services = %+v", module.cfg.Services)
+	}
+	if service := module.cfg.Services[0]; service.Network != "tcp" || service.Handler != "echo" {
+		t.Errorf("service = %+v", service)
+	}
+}
+
+func TestUnregisteredHandler(t *testing.T) {
+	module := newTestModule(t)
+	if err := module.PreTicker(); err == nil {
+		t.Error("PreTicker should fail with an unregistered handler")
+	}
+}
+
+func TestServe(t *testing.T) {
+	module := newTestModule(t)
+	module.RegisterHandler("echo", func(conn net.Conn) {
+		defer conn.Close()
+		buf := make([]byte, 4)
+		n, _ := conn.Read(buf)
+		conn.Write(buf[:n])
+	})
+	if err := module.PreTicker(); err != nil {
+		t.Fatal(err)
+	}
+	if err := module.HealthCheck(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	ns := module.GetServer().GetService("echo")
+	conn, err := net.Dial("tcp", ns.GetAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("ping"))
+	buf, err := ioutil.ReadAll(conn)
+	conn.Close()
+	if err != nil || string(buf) != "ping" {
+		t.Errorf("read %q, %v", buf, err)
+	}
+
+	if stats := module.stats().(map[string]interface{}); stats["echo"] == nil {
+		t.Errorf("stats = %v", stats)
+	}
+
+	if err := module.PreShut(); err != nil {
+		t.Error(err)
+	}
+	if err := module.HealthCheck(context.Background()); err == nil {
+		t.Error("health check should fail after shutting down")
+	}
+}
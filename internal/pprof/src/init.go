@@ -10,11 +10,14 @@ import (
 	"github.com/overtalk/bgo/core"
 	"github.com/overtalk/bgo/internal/pprof"
 	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/utils/net"
 )
 
 func init() {
-	var module ipprof.IPProf = new(CPProf)
-	core.GetCore().RegisterModule(ipprof.ModuleName, module)
+	core.RegisterFactory(ipprof.ModuleName, func() core.IModule {
+		var module ipprof.IPProf = new(CPProf)
+		return module
+	})
 }
 
 type CPProf struct {
@@ -51,7 +54,11 @@ func (this *CPProf) PreTicker() error {
 
 	go func() {
 		logpkg.Info("start pprof http server", zap.Any("addr", this.svr.Addr))
-		if err := this.svr.ListenAndServe(); err != nil {
+		l, err := netutil.Listen("tcp", this.svr.Addr)
+		if err != nil {
+			logpkg.Fatal("start pprof http server error", zap.Error(err))
+		}
+		if err := this.svr.Serve(l); err != nil {
 			logpkg.Fatal("start pprof http server error", zap.Error(err))
 		}
 	}()
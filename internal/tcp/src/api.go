@@ -1,8 +1,11 @@
 package ctcp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,6 +15,14 @@ import (
 	"github.com/overtalk/bgo/utils/net"
 )
 
+// HealthCheck check whether the listener is accepting connections
+func (tcp *CTcpModule) HealthCheck(ctx context.Context) error {
+	if atomic.LoadInt32(&tcp.accepting) == 0 {
+		return errors.New("tcp listener is not accepting")
+	}
+	return nil
+}
+
 func (tcp *CTcpModule) RegisterHandler(handler itcp.HandlerFunc) { tcp.connHandler = handler }
 
 func (tcp *CTcpModule) Start() {
@@ -27,21 +38,27 @@ func (tcp *CTcpModule) Start() {
 		logpkg.Fatal("failed to start tcp server", zap.String("reason", "empty tcp handler"))
 	}
 
-	address := fmt.Sprintf("%s:%d", tcp.cfg.Host, tcp.cfg.Port)
-	listener, err := net.Listen(tcp.cfg.Network, address)
+	cfg := tcp.getConfig()
+	address := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	listener, err := netutil.Listen(cfg.Network, address)
 	if err != nil {
 		//logpkg.GetLogger().With(zap.String("address", address)).Fatal("failed to build tcp listener")
 		logpkg.Fatal("failed to build tcp listener", zap.String("address", address))
 	}
 
-	// limit listener
-	if tcp.cfg.MaxConn > 0 {
-		listener = netutil.LimitListener(listener, tcp.cfg.MaxConn)
-	}
+	// limit listener, the limitation can be changed by Reload
+	tcp.lock.Lock()
+	limiter := netutil.NewDynamicLimitListener(listener, tcp.cfg.MaxConn)
+	tcp.listener = limiter
+	tcp.lock.Unlock()
+	listener = limiter
 
 	//logpkg.GetLogger().With(zap.String("address", address)).Info("start tcp server ")
 	logpkg.Info("start tcp server", zap.String("address", address))
 
+	atomic.StoreInt32(&tcp.accepting, 1)
+	defer atomic.StoreInt32(&tcp.accepting, 0)
+
 	var tempDelay time.Duration
 	for {
 		conn, err := listener.Accept()
@@ -59,6 +76,10 @@ func (tcp *CTcpModule) Start() {
 				time.Sleep(tempDelay)
 				continue
 			}
+			// the listener is closed by PreShut
+			if atomic.LoadInt32(&tcp.accepting) == 0 {
+				break
+			}
 			//logpkg.GetLogger().With(zap.Error(err)).Error("accept error")
 			logpkg.Error("accept error", zap.Error(err))
 			break
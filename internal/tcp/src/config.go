@@ -2,26 +2,58 @@ package ctcp
 
 import (
 	"encoding/xml"
+	"fmt"
 
-	"github.com/overtalk/bgo/utils/xml"
+	"github.com/overtalk/bgo/utils/config"
 )
 
 type Config struct {
-	XMLName    xml.Name `xml:"xml"`
-	Name       string   `xml:"name"`
-	Network    string   `xml:"network"`
-	Host       string   `xml:"host"`
-	Port       int      `xml:"port"`
-	MaxConn    int      `xml:"maxConn"`
-	ReadSynced bool     `xml:"readSynced"`
+	XMLName    xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	Name       string   `xml:"name" json:"name" yaml:"name" toml:"name"`
+	Network    string   `xml:"network" json:"network" yaml:"network" toml:"network" default:"tcp" validate:"oneof=tcp|tcp4|tcp6|unix"`
+	Host       string   `xml:"host" json:"host" yaml:"host" toml:"host" default:"0.0.0.0"`
+	Port       int      `xml:"port" json:"port" yaml:"port" toml:"port" validate:"required,min=1,max=65535"`
+	MaxConn    int      `xml:"maxConn" json:"maxConn" yaml:"maxConn" toml:"maxConn" validate:"min=0"`
+	ReadSynced bool     `xml:"readSynced" json:"readSynced" yaml:"readSynced" toml:"readSynced"`
+	// the max seconds to wait for the active connections when shutting down,
+	// it should be less than the timeout of the PreShut phase(30s by default)
+	ExitTimeout int `xml:"exitTimeout" json:"exitTimeout" yaml:"exitTimeout" toml:"exitTimeout" default:"25" validate:"min=0"`
 }
 
 func (tcp *CTcpModule) LoadConfig(path string) error {
 	cfg := &Config{}
-	if err := xmlutil.ParseXml(path, cfg); err != nil {
+	if err := configutil.Load(path, cfg); err != nil {
 		return err
 	}
 
 	tcp.cfg = cfg
 	return nil
 }
+
+// Reload reload the config, only maxConn can be changed at runtime
+func (tcp *CTcpModule) Reload(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	tcp.lock.Lock()
+	defer tcp.lock.Unlock()
+	if cfg.Network != tcp.cfg.Network || cfg.Host != tcp.cfg.Host || cfg.Port != tcp.cfg.Port {
+		return fmt.Errorf("listen address changed from %s://%s:%d, a restart is required",
+			tcp.cfg.Network, tcp.cfg.Host, tcp.cfg.Port)
+	}
+
+	if tcp.listener != nil {
+		tcp.listener.SetLimit(cfg.MaxConn)
+	}
+	tcp.cfg = cfg
+	return nil
+}
+
+// getConfig get the current config, it's replaced by Reload
+func (tcp *CTcpModule) getConfig() *Config {
+	tcp.lock.RLock()
+	defer tcp.lock.RUnlock()
+	return tcp.cfg
+}
@@ -1,15 +1,24 @@
 package ctcp
 
 import (
-	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
 
 	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/internal/admin"
 	"github.com/overtalk/bgo/internal/tcp"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/utils/net"
 )
 
 func init() {
-	var module itcp.ITcpModule = new(CTcpModule)
-	core.GetCore().RegisterModule(itcp.ModuleName, module)
+	core.RegisterFactory(itcp.ModuleName, func() core.IModule {
+		var module itcp.ITcpModule = new(CTcpModule)
+		return module
+	})
 }
 
 type CTcpModule struct {
@@ -17,12 +26,48 @@ type CTcpModule struct {
 
 	// config & other modules
 	cfg *Config
+	// the active conn number is listed in the admin stats
+	Admin iadmin.IAdminModule `module:"internal.admin,optional"`
 	// some other
-	listener    net.Listener
+	lock        sync.RWMutex // guards cfg & listener
+	listener    *netutil.DynamicLimitListener
+	accepting   int32 // 1 if the listener is accepting connections
 	connHandler itcp.HandlerFunc
 }
 
 func (tcp *CTcpModule) PreTicker() error {
+	if tcp.Admin != nil {
+		tcp.Admin.RegisterStats(itcp.ModuleName, tcp.stats)
+	}
 	go tcp.Start()
 	return nil
 }
+
+func (tcp *CTcpModule) getListener() *netutil.DynamicLimitListener {
+	tcp.lock.RLock()
+	defer tcp.lock.RUnlock()
+	return tcp.listener
+}
+
+// stats the active conn number
+func (tcp *CTcpModule) stats() interface{} {
+	stats := map[string]interface{}{"conn_num": 0, "max_conn_num": 0}
+	if listener := tcp.getListener(); listener != nil {
+		stats["conn_num"], stats["max_conn_num"] = listener.GetActive(), listener.GetLimit()
+	}
+	return stats
+}
+
+// PreShut stop accepting and wait for the active connections within exitTimeout
+func (tcp *CTcpModule) PreShut() error {
+	listener := tcp.getListener()
+	if listener == nil {
+		return nil
+	}
+	atomic.StoreInt32(&tcp.accepting, 0)
+	listener.Close()
+	if !listener.Drain(time.Duration(tcp.getConfig().ExitTimeout) * time.Second) {
+		logpkg.Warn("tcp server exit with active connections", zap.Int("active", listener.GetActive()))
+	}
+	return nil
+}
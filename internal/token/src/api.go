@@ -7,6 +7,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/overtalk/bgo/core"
 	"github.com/overtalk/bgo/internal/token"
 	"github.com/overtalk/bgo/pkg/log"
 )
@@ -16,6 +17,9 @@ const (
 	minGameTokenAccessTime = 60 * time.Millisecond
 )
 
+// tokenResetCron the time to reset user data
+var tokenResetCron = core.MustParseCron("0 5 * * *")
+
 var (
 	// ErrTokenNotExist token isn't existed
 	ErrTokenNotExist = errors.New("token not exist")
@@ -29,10 +33,10 @@ var (
 func (tm *CTokenModule) Size() int64 { return tm.cache.Size() }
 
 func (tm *CTokenModule) SetToken(userId string, token string, ttl int64) string {
-	hour, min, sec := time.Now().Clock()
-	if hour == 4 {
-		// at 00:05:00, token will be exipred, to reset user data quickly
-		ttl = maxGameTokenTTL - int64(min*60+sec)
+	now := time.Now()
+	if reset := tokenResetCron.Next(now).Sub(now); reset < maxGameTokenTTL*time.Second {
+		// token will be expired at the reset time, to reset user data quickly
+		ttl = int64(reset / time.Second)
 	}
 	tk := &itoken.GameToken{
 		Token:       token,
@@ -9,8 +9,10 @@ import (
 )
 
 func init() {
-	var module itoken.ITokenModule = new(CTokenModule)
-	core.GetCore().RegisterModule(itoken.ModuleName, module)
+	core.RegisterFactory(itoken.ModuleName, func() core.IModule {
+		var module itoken.ITokenModule = new(CTokenModule)
+		return module
+	})
 }
 
 type CTokenModule struct {
@@ -1,6 +1,8 @@
 package cmysql
 
 import (
+	"context"
+
 	"go.uber.org/zap"
 
 	"github.com/overtalk/bgo/core"
@@ -10,8 +12,10 @@ import (
 )
 
 func init() {
-	var module imysql.IMysqlModule = new(CMysqlModule)
-	core.GetCore().RegisterModule(imysql.ModuleName, module)
+	core.RegisterFactory(imysql.ModuleName, func() core.IModule {
+		var module imysql.IMysqlModule = new(CMysqlModule)
+		return module
+	})
 }
 
 type CMysqlModule struct {
@@ -34,3 +38,11 @@ func (this *CMysqlModule) LoadConfig(path string) error {
 func (this *CMysqlModule) PreTicker() error {
 	return this.mysqlConn.Connect()
 }
+
+func (this *CMysqlModule) Reload(path string) error {
+	return this.mysqlConn.Reload(path)
+}
+
+func (this *CMysqlModule) HealthCheck(ctx context.Context) error {
+	return this.mysqlConn.Ping(ctx)
+}
@@ -1,14 +1,18 @@
 package credis
 
 import (
+	"context"
+
 	"github.com/overtalk/bgo/core"
 	"github.com/overtalk/bgo/modules/redis"
 	"github.com/overtalk/bgo/pkg/redis"
 )
 
 func init() {
-	var module iredis.IRedisModule = new(CRedisModule)
-	core.GetCore().RegisterModule(iredis.ModuleName, module)
+	core.RegisterFactory(iredis.ModuleName, func() core.IModule {
+		var module iredis.IRedisModule = new(CRedisModule)
+		return module
+	})
 }
 
 type CRedisModule struct {
@@ -29,3 +33,11 @@ func (this *CRedisModule) LoadConfig(path string) error {
 func (this *CRedisModule) PreTicker() error {
 	return this.redisClient.Connect()
 }
+
+func (this *CRedisModule) Reload(path string) error {
+	return this.redisClient.Reload(path)
+}
+
+func (this *CRedisModule) HealthCheck(ctx context.Context) error {
+	return this.redisClient.Ping()
+}
@@ -8,16 +8,16 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/overtalk/bgo/pkg/log"
-	"github.com/overtalk/bgo/utils/xml"
+	"github.com/overtalk/bgo/utils/config"
 )
 
 type Config struct {
-	XMLName  xml.Name `xml:"xml"`
-	Name     string   `xml:"name"`
-	Host     string   `xml:"host"`
-	Port     int      `xml:"port"`
-	CertFile string   `xml:"certFile"`
-	KeyFile  string   `xml:"keyFile"`
+	XMLName  xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	Name     string   `xml:"name" json:"name" yaml:"name" toml:"name"`
+	Host     string   `xml:"host" json:"host" yaml:"host" toml:"host" default:"0.0.0.0"`
+	Port     int      `xml:"port" json:"port" yaml:"port" toml:"port" validate:"required,min=1,max=65535"`
+	CertFile string   `xml:"certFile" json:"certFile" yaml:"certFile" toml:"certFile"`
+	KeyFile  string   `xml:"keyFile" json:"keyFile" yaml:"keyFile" toml:"keyFile"`
 }
 
 type GinServer struct {
@@ -28,7 +28,7 @@ type GinServer struct {
 func NewGinServer(path string) (*GinServer, error) {
 	gin.SetMode(gin.ReleaseMode)
 	cfg := &Config{}
-	if err := xmlutil.ParseXml(path, cfg); err != nil {
+	if err := configutil.Load(path, cfg); err != nil {
 		return nil, err
 	}
 	return &GinServer{engine: gin.New(), cfg: cfg}, nil
@@ -0,0 +1,243 @@
+package logpkg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lestrrat-go/file-rotatelogs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/overtalk/bgo/utils/file"
+)
+
+// sink types
+const (
+	SinkConsole = "console"
+	SinkFile    = "file"
+	SinkRemote  = "remote"
+)
+
+// encodings
+const (
+	EncodingConsole = "console"
+	EncodingJSON    = "json"
+)
+
+// SinkConfig a destination of the logs
+type SinkConfig struct {
+	Name string `xml:"name" json:"name" yaml:"name" toml:"name" validate:"required"`
+	// Type {console|file|remote}, the file is rotated by the hour, the remote ships the logs to a collector
+	Type string `xml:"type" json:"type" yaml:"type" toml:"type" default:"console" validate:"oneof=console|file|remote"`
+	// Encoding {console|json}, the remote sink is always encoded in json
+	Encoding string `xml:"encoding" json:"encoding" yaml:"encoding" toml:"encoding" default:"console" validate:"oneof=console|json"`
+	// Level the minimum level written to this sink, eg: error logs to a separate file
+	Level string `xml:"level" json:"level" yaml:"level" toml:"level" default:"debug"`
+	// Output of the console sink, {stdout|stderr}
+	Output string `xml:"output" json:"output" yaml:"output" toml:"output" default:"stdout" validate:"oneof=stdout|stderr"`
+	// Path the dir of the file sink, it should exist
+	Path string `xml:"path" json:"path" yaml:"path" toml:"path"`
+	// Prefix the file name of the file sink, or the app name of the rfc5424 remote sink
+	Prefix       string `xml:"prefix" json:"prefix" yaml:"prefix" toml:"prefix" default:"default"`
+	MaxKeepHour  int    `xml:"maxKeepHour" json:"maxKeepHour" yaml:"maxKeepHour" toml:"maxKeepHour" default:"168" validate:"min=1"`
+	RotationHour int    `xml:"rotationHour" json:"rotationHour" yaml:"rotationHour" toml:"rotationHour" default:"1" validate:"min=1"`
+
+	// Network of the remote sink, {tcp|udp}, the entries are separated by newlines in tcp
+	Network string `xml:"network" json:"network" yaml:"network" toml:"network" default:"tcp" validate:"oneof=tcp|udp"`
+	// Address of the remote collector, eg: 127.0.0.1:514
+	Address string `xml:"address" json:"address" yaml:"address" toml:"address"`
+	// Format of the remote sink, {ndjson|rfc5424}
+	Format string `xml:"format" json:"format" yaml:"format" toml:"format" default:"ndjson" validate:"oneof=ndjson|rfc5424"`
+	// BatchSize the max entries shipped at once
+	BatchSize int `xml:"batchSize" json:"batchSize" yaml:"batchSize" toml:"batchSize" default:"100" validate:"min=1"`
+	// FlushInterval ship the entries at least once in the interval, eg: 1s
+	FlushInterval string `xml:"flushInterval" json:"flushInterval" yaml:"flushInterval" toml:"flushInterval" default:"1s"`
+	// QueueSize the max entries waiting to be shipped, the debug entries are dropped first if it's full
+	QueueSize int `xml:"queueSize" json:"queueSize" yaml:"queueSize" toml:"queueSize" default:"10000" validate:"min=1"`
+	// SpillPath the dir to spill the entries when the collector is unreachable, spilling is disabled if it's empty
+	SpillPath  string `xml:"spillPath" json:"spillPath" yaml:"spillPath" toml:"spillPath"`
+	SpillMaxMB int    `xml:"spillMaxMB" json:"spillMaxMB" yaml:"spillMaxMB" toml:"spillMaxMB" default:"64" validate:"min=1"`
+}
+
+// PackageConfig the level of a package, see Named
+type PackageConfig struct {
+	Name  string `xml:"name,attr" json:"name" yaml:"name" toml:"name" validate:"required"`
+	Level string `xml:"level,attr" json:"level" yaml:"level" toml:"level" validate:"required"`
+}
+
+// SamplingConfig sample the logs of the hot paths, in every tick, the first Initial entries with
+// the same level and message are logged, and then every Thereafter-th. it's disabled if Initial is 0,
+// and the rest are dropped if Thereafter is 0.
+type SamplingConfig struct {
+	Initial    int    `xml:"initial" json:"initial" yaml:"initial" toml:"initial" validate:"min=0"`
+	Thereafter int    `xml:"thereafter" json:"thereafter" yaml:"thereafter" toml:"thereafter" validate:"min=0"`
+	Tick       string `xml:"tick" json:"tick" yaml:"tick" toml:"tick" default:"1s"`
+}
+
+// Config the config of the global logger
+type Config struct {
+	XMLName xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	// Level the minimum level of the packages without their own levels
+	Level    string          `xml:"level" json:"level" yaml:"level" toml:"level" default:"debug"`
+	Packages []PackageConfig `xml:"packages>package" json:"packages" yaml:"packages" toml:"packages"`
+	// Sinks a console sink is used if it's empty
+	Sinks    []SinkConfig   `xml:"sinks>sink" json:"sinks" yaml:"sinks" toml:"sinks"`
+	Sampling SamplingConfig `xml:"sampling" json:"sampling" yaml:"sampling" toml:"sampling"`
+}
+
+func parseLevel(l string, def zapcore.Level) (zapcore.Level, error) {
+	if len(l) == 0 {
+		return def, nil
+	}
+	var lvl zapcore.Level
+	err := lvl.UnmarshalText([]byte(l))
+	return lvl, err
+}
+
+func newEncoder(encoding string) (zapcore.Encoder, error) {
+	switch encoding {
+	case "", EncodingConsole:
+		return zapcore.NewConsoleEncoder(encoderConfig), nil
+	case EncodingJSON:
+		return zapcore.NewJSONEncoder(encoderConfig), nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
+
+// newWriteSyncer create the writer of a sink, the closer is nil if it needn't be closed
+func newWriteSyncer(sink SinkConfig) (zapcore.WriteSyncer, io.Closer, error) {
+	switch sink.Type {
+	case "", SinkConsole:
+		if sink.Output == "stderr" {
+			return zapcore.Lock(os.Stderr), nil, nil
+		}
+		return zapcore.Lock(os.Stdout), nil, nil
+	case SinkFile:
+		if !fileutil.PathExists(sink.Path) {
+			return nil, nil, fmt.Errorf("log path %s is absent", sink.Path)
+		}
+		if !fileutil.IsDir(sink.Path) {
+			return nil, nil, fmt.Errorf("log path %s should be a dir", sink.Path)
+		}
+		prefix, maxKeepHour, rotationHour := sink.Prefix, sink.MaxKeepHour, sink.RotationHour
+		if len(prefix) == 0 {
+			prefix = "default"
+		}
+		if maxKeepHour <= 0 {
+			maxKeepHour = 24 * 7
+		}
+		if rotationHour <= 0 {
+			rotationHour = 1
+		}
+		hook, err := rotatelogs.New(
+			filepath.Join(sink.Path, prefix)+"-%Y%m%d%H.log",
+			rotatelogs.WithLinkName(filepath.Join(sink.Path, prefix+".log")),
+			rotatelogs.WithMaxAge(time.Duration(maxKeepHour)*time.Hour),
+			rotatelogs.WithRotationTime(time.Duration(rotationHour)*time.Hour),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zapcore.AddSync(hook), hook, nil
+	}
+	return nil, nil, fmt.Errorf("unknown sink type %q", sink.Type)
+}
+
+// Build build a logger with the config, the global level and the package levels are reset by the config
+func Build(cfg *Config) (*zap.Logger, error) {
+	core, _, err := build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return zap.New(&levelCore{core}, zap.AddCaller()), nil
+}
+
+// build build the core of the sinks with the config, the closers close the sinks after they're replaced
+func build(cfg *Config) (core zapcore.Core, closers []io.Closer, err error) {
+	rootLevel, err := parseLevel(cfg.Level, zapcore.DebugLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range cfg.Packages {
+		if _, err := parseLevel(pkg.Level, zapcore.DebugLevel); err != nil {
+			return nil, nil, fmt.Errorf("level of package %s : %v", pkg.Name, err)
+		}
+	}
+
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []SinkConfig{{Name: SinkConsole, Type: SinkConsole}}
+	}
+	var (
+		cores = make([]zapcore.Core, 0, len(sinks))
+		names = make(map[string]bool, len(sinks))
+	)
+	defer func() {
+		// stop shipping and close the files if any sink fails
+		if err != nil {
+			for _, closer := range closers {
+				closer.Close()
+			}
+		}
+	}()
+	for _, sink := range sinks {
+		if names[sink.Name] {
+			return nil, nil, fmt.Errorf("sink %s is duplicated", sink.Name)
+		}
+		names[sink.Name] = true
+
+		sinkLevel, err := parseLevel(sink.Level, zapcore.DebugLevel)
+		if err != nil {
+			return nil, nil, fmt.Errorf("level of sink %s : %v", sink.Name, err)
+		}
+		if sink.Type == SinkRemote {
+			remote, err := newRemoteSink(sink)
+			if err != nil {
+				return nil, nil, fmt.Errorf("sink %s : %v", sink.Name, err)
+			}
+			closers = append(closers, remote)
+			cores = append(cores, newRemoteCore(remote, sinkLevel))
+			continue
+		}
+		encoder, err := newEncoder(sink.Encoding)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sink %s : %v", sink.Name, err)
+		}
+		ws, closer, err := newWriteSyncer(sink)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sink %s : %v", sink.Name, err)
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		cores = append(cores, zapcore.NewCore(encoder, ws, sinkLevel))
+	}
+
+	core = zapcore.NewTee(cores...)
+	if cfg.Sampling.Initial > 0 {
+		tick := time.Second
+		if len(cfg.Sampling.Tick) > 0 {
+			if tick, err = time.ParseDuration(cfg.Sampling.Tick); err != nil {
+				return nil, nil, fmt.Errorf("sampling tick : %v", err)
+			}
+		}
+		thereafter := cfg.Sampling.Thereafter
+		if thereafter <= 0 {
+			// drop all entries after the initial ones in a tick
+			thereafter = math.MaxInt32
+		}
+		core = zapcore.NewSampler(core, tick, cfg.Sampling.Initial, thereafter)
+	}
+
+	level.SetLevel(rootLevel)
+	ResetPackageLevels()
+	for _, pkg := range cfg.Packages {
+		SetPackageLevel(pkg.Name, pkg.Level)
+	}
+	return core, closers, nil
+}
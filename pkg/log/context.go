@@ -0,0 +1,60 @@
+package logpkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// the keys of the request-scoped fields
+const (
+	KeyConnID     = "conn_id"
+	KeyBackendID  = "backend_id"
+	KeyRemoteAddr = "remote_addr"
+	KeyTraceID    = "trace_id"
+	KeyMID        = "mid"
+	KeyAID        = "aid"
+)
+
+type loggerKey struct{}
+
+// Logger get the global logger
+func Logger() *zap.Logger { return getLogger() }
+
+// With create a child logger of the global logger with some fields,
+// eg: a session logger carrying the conn id and the remote address
+func With(fields ...zap.Field) *zap.Logger { return getLogger().With(fields...) }
+
+// NewContext create a context carrying the logger
+func NewContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext get the logger carried by the context, the global logger is returned if absent
+func FromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok && logger != nil {
+		return logger
+	}
+	return getLogger()
+}
+
+// NewTraceID generate a random trace id for a request which doesn't pass through a tunnel,
+// it's also the random salt of the trace ids of a tunnel
+func NewTraceID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "0000000000000000"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// TraceID get the trace id of a request passing through a tunnel, it's derived from the random salt
+// generated by the agent for each tunnel connection and sent in the register, and the conn id carried
+// by each packet, so the agent and the backend log the same trace id without changing the packet.
+// a conn id is unique in a tunnel connection and a frontend session carries one request.
+func TraceID(salt string, connID uint32) string {
+	return fmt.Sprintf("%s%08x", salt, connID)
+}
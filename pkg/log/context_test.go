@@ -0,0 +1,28 @@
+package logpkg
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestTraceID(t *testing.T) {
+	if id := TraceID("0123456789abcdef", 101); id != "0123456789abcdef00000065" {
+		t.Errorf("trace id = %s", id)
+	}
+	a, b := NewTraceID(), NewTraceID()
+	if len(a) != 16 || a == b {
+		t.Errorf("random trace ids : %s, %s", a, b)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != getLogger() {
+		t.Error("the global logger should be returned without a logger in the context")
+	}
+	l := zap.NewNop()
+	if FromContext(NewContext(context.Background(), l)) != l {
+		t.Error("the logger in the context should be returned")
+	}
+}
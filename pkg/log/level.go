@@ -0,0 +1,137 @@
+package logpkg
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// packageLevels the levels of the named loggers, eg: Named("tunnel"),
+// the level of "tunnel" applies to "tunnel.backend" as well.
+var packageLevels = struct {
+	sync.RWMutex
+	levels map[string]zap.AtomicLevel
+}{levels: map[string]zap.AtomicLevel{}}
+
+// levelSnapshot the package levels read without the lock, it's replaced when a package is added or removed,
+// the levels changed by SetPackageLevel are shared since they are atomic.
+type levelSnapshot struct {
+	all      []zap.AtomicLevel
+	resolved sync.Map // the levels resolved by the logger names, string -> zapcore.LevelEnabler
+}
+
+var snapshot atomic.Value // *levelSnapshot
+
+func init() { snapshot.Store(&levelSnapshot{}) }
+
+// updateSnapshotLocked replace the snapshot after the packages change, the lock should be held
+func updateSnapshotLocked() {
+	snap := &levelSnapshot{all: make([]zap.AtomicLevel, 0, len(packageLevels.levels))}
+	for _, atomicLevel := range packageLevels.levels {
+		snap.all = append(snap.all, atomicLevel)
+	}
+	snapshot.Store(snap)
+}
+
+// Named create a child logger of the global logger for a package,
+// whose level can be set by SetPackageLevel
+func Named(name string) *zap.Logger { return getLogger().Named(name) }
+
+// SetPackageLevel change the minimum enabled log level of a package at runtime
+func SetPackageLevel(name, l string) error {
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(l)); err != nil {
+		return err
+	}
+
+	packageLevels.Lock()
+	defer packageLevels.Unlock()
+	if atomicLevel, ok := packageLevels.levels[name]; ok {
+		atomicLevel.SetLevel(lvl)
+	} else {
+		packageLevels.levels[name] = zap.NewAtomicLevelAt(lvl)
+		updateSnapshotLocked()
+	}
+	return nil
+}
+
+// ResetPackageLevels remove the levels of all packages, they use the global level then
+func ResetPackageLevels() {
+	packageLevels.Lock()
+	packageLevels.levels = map[string]zap.AtomicLevel{}
+	updateSnapshotLocked()
+	packageLevels.Unlock()
+}
+
+// GetPackageLevels get the levels of the packages which have their own levels
+func GetPackageLevels() map[string]string {
+	packageLevels.RLock()
+	defer packageLevels.RUnlock()
+	levels := make(map[string]string, len(packageLevels.levels))
+	for name, atomicLevel := range packageLevels.levels {
+		levels[name] = atomicLevel.Level().String()
+	}
+	return levels
+}
+
+// levelOf get the level of a logger by the longest matched package name,
+// it's resolved once for each name until the packages change
+func levelOf(name string) zapcore.LevelEnabler {
+	snap := snapshot.Load().(*levelSnapshot)
+	if len(snap.all) == 0 {
+		return level
+	}
+	if enab, ok := snap.resolved.Load(name); ok {
+		return enab.(zapcore.LevelEnabler)
+	}
+
+	packageLevels.RLock()
+	defer packageLevels.RUnlock()
+	// the snapshot loaded under the lock matches the packages
+	snap = snapshot.Load().(*levelSnapshot)
+	var (
+		enab    zapcore.LevelEnabler = level
+		matched                      = -1
+	)
+	for pkg, atomicLevel := range packageLevels.levels {
+		if len(pkg) > matched && (name == pkg || strings.HasPrefix(name, pkg+".")) {
+			enab, matched = atomicLevel, len(pkg)
+		}
+	}
+	snap.resolved.Store(name, enab)
+	return enab
+}
+
+// enabledAny check whether the level is enabled by any package
+func enabledAny(lvl zapcore.Level) bool {
+	if level.Enabled(lvl) {
+		return true
+	}
+	for _, atomicLevel := range snapshot.Load().(*levelSnapshot).all {
+		if atomicLevel.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+// levelCore filter the entries by the levels of their packages
+type levelCore struct {
+	zapcore.Core
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool { return enabledAny(lvl) }
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{c.Core.With(fields)}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !levelOf(ent.LoggerName).Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
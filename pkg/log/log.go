@@ -1,47 +1,30 @@
 package logpkg
 
 import (
-	"flag"
+	"io"
 	"log"
-	"os"
-	"path/filepath"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/lestrrat-go/file-rotatelogs"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-
-	"github.com/overtalk/bgo/utils/file"
 )
 
-func init() {
-	flag.IntVar(&rotationHour, "r", 1, "log rotation hour")
-	flag.IntVar(&maxKeepHour, "max", 24*7, "log max keep hour")
-	flag.BoolVar(&useJsonFormat, "json", false, "log format json")
-	flag.BoolVar(&openConsole, "console", true, "show log in console")
-	flag.StringVar(&logFilePath, "logpath", "", "log save dir")
-	flag.StringVar(&logLevel, "loglevel", "debug", "log level {debug|info|error|fatal}")
-	flag.StringVar(&logFilePrefix, "prefix", "default", "log prefix")
-}
-
 var (
-	once   sync.Once
-	logger *zap.Logger
-
-	logFilePrefix string
-	logLevel      string
-	useJsonFormat bool   // weather to use json format
-	logFilePath   string // log file path
-	openConsole   bool   // open the log in console
-	maxKeepHour   int
-	rotationHour  int
+	once    sync.Once
+	current atomic.Value // *zap.Logger
+	// sinks the sinks built by the last Init, the global logger writes to them by a rootCore
+	sinks atomic.Value // *rootSinks
+	// level the minimum enabled level of the packages without their own levels, it can be changed at runtime
+	level = zap.NewAtomicLevel()
 
 	encoderConfig = zapcore.EncoderConfig{
 		MessageKey:  "msg",
 		LevelKey:    "level",
+		NameKey:     "logger",
 		EncodeLevel: zapcore.CapitalLevelEncoder,
+		EncodeName:  zapcore.FullNameEncoder,
 		TimeKey:     "ts",
 		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 			enc.AppendString(t.Format("2006-01-02 15:04:05"))
@@ -54,64 +37,118 @@ var (
 	}
 )
 
-func BuildLogger() {
-	var encoder zapcore.Encoder
-
-	if useJsonFormat {
-		encoder = zapcore.NewJSONEncoder(encoderConfig)
-	} else {
-		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+// Init build the sinks with the config and replace the zap globals with the global logger,
+// the loggers created from the global logger before, eg: by With, write to the new sinks as well.
+// the old sinks are closed after they're replaced, eg: the remote sinks stop shipping.
+func Init(cfg *Config) error {
+	core, closers, err := build(cfg)
+	if err != nil {
+		return err
 	}
-
-	var minLevel zapcore.Level
-	if err := minLevel.UnmarshalText([]byte(logLevel)); err != nil {
-		log.Fatalf("failed to parse log level : %s\n", logLevel)
+	old, _ := sinks.Load().(*rootSinks)
+	sinks.Store(&rootSinks{core: core, closers: closers})
+	ReplaceLogger(newRootLogger())
+	if old != nil {
+		old.close()
 	}
+	return nil
+}
 
-	level := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= minLevel
-	})
+// ReplaceLogger replace the global logger and the zap globals, eg: an observer logger in tests
+func ReplaceLogger(l *zap.Logger) {
+	once.Do(func() {})
+	current.Store(l)
+	zap.ReplaceGlobals(l)
+}
 
-	var cores []zapcore.Core
-	if len(logFilePath) > 0 {
-		if !fileutil.PathExists(logFilePath) {
-			panic("log path " + logFilePath + " is absent")
-		}
-		if !fileutil.IsDir(logFilePath) {
-			panic("log path " + logFilePath + " should be a dir")
-		}
+// SetLevel change the minimum enabled log level at runtime, {debug|info|warn|error|dpanic|panic|fatal}
+func SetLevel(l string) error {
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(l)); err != nil {
+		return err
+	}
+	level.SetLevel(lvl)
+	return nil
+}
+
+// GetLevel get the minimum enabled log level
+func GetLevel() string { return level.Level().String() }
 
-		logFilePath = filepath.Join(logFilePath, logFilePrefix+".log")
+// GetAtomicLevel get the minimum enabled log level of the packages without their own levels
+func GetAtomicLevel() zap.AtomicLevel { return level }
 
-		hook, err := rotatelogs.New(
-			strings.Replace(logFilePath, ".log", "", -1)+"-%Y%m%d%H.log",
-			rotatelogs.WithLinkName(logFilePath),
-			rotatelogs.WithMaxAge(time.Duration(maxKeepHour)*time.Hour),
-			rotatelogs.WithRotationTime(time.Duration(rotationHour)*time.Hour),
-		)
+func getLogger() *zap.Logger {
+	once.Do(func() {
+		core, closers, err := build(&Config{})
 		if err != nil {
 			log.Fatalf("failed to build logger : %v\n", err)
 		}
+		sinks.Store(&rootSinks{core: core, closers: closers})
+		logger := newRootLogger()
+		current.Store(logger)
+		zap.ReplaceGlobals(logger)
+	})
+	return current.Load().(*zap.Logger)
+}
 
-		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(hook), level))
+// rootSinks the core of the sinks and their closers
+type rootSinks struct {
+	core    zapcore.Core
+	closers []io.Closer
+}
+
+func (s *rootSinks) close() {
+	s.core.Sync()
+	for _, closer := range s.closers {
+		closer.Close()
 	}
+}
 
-	if openConsole || len(cores) == 0 {
-		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), level))
+func newRootLogger() *zap.Logger { return zap.New(&levelCore{&rootCore{}}, zap.AddCaller()) }
+
+// rootCore a core writing to the sinks built by the last Init, its fields are added to the new sinks
+// once they're replaced, so the loggers created at any time are reached by the Init of a reload
+type rootCore struct {
+	fields []zapcore.Field
+	cache  atomic.Value // *rootCoreCache
+}
+
+// rootCoreCache the core of the sinks with the fields of a rootCore
+type rootCoreCache struct {
+	sinks *rootSinks
+	core  zapcore.Core
+}
+
+func (c *rootCore) current() zapcore.Core {
+	s := sinks.Load().(*rootSinks)
+	if cache, ok := c.cache.Load().(*rootCoreCache); ok && cache.sinks == s {
+		return cache.core
+	}
+	core := s.core
+	if len(c.fields) > 0 {
+		core = core.With(c.fields)
 	}
+	c.cache.Store(&rootCoreCache{sinks: s, core: core})
+	return core
+}
+
+func (c *rootCore) Enabled(lvl zapcore.Level) bool { return c.current().Enabled(lvl) }
 
-	logger = zap.New(zapcore.NewTee(cores...), zap.AddCaller())
+func (c *rootCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	return &rootCore{fields: append(append(all, c.fields...), fields...)}
 }
 
-func getLogger() *zap.Logger {
-	once.Do(func() {
-		if logger == nil {
-			BuildLogger()
-		}
-	})
-	return logger
+func (c *rootCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(ent, ce)
+}
+
+func (c *rootCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(ent, fields)
 }
 
+func (c *rootCore) Sync() error { return c.current().Sync() }
+
 func Debug(msg string, fields ...zapcore.Field) {
 	getLogger().WithOptions(zap.AddCallerSkip(1)).With(fields...).Debug(msg)
 }
@@ -0,0 +1,165 @@
+package logpkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPackageLevel(t *testing.T) {
+	defer ResetPackageLevels()
+	defer SetLevel("debug")
+
+	observed, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(&levelCore{observed})
+	SetLevel("info")
+	if err := SetPackageLevel("tunnel", "error"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPackageLevel("route", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPackageLevel("route", "bad"); err == nil {
+		t.Error("bad level should fail")
+	}
+
+	logger.Debug("root debug")
+	logger.Info("root info")
+	logger.Named("tunnel").Info("tunnel info")
+	logger.Named("tunnel").Named("backend").Error("tunnel error")
+	logger.Named("route").Debug("route debug")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	if got := strings.Join(messages, ","); got != "root info,tunnel error,route debug" {
+		t.Errorf("messages = %s", got)
+	}
+	if levels := GetPackageLevels(); levels["tunnel"] != "error" || levels["route"] != "debug" {
+		t.Errorf("package levels = %v", levels)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	defer ResetPackageLevels()
+	defer SetLevel("debug")
+
+	dir, err := ioutil.TempDir("", "bgo-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger, err := Build(&Config{
+		Level:    "info",
+		Packages: []PackageConfig{{Name: "hot", Level: "debug"}},
+		Sinks: []SinkConfig{
+			{Name: "all", Type: SinkFile, Encoding: EncodingJSON, Path: dir, Prefix: "all"},
+			{Name: "error", Type: SinkFile, Path: dir, Prefix: "error", Level: "error"},
+		},
+		Sampling: SamplingConfig{Initial: 2, Tick: "1m"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		logger.Named("hot").Debug("hot path")
+	}
+	logger.Debug("dropped")
+	logger.Error("failed")
+	logger.Sync()
+
+	all, err := ioutil.ReadFile(filepath.Join(dir, "all.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(all), "hot path"); n != 2 {
+		t.Errorf("sampled entries = %d, want 2", n)
+	}
+	if strings.Contains(string(all), "dropped") || !strings.Contains(string(all), `"msg":"failed"`) {
+		t.Errorf("all.log = %s", all)
+	}
+	errors, err := ioutil.ReadFile(filepath.Join(dir, "error.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(errors), "hot path") || !strings.Contains(string(errors), "failed") {
+		t.Errorf("error.log = %s", errors)
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	for _, cfg := range []*Config{
+		{Level: "bad"},
+		{Sinks: []SinkConfig{{Name: "a"}, {Name: "a"}}},
+		{Sinks: []SinkConfig{{Name: "a", Type: SinkFile, Path: "/not/exist"}}},
+		{Sinks: []SinkConfig{{Name: "a", Encoding: "xml"}}},
+	} {
+		if _, err := Build(cfg); err == nil {
+			t.Errorf("build %+v should fail", cfg)
+		}
+	}
+}
+
+func TestPackageLevelResolved(t *testing.T) {
+	defer ResetPackageLevels()
+
+	if levelOf("tunnel.backend") != level {
+		t.Error("the global level should be used without package levels")
+	}
+	SetPackageLevel("tunnel", "error")
+	if levelOf("tunnel.backend").Enabled(zapcore.WarnLevel) {
+		t.Error("the level of tunnel should apply to tunnel.backend")
+	}
+	// the resolved level is replaced once a longer package is added
+	SetPackageLevel("tunnel.backend", "debug")
+	if !levelOf("tunnel.backend").Enabled(zapcore.DebugLevel) {
+		t.Error("the level of tunnel.backend should be used")
+	}
+	SetPackageLevel("tunnel.backend", "warn")
+	if levelOf("tunnel.backend").Enabled(zapcore.InfoLevel) {
+		t.Error("the changed level of tunnel.backend should be used")
+	}
+}
+
+func TestInitReload(t *testing.T) {
+	defer Init(&Config{})
+
+	var dirs []string
+	for i := 0; i < 2; i++ {
+		dir, err := ioutil.TempDir("", "bgo-log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		dirs = append(dirs, dir)
+	}
+
+	if err := Init(&Config{Sinks: []SinkConfig{{Name: "file", Type: SinkFile, Path: dirs[0]}}}); err != nil {
+		t.Fatal(err)
+	}
+	// eg: a session logger created at connect time
+	logger := Named("session").With(zap.Int("conn_id", 7))
+	logger.Info("before")
+	if err := Init(&Config{Sinks: []SinkConfig{{Name: "file", Type: SinkFile, Path: dirs[1]}}}); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after")
+	logger.Sync()
+
+	before, _ := ioutil.ReadFile(filepath.Join(dirs[0], "default.log"))
+	after, _ := ioutil.ReadFile(filepath.Join(dirs[1], "default.log"))
+	if !strings.Contains(string(before), "before") || strings.Contains(string(before), "after") {
+		t.Errorf("old sink = %s", before)
+	}
+	if !strings.Contains(string(after), "after") || !strings.Contains(string(after), "conn_id") {
+		t.Errorf("new sink = %s", after)
+	}
+}
@@ -0,0 +1,513 @@
+package logpkg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// formats of the remote sink
+const (
+	FormatNDJSON  = "ndjson"
+	FormatRFC5424 = "rfc5424"
+)
+
+const (
+	remoteDialTimeout   = 3 * time.Second
+	remoteWriteTimeout  = 5 * time.Second
+	remoteRetryInterval = time.Second
+	remoteSyncTimeout   = 5 * time.Second
+	// the size of the spilled entries shipped in a write
+	remoteReplayChunk = 64 << 10
+	// facility local0 of syslog
+	syslogFacility = 16
+)
+
+var errCollectorDown = errors.New("log collector is unreachable")
+
+// remoteEntry an encoded entry waiting to be shipped
+type remoteEntry struct {
+	level zapcore.Level
+	data  []byte
+}
+
+// remoteSink ship the entries to a remote collector in batches, the entries are spilled to
+// a bounded file when the collector is unreachable, and shipped again after reconnecting.
+// when the queue is full, the debug entries are dropped first.
+type remoteSink struct {
+	network       string
+	address       string
+	format        string
+	appName       string
+	hostname      string
+	pid           int
+	batchSize     int
+	flushInterval time.Duration
+	queueSize     int
+	spillFile     string // empty if spilling is disabled
+	replayFile    string // the spill file is moved to it to be shipped
+	spillMax      int64
+
+	lock       sync.Mutex
+	queue      []remoteEntry
+	spillSize  int64
+	replaySize int64 // the size of the entries in the replay file which aren't shipped
+
+	// used by the shipping goroutine only
+	conn         net.Conn
+	retryAt      time.Time
+	replayOffset int64
+
+	dropped   uint64
+	notify    chan struct{}
+	flushReq  chan chan struct{}
+	closeChan chan struct{}
+	closeOnce sync.Once
+	exited    chan struct{}
+}
+
+func newRemoteSink(sink SinkConfig) (*remoteSink, error) {
+	if len(sink.Address) == 0 {
+		return nil, errors.New("address of the remote sink is required")
+	}
+	s := &remoteSink{
+		network:   sink.Network,
+		address:   sink.Address,
+		format:    sink.Format,
+		appName:   sink.Prefix,
+		pid:       os.Getpid(),
+		batchSize: sink.BatchSize,
+		queueSize: sink.QueueSize,
+		spillMax:  int64(sink.SpillMaxMB) << 20,
+		notify:    make(chan struct{}, 1),
+		flushReq:  make(chan chan struct{}),
+		closeChan: make(chan struct{}),
+		exited:    make(chan struct{}),
+	}
+	switch s.network {
+	case "":
+		s.network = "tcp"
+	case "tcp", "udp":
+	default:
+		return nil, fmt.Errorf("unknown network %q", s.network)
+	}
+	switch s.format {
+	case "":
+		s.format = FormatNDJSON
+	case FormatNDJSON, FormatRFC5424:
+	default:
+		return nil, fmt.Errorf("unknown format %q", s.format)
+	}
+	if len(s.appName) == 0 {
+		s.appName = "default"
+	}
+	if s.hostname, _ = os.Hostname(); len(s.hostname) == 0 {
+		s.hostname = "-"
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 100
+	}
+	if s.queueSize <= 0 {
+		s.queueSize = 10000
+	}
+	s.flushInterval = time.Second
+	if len(sink.FlushInterval) > 0 {
+		d, err := time.ParseDuration(sink.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("flush interval : %v", err)
+		}
+		s.flushInterval = d
+	}
+
+	if len(sink.SpillPath) > 0 {
+		if s.spillMax <= 0 {
+			s.spillMax = 64 << 20
+		}
+		if err := os.MkdirAll(sink.SpillPath, 0755); err != nil {
+			return nil, err
+		}
+		s.spillFile = filepath.Join(sink.SpillPath, sink.Name+".spill")
+		s.replayFile = s.spillFile + ".replay"
+		// the entries spilled by the last run are shipped as well
+		if info, err := os.Stat(s.spillFile); err == nil {
+			s.spillSize = info.Size()
+		}
+		if info, err := os.Stat(s.replayFile); err == nil {
+			s.replaySize = info.Size()
+		}
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Dropped get the number of the dropped entries
+func (s *remoteSink) Dropped() uint64 { return atomic.LoadUint64(&s.dropped) }
+
+// encode format the entry encoded in json
+func (s *remoteSink) encode(ent zapcore.Entry, line []byte) []byte {
+	if s.format == FormatNDJSON {
+		return append([]byte(nil), line...)
+	}
+
+	// RFC5424: <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - - ",
+		syslogFacility*8+syslogSeverity(ent.Level),
+		ent.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, s.appName, s.pid)
+	buf.Write(line)
+	return buf.Bytes()
+}
+
+func syslogSeverity(l zapcore.Level) int {
+	switch l {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	}
+	return 2
+}
+
+// enqueue add an entry to the queue, the debug entries are dropped first if it's full,
+// and the oldest entry is spilled or dropped if there is no debug entry.
+func (s *remoteSink) enqueue(entry remoteEntry) {
+	s.lock.Lock()
+	if len(s.queue) >= s.queueSize {
+		if entry.level == zapcore.DebugLevel {
+			s.lock.Unlock()
+			atomic.AddUint64(&s.dropped, 1)
+			return
+		}
+		index := -1
+		for i := range s.queue {
+			if s.queue[i].level == zapcore.DebugLevel {
+				index = i
+				break
+			}
+		}
+		if index >= 0 {
+			s.queue = append(s.queue[:index], s.queue[index+1:]...)
+			atomic.AddUint64(&s.dropped, 1)
+		} else {
+			s.spillLocked(s.queue[:1])
+			s.queue = s.queue[1:]
+		}
+	}
+	s.queue = append(s.queue, entry)
+	full := len(s.queue) >= s.batchSize
+	s.lock.Unlock()
+
+	if full {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// spillLocked append the entries to the spill file, the debug entries are dropped first
+// if the file is full. the lock should be held.
+func (s *remoteSink) spillLocked(entries []remoteEntry) {
+	if len(s.spillFile) == 0 {
+		atomic.AddUint64(&s.dropped, uint64(len(entries)))
+		return
+	}
+	f, err := os.OpenFile(s.spillFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		atomic.AddUint64(&s.dropped, uint64(len(entries)))
+		return
+	}
+	defer f.Close()
+	// the file may be appended by the sink replaced by a reload as well
+	if info, err := f.Stat(); err == nil {
+		s.spillSize = info.Size()
+	}
+
+	var size int64
+	for _, entry := range entries {
+		size += int64(len(entry.data))
+	}
+	limit := s.spillMax - s.replaySize
+	if s.spillSize+size > limit {
+		kept := make([]remoteEntry, 0, len(entries))
+		size = 0
+		for _, entry := range entries {
+			if entry.level != zapcore.DebugLevel {
+				kept = append(kept, entry)
+				size += int64(len(entry.data))
+			}
+		}
+		entries = kept
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		if s.spillSize+int64(buf.Len()+len(entry.data)) > limit {
+			atomic.AddUint64(&s.dropped, 1)
+			continue
+		}
+		buf.Write(entry.data)
+	}
+	if buf.Len() == 0 {
+		return
+	}
+	n, _ := f.Write(buf.Bytes())
+	s.spillSize += int64(n)
+}
+
+// connect dial the collector and ship the spilled entries first
+func (s *remoteSink) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+	if time.Now().Before(s.retryAt) {
+		return errCollectorDown
+	}
+	conn, err := net.DialTimeout(s.network, s.address, remoteDialTimeout)
+	if err != nil {
+		s.retryAt = time.Now().Add(remoteRetryInterval)
+		return err
+	}
+	s.conn = conn
+
+	// the spill file is moved away under the lock, and shipped without it,
+	// so the logging isn't blocked while shipping
+	s.lock.Lock()
+	if s.replaySize == 0 && s.spillSize > 0 {
+		if err := os.Rename(s.spillFile, s.replayFile); err == nil {
+			s.replaySize, s.spillSize, s.replayOffset = s.spillSize, 0, 0
+		}
+	}
+	pending := s.replaySize > 0
+	s.lock.Unlock()
+	if !pending {
+		return nil
+	}
+	if err := s.replay(); err != nil {
+		s.disconnect()
+		return err
+	}
+	return nil
+}
+
+// replay ship the entries in the replay file in chunks, it's continued from the
+// last shipped entry after reconnecting if it fails
+func (s *remoteSink) replay() error {
+	f, err := os.Open(s.replayFile)
+	if err == nil {
+		_, err = f.Seek(s.replayOffset, io.SeekStart)
+	}
+	if err != nil {
+		if f != nil {
+			f.Close()
+		}
+		s.finishReplay()
+		return nil
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, remoteReplayChunk)
+	for {
+		var (
+			lines [][]byte
+			size  int
+			err   error
+		)
+		for size < remoteReplayChunk && err == nil {
+			var line []byte
+			if line, err = reader.ReadBytes('\n'); len(line) > 0 {
+				lines = append(lines, line)
+				size += len(line)
+			}
+		}
+		if len(lines) > 0 {
+			if err := s.write(lines); err != nil {
+				return err
+			}
+			s.replayOffset += int64(size)
+			s.lock.Lock()
+			s.replaySize -= int64(size)
+			s.lock.Unlock()
+		}
+		if err != nil {
+			// all entries are shipped, or the rest can't be read
+			s.finishReplay()
+			return nil
+		}
+	}
+}
+
+func (s *remoteSink) finishReplay() {
+	os.Remove(s.replayFile)
+	s.replayOffset = 0
+	s.lock.Lock()
+	s.replaySize = 0
+	s.lock.Unlock()
+}
+
+func (s *remoteSink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.retryAt = time.Now().Add(remoteRetryInterval)
+}
+
+// write write the lines in a batch by tcp, or one datagram for each line by udp
+func (s *remoteSink) write(lines [][]byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(remoteWriteTimeout))
+	if s.network == "udp" {
+		for _, line := range lines {
+			if len(line) == 0 {
+				continue
+			}
+			if _, err := s.conn.Write(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, err := s.conn.Write(bytes.Join(lines, nil))
+	return err
+}
+
+// flush ship all entries in the queue, the entries are spilled if the collector is unreachable
+func (s *remoteSink) flush() {
+	for {
+		s.lock.Lock()
+		n := len(s.queue)
+		if n == 0 {
+			s.lock.Unlock()
+			return
+		}
+		if n > s.batchSize {
+			n = s.batchSize
+		}
+		batch := make([]remoteEntry, n)
+		copy(batch, s.queue[:n])
+		s.queue = s.queue[n:]
+		s.lock.Unlock()
+
+		err := s.connect()
+		if err == nil {
+			lines := make([][]byte, len(batch))
+			for i, entry := range batch {
+				lines[i] = entry.data
+			}
+			if err = s.write(lines); err != nil {
+				s.disconnect()
+			}
+		}
+		if err != nil {
+			s.lock.Lock()
+			s.spillLocked(batch)
+			s.lock.Unlock()
+		}
+	}
+}
+
+func (s *remoteSink) run() {
+	defer close(s.exited)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.notify:
+			s.flush()
+		case <-ticker.C:
+			s.flush()
+		case done := <-s.flushReq:
+			s.flush()
+			close(done)
+		case <-s.closeChan:
+			s.flush()
+			if s.conn != nil {
+				s.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// Sync ship all entries in the queue
+func (s *remoteSink) Sync() error {
+	done := make(chan struct{})
+	select {
+	case s.flushReq <- done:
+	case <-s.exited:
+		return nil
+	case <-time.After(remoteSyncTimeout):
+		return errors.New("sync remote log sink timeout")
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(remoteSyncTimeout):
+		return errors.New("sync remote log sink timeout")
+	}
+}
+
+// Close ship all entries in the queue and stop shipping
+func (s *remoteSink) Close() error {
+	s.closeOnce.Do(func() { close(s.closeChan) })
+	<-s.exited
+	return nil
+}
+
+// remoteCore a zapcore.Core writing to the remoteSink
+type remoteCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	sink *remoteSink
+}
+
+func newRemoteCore(sink *remoteSink, enab zapcore.LevelEnabler) zapcore.Core {
+	return &remoteCore{LevelEnabler: enab, enc: zapcore.NewJSONEncoder(encoderConfig), sink: sink}
+}
+
+func (c *remoteCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	return &remoteCore{LevelEnabler: c.LevelEnabler, enc: enc, sink: c.sink}
+}
+
+func (c *remoteCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *remoteCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	c.sink.enqueue(remoteEntry{level: ent.Level, data: c.sink.encode(ent, buf.Bytes())})
+	buf.Free()
+	if ent.Level > zapcore.ErrorLevel {
+		// the process may exit, see zapcore.ioCore
+		c.sink.Sync()
+	}
+	return nil
+}
+
+func (c *remoteCore) Sync() error { return c.sink.Sync() }
@@ -0,0 +1,220 @@
+package logpkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestRemote(t *testing.T, sink SinkConfig) (*remoteSink, *zap.Logger) {
+	sink.Name, sink.Type = "remote", SinkRemote
+	remote, err := newRemoteSink(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return remote, zap.New(newRemoteCore(remote, zapcore.DebugLevel))
+}
+
+// readLines read n lines from the first connection of the listener
+func readLines(t *testing.T, l net.Listener, n int) []string {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	for len(lines) < n && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != n {
+		t.Fatalf("lines = %v, err = %v", lines, scanner.Err())
+	}
+	return lines
+}
+
+func TestRemoteTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	remote, logger := newTestRemote(t, SinkConfig{Address: l.Addr().String(), BatchSize: 2, FlushInterval: "10ms"})
+	defer remote.Close()
+	logger.Info("first", zap.Int("n", 1))
+	logger.With(zap.String(KeyTraceID, "abc")).Warn("second")
+	logger.Sync()
+
+	lines := readLines(t, l, 2)
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "second" || entry[KeyTraceID] != "abc" || entry["level"] != "WARN" {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
+func TestRemoteUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	remote, logger := newTestRemote(t, SinkConfig{Network: "udp", Address: conn.LocalAddr().String(), Format: FormatRFC5424, Prefix: "bgo"})
+	defer remote.Close()
+	logger.Info("hello")
+	logger.Sync()
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0.info
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<134>1 ") || !strings.Contains(msg, " bgo ") || !strings.Contains(msg, `"msg":"hello"`) {
+		t.Errorf("message = %s", msg)
+	}
+}
+
+func TestRemoteSpill(t *testing.T) {
+	// a free address without a collector
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	dir, err := ioutil.TempDir("", "bgo-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	remote, logger := newTestRemote(t, SinkConfig{Address: address, SpillPath: dir, SpillMaxMB: 1})
+	defer remote.Close()
+	logger.Info("spilled")
+	logger.Sync()
+	if remote.spillSize == 0 {
+		t.Fatal("the entry should be spilled")
+	}
+
+	// the collector is back
+	if l, err = net.Listen("tcp", address); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	time.Sleep(remoteRetryInterval)
+	logger.Info("shipped")
+	logger.Sync()
+
+	lines := readLines(t, l, 2)
+	if !strings.Contains(lines[0], "spilled") || !strings.Contains(lines[1], "shipped") {
+		t.Errorf("lines = %v", lines)
+	}
+	remote.Sync()
+	if _, err := os.Stat(remote.replayFile); !os.IsNotExist(err) {
+		t.Errorf("replay file should be removed : %v", err)
+	}
+}
+
+func TestRemoteBackPressure(t *testing.T) {
+	remote, _ := newTestRemote(t, SinkConfig{Address: "127.0.0.1:1", QueueSize: 2, FlushInterval: "1h"})
+	defer remote.Close()
+
+	remote.enqueue(remoteEntry{level: zapcore.DebugLevel, data: []byte("debug\n")})
+	remote.enqueue(remoteEntry{level: zapcore.InfoLevel, data: []byte("info\n")})
+	// the queued debug entry is dropped
+	remote.enqueue(remoteEntry{level: zapcore.ErrorLevel, data: []byte("error\n")})
+	// the new debug entry is dropped
+	remote.enqueue(remoteEntry{level: zapcore.DebugLevel, data: []byte("debug\n")})
+
+	remote.lock.Lock()
+	queued := len(remote.queue)
+	first := string(remote.queue[0].data)
+	remote.lock.Unlock()
+	if queued != 2 || first != "info\n" || remote.Dropped() != 2 {
+		t.Errorf("queued = %d, first = %q, dropped = %d", queued, first, remote.Dropped())
+	}
+}
+
+func TestRemoteReplayUnlocked(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	dir, err := ioutil.TempDir("", "bgo-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the entries spilled by the last run, more than the socket buffers
+	line := strings.Repeat("x", 1023) + "\n"
+	spilled := strings.Repeat(line, 4<<10)
+	if err := ioutil.WriteFile(filepath.Join(dir, "remote.spill"), []byte(spilled), 0644); err != nil {
+		t.Fatal(err)
+	}
+	remote, logger := newTestRemote(t, SinkConfig{Address: l.Addr().String(), SpillPath: dir, FlushInterval: "10ms"})
+	defer remote.Close()
+	logger.Info("connect")
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the collector doesn't read, the logging isn't blocked by the replay
+	time.Sleep(50 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		logger.Info("during replay")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("logging is blocked by the replay")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	data, _ := ioutil.ReadAll(io.LimitReader(conn, int64(len(spilled))))
+	if string(data) != spilled {
+		t.Errorf("replayed %d bytes, want %d", len(data), len(spilled))
+	}
+}
+
+func TestRemoteClosedByInit(t *testing.T) {
+	defer Init(&Config{})
+
+	if err := Init(&Config{Sinks: []SinkConfig{{Name: "remote", Type: SinkRemote, Address: "127.0.0.1:1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	remote := sinks.Load().(*rootSinks).closers[0].(*remoteSink)
+	if err := Init(&Config{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-remote.exited:
+	default:
+		t.Error("the replaced remote sink should be closed")
+	}
+}
@@ -0,0 +1,309 @@
+package metricspkg
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefBuckets the default buckets of histograms in seconds
+var DefBuckets = []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// desc the description of a metric
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (d *desc) Name() string { return d.name }
+
+func (d *desc) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+	return err
+}
+
+// writeSample write a sample line, extra is an additional label like `le="0.5"`
+func (d *desc) writeSample(w io.Writer, suffix string, labelValues []string, extra string, value float64) error {
+	var labels []string
+	for i, name := range d.labelNames {
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, name, escapeLabel(labelValues[i])))
+	}
+	if len(extra) > 0 {
+		labels = append(labels, extra)
+	}
+
+	var err error
+	if len(labels) > 0 {
+		_, err = fmt.Fprintf(w, "%s%s{%s} %s\n", d.name, suffix, strings.Join(labels, ","), formatFloat(value))
+	} else {
+		_, err = fmt.Fprintf(w, "%s%s %s\n", d.name, suffix, formatFloat(value))
+	}
+	return err
+}
+
+// vec the children of a metric with different label values
+type vec struct {
+	desc
+	lock     sync.RWMutex
+	children map[string]interface{}
+	values   map[string][]string
+	newChild func() interface{}
+}
+
+func newVec(d desc, newChild func() interface{}) vec {
+	return vec{desc: d, children: make(map[string]interface{}), values: make(map[string][]string), newChild: newChild}
+}
+
+func (v *vec) with(labelValues []string) interface{} {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.lock.RLock()
+	child, ok := v.children[key]
+	v.lock.RUnlock()
+	if ok {
+		return child
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if child, ok = v.children[key]; !ok {
+		child = v.newChild()
+		v.children[key] = child
+		v.values[key] = append([]string(nil), labelValues...)
+	}
+	return child
+}
+
+// each call fn for each child ordered by label values
+func (v *vec) each(fn func(labelValues []string, child interface{}) error) error {
+	v.lock.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	v.lock.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.lock.RLock()
+		child, values := v.children[key], v.values[key]
+		v.lock.RUnlock()
+		if err := fn(values, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value an atomic float64
+type value struct {
+	bits uint64
+}
+
+func (v *value) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		if atomic.CompareAndSwapUint64(&v.bits, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) Set(f float64) { atomic.StoreUint64(&v.bits, math.Float64bits(f)) }
+func (v *value) Get() float64  { return math.Float64frombits(atomic.LoadUint64(&v.bits)) }
+
+// Counter a monotonically increasing value
+type Counter struct{ value }
+
+func (c *Counter) Inc() { c.value.Add(1) }
+
+// Add add a non-negative delta
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.value.Add(delta)
+}
+
+// CounterVec counters partitioned by label values
+type CounterVec struct{ vec }
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newVec(desc{name, help, typeCounter, labelNames}, func() interface{} { return &Counter{} })}
+}
+
+// With get the counter of the label values
+func (v *CounterVec) With(labelValues ...string) *Counter { return v.with(labelValues).(*Counter) }
+
+func (v *CounterVec) Write(w io.Writer) error {
+	if err := v.writeHeader(w); err != nil {
+		return err
+	}
+	return v.each(func(labelValues []string, child interface{}) error {
+		return v.writeSample(w, "", labelValues, "", child.(*Counter).Get())
+	})
+}
+
+// Gauge a value which can go up and down
+type Gauge struct{ value }
+
+func (g *Gauge) Inc() { g.value.Add(1) }
+func (g *Gauge) Dec() { g.value.Add(-1) }
+
+// GaugeVec gauges partitioned by label values
+type GaugeVec struct{ vec }
+
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVec(desc{name, help, typeGauge, labelNames}, func() interface{} { return &Gauge{} })}
+}
+
+// With get the gauge of the label values
+func (v *GaugeVec) With(labelValues ...string) *Gauge { return v.with(labelValues).(*Gauge) }
+
+func (v *GaugeVec) Write(w io.Writer) error {
+	if err := v.writeHeader(w); err != nil {
+		return err
+	}
+	return v.each(func(labelValues []string, child interface{}) error {
+		return v.writeSample(w, "", labelValues, "", child.(*Gauge).Get())
+	})
+}
+
+// Histogram counts the observations in buckets
+type Histogram struct {
+	upperBounds []float64
+	counts      []uint64 // count of each bucket, not cumulative
+	count       uint64
+	sum         value
+}
+
+// Observe add an observation
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	atomic.AddUint64(&h.count, 1)
+	h.sum.Add(v)
+}
+
+// ObserveSince observe the duration since start in seconds
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// HistogramVec histograms partitioned by label values
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec create a HistogramVec, DefBuckets is used if buckets is nil
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{
+		vec: newVec(desc{name, help, typeHistogram, labelNames}, func() interface{} {
+			return &Histogram{upperBounds: buckets, counts: make([]uint64, len(buckets))}
+		}),
+		buckets: buckets,
+	}
+}
+
+// With get the histogram of the label values
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.with(labelValues).(*Histogram)
+}
+
+func (v *HistogramVec) Write(w io.Writer) error {
+	if err := v.writeHeader(w); err != nil {
+		return err
+	}
+	return v.each(func(labelValues []string, child interface{}) error {
+		h := child.(*Histogram)
+		var cumulative uint64
+		for i, bound := range h.upperBounds {
+			cumulative += atomic.LoadUint64(&h.counts[i])
+			le := fmt.Sprintf(`le="%s"`, formatFloat(bound))
+			if err := v.writeSample(w, "_bucket", labelValues, le, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		count := atomic.LoadUint64(&h.count)
+		if err := v.writeSample(w, "_bucket", labelValues, `le="+Inf"`, float64(count)); err != nil {
+			return err
+		}
+		if err := v.writeSample(w, "_sum", labelValues, "", h.sum.Get()); err != nil {
+			return err
+		}
+		return v.writeSample(w, "_count", labelValues, "", float64(count))
+	})
+}
+
+// FuncVec a counter or gauge whose samples are collected by a func at scrape time,
+// fn calls add for each sample.
+type FuncVec struct {
+	desc
+	fn func(add func(value float64, labelValues ...string))
+}
+
+func NewCounterFunc(name, help string, fn func(add func(value float64, labelValues ...string)), labelNames ...string) *FuncVec {
+	return &FuncVec{desc{name, help, typeCounter, labelNames}, fn}
+}
+
+func NewGaugeFunc(name, help string, fn func(add func(value float64, labelValues ...string)), labelNames ...string) *FuncVec {
+	return &FuncVec{desc{name, help, typeGauge, labelNames}, fn}
+}
+
+func (v *FuncVec) Write(w io.Writer) error {
+	if err := v.writeHeader(w); err != nil {
+		return err
+	}
+	var err error
+	v.fn(func(value float64, labelValues ...string) {
+		if err == nil && len(labelValues) == len(v.labelNames) {
+			err = v.writeSample(w, "", labelValues, "", value)
+		}
+	})
+	return err
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpReplacer.Replace(s) }
+func escapeLabel(s string) string { return labelReplacer.Replace(s) }
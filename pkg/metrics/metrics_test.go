@@ -0,0 +1,68 @@
+package metricspkg_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/metrics"
+)
+
+func TestWriteText(t *testing.T) {
+	registry := metricspkg.NewRegistry()
+	counter := metricspkg.NewCounterVec("test_requests_total", "Total requests.", "code")
+	gauge := metricspkg.NewGaugeVec("test_active", "Active conns.")
+	histogram := metricspkg.NewHistogramVec("test_seconds", "Latency.", []float64{0.1, 1}, "op")
+	fn := metricspkg.NewGaugeFunc("test_func", "Func.", func(add func(float64, ...string)) {
+		add(3, `a"b`)
+	}, "name")
+	registry.MustRegister(counter, gauge, histogram, fn)
+	if err := registry.Register(counter); err == nil {
+		t.Error("expect an error for duplicated metric")
+	}
+
+	counter.With("200").Inc()
+	counter.With("200").Add(2)
+	counter.With("500").Inc()
+	gauge.With().Inc()
+	gauge.With().Inc()
+	gauge.With().Dec()
+	histogram.With("get").Observe(0.05)
+	histogram.With("get").Observe(0.5)
+	histogram.With("get").Observe(5)
+
+	var buf bytes.Buffer
+	if err := registry.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := `# HELP test_active Active conns.
+# TYPE test_active gauge
+test_active 1
+# HELP test_func Func.
+# TYPE test_func gauge
+test_func{name="a\"b"} 3
+# HELP test_requests_total Total requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 3
+test_requests_total{code="500"} 1
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{op="get",le="0.1"} 1
+test_seconds_bucket{op="get",le="1"} 2
+test_seconds_bucket{op="get",le="+Inf"} 3
+test_seconds_sum{op="get"} 5.55
+test_seconds_count{op="get"} 3
+`
+	if got := buf.String(); got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s", got, expect)
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(err.(string), "label values") {
+			t.Errorf("expect a panic for wrong label count, got %v", err)
+		}
+	}()
+	metricspkg.NewCounterVec("test_total", "Test.", "a", "b").With("a")
+}
@@ -0,0 +1,88 @@
+package metricspkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Collector a metric which can be written in the Prometheus text format
+type Collector interface {
+	// Name get the metric name, it's unique in a registry
+	Name() string
+	// Write write the HELP, TYPE and samples of the metric
+	Write(w io.Writer) error
+}
+
+// Registry a set of collectors
+type Registry struct {
+	lock       sync.RWMutex
+	collectors map[string]Collector
+}
+
+// DefaultRegistry the registry used by all packages in bgo
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register register a collector, the name of which must be unique
+func (r *Registry) Register(c Collector) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.collectors[c.Name()]; ok {
+		return fmt.Errorf("metric %s is already registered", c.Name())
+	}
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// MustRegister register several collectors, it panics if any one fails
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister unregister a collector by its name
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	delete(r.collectors, name)
+	r.lock.Unlock()
+}
+
+// WriteText write all metrics in the Prometheus text format, ordered by their names
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.RLock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.lock.RUnlock()
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name() < collectors[j].Name() })
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		if err := c.Write(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler the http handler of the metrics endpoint
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// MustRegister register several collectors to the default registry
+func MustRegister(cs ...Collector) { DefaultRegistry.MustRegister(cs...) }
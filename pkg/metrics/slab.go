@@ -0,0 +1,44 @@
+package metricspkg
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/overtalk/bgo/3rdparty/slab"
+)
+
+var (
+	slabLock  sync.RWMutex
+	slabPools = make(map[string]slab.StatsPool)
+)
+
+func init() {
+	MustRegister(
+		NewCounterFunc("bgo_slab_allocs_total", "Total number of allocations of each slab pool.", collectSlab(func(s slab.Stats) uint64 { return s.Allocs }), "pool"),
+		NewCounterFunc("bgo_slab_misses_total", "Total number of allocations missing the free chunks of each slab pool.", collectSlab(func(s slab.Stats) uint64 { return s.Misses }), "pool"),
+	)
+}
+
+// RegisterSlabPool register a slab pool to report its alloc & miss counts, pools without stats are ignored
+func RegisterSlabPool(name string, pool slab.Pool) {
+	if p, ok := pool.(slab.StatsPool); ok {
+		slabLock.Lock()
+		slabPools[name] = p
+		slabLock.Unlock()
+	}
+}
+
+func collectSlab(field func(slab.Stats) uint64) func(add func(float64, ...string)) {
+	return func(add func(float64, ...string)) {
+		slabLock.RLock()
+		names := make([]string, 0, len(slabPools))
+		for name := range slabPools {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(float64(field(slabPools[name].Stats())), name)
+		}
+		slabLock.RUnlock()
+	}
+}
@@ -0,0 +1,18 @@
+package mysqlpkg
+
+import (
+	"time"
+
+	"github.com/overtalk/bgo/pkg/metrics"
+)
+
+var callDuration = metricspkg.NewHistogramVec("bgo_mysql_call_seconds", "Latency of mysql calls of each operation.", nil, "op")
+
+func init() {
+	metricspkg.MustRegister(callDuration)
+}
+
+// observe observe the latency of an operation since start
+func observe(op string, start time.Time) {
+	callDuration.With(op).ObserveSince(start)
+}
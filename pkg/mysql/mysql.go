@@ -1,18 +1,21 @@
 package mysqlpkg
 
 import (
+	"context"
 	"database/sql"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/overtalk/bgo/pkg/log"
-	"github.com/overtalk/bgo/utils/xml"
+	"github.com/overtalk/bgo/utils/config"
 )
 
 const (
@@ -35,22 +38,41 @@ type (
 )
 
 type Config struct {
-	XMLName  xml.Name `xml:"xml"`
-	PoolSize int      `xml:"poolsize"`
-	Username string   `xml:"username"`
-	Password string   `xml:"password"`
-	Address  string   `xml:"address"`
-	Dbname   string   `xml:"dbname"`
+	XMLName  xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
+	PoolSize int      `xml:"poolsize" json:"poolsize" yaml:"poolsize" toml:"poolsize" default:"10" validate:"min=1"`
+	Username string   `xml:"username" json:"username" yaml:"username" toml:"username" validate:"required"`
+	Password string   `xml:"password" json:"password" yaml:"password" toml:"password" secret:"true"`
+	Address  string   `xml:"address" json:"address" yaml:"address" toml:"address" validate:"required"`
+	Dbname   string   `xml:"dbname" json:"dbname" yaml:"dbname" toml:"dbname" validate:"required"`
+}
+
+// MarshalLogObject log the config with its secrets redacted
+func (this *Config) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return configutil.MarshalLogObject(enc, this)
 }
 
 type MysqlConn struct {
-	cfg *Config
-	db  *sql.DB
+	lock sync.RWMutex
+	cfg  *Config
+	db   *mysqlDB
+}
+
+// mysqlDB a connection pool with the operations in progress on it,
+// it's closed after they are done when it's replaced by a reload
+type mysqlDB struct {
+	*sql.DB
+	using sync.WaitGroup
+}
+
+// closeUnused close the db after all operations on it are done
+func (db *mysqlDB) closeUnused() {
+	db.using.Wait()
+	db.Close()
 }
 
 func NewMysqlConn(path string) (*MysqlConn, error) {
 	cfg := &Config{}
-	if err := xmlutil.ParseXml(path, cfg); err != nil {
+	if err := configutil.Load(path, cfg); err != nil {
 		return nil, err
 	}
 
@@ -58,11 +80,81 @@ func NewMysqlConn(path string) (*MysqlConn, error) {
 }
 
 func (this *MysqlConn) Connect() error {
+	db, err := open(this.cfg)
+	if err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	this.db = &mysqlDB{DB: db}
+	this.lock.Unlock()
+	return nil
+}
+
+// acquire get the current db for an operation, call the returned func after the operation is done
+func (this *MysqlConn) acquire() (*mysqlDB, func()) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if this.db == nil {
+		return nil, func() {}
+	}
+	db := this.db
+	db.using.Add(1)
+	return db, db.using.Done
+}
+
+// Ping check whether the connection to mysql is alive
+func (this *MysqlConn) Ping(ctx context.Context) error {
+	db, done := this.acquire()
+	defer done()
+	if db == nil {
+		return errors.New("mysql is not connected")
+	}
+	return db.PingContext(ctx)
+}
+
+// Reload reload the config, the pool size is applied to the current connection,
+// and if any other field changed, it connects to mysql again.
+// the old connection is closed after the operations in progress on it are done.
+func (this *MysqlConn) Reload(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	this.lock.RLock()
+	old, oldDB := this.cfg, this.db
+	this.lock.RUnlock()
+	if cfg.Username == old.Username && cfg.Password == old.Password &&
+		cfg.Address == old.Address && cfg.Dbname == old.Dbname && oldDB != nil {
+		setPoolSize(oldDB.DB, cfg.PoolSize)
+		this.lock.Lock()
+		this.cfg = cfg
+		this.lock.Unlock()
+		return nil
+	}
+
+	db, err := open(cfg)
+	if err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	oldDB = this.db
+	this.cfg, this.db = cfg, &mysqlDB{DB: db}
+	this.lock.Unlock()
+	if oldDB != nil {
+		go oldDB.closeUnused()
+	}
+	return nil
+}
+
+func open(c *Config) (*sql.DB, error) {
 	cfg := &mysql.Config{
-		User:                 this.cfg.Username,
-		Passwd:               this.cfg.Password,
-		Addr:                 this.cfg.Address,
-		DBName:               this.cfg.Dbname,
+		User:                 c.Username,
+		Passwd:               c.Password,
+		Addr:                 c.Address,
+		DBName:               c.Dbname,
 		Loc:                  time.Now().Location(),
 		ParseTime:            true,
 		Net:                  "tcp",
@@ -73,28 +165,43 @@ func (this *MysqlConn) Connect() error {
 	if err != nil {
 		//logpkg.GetLogger().With(zap.Error(err)).Error("failed to open mysql")
 		logpkg.Error("failed to open mysql", zap.Error(err))
-		return err
-	}
-	db.SetMaxOpenConns(this.cfg.PoolSize)
-
-	if maxIdle := this.cfg.PoolSize / 10; maxIdle > 2 {
-		db.SetMaxIdleConns(maxIdle)
+		return nil, err
 	}
+	setPoolSize(db, c.PoolSize)
 
 	if err = db.Ping(); err != nil {
 		logpkg.Error("failed to ping mysql", zap.Error(err))
-		return err
+		db.Close()
+		return nil, err
 	}
 
-	this.db = db
-	return nil
+	return db, nil
+}
+
+func setPoolSize(db *sql.DB, poolSize int) {
+	db.SetMaxOpenConns(poolSize)
+
+	if maxIdle := poolSize / 10; maxIdle > 2 {
+		db.SetMaxIdleConns(maxIdle)
+	}
 }
 
-func (this *MysqlConn) GetConn() *sql.DB { return this.db }
+// GetConn get the current connection pool, get it again for each use as it's closed after a reload
+func (this *MysqlConn) GetConn() *sql.DB {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if this.db == nil {
+		return nil
+	}
+	return this.db.DB
+}
 
 func (this *MysqlConn) Insert(tableName string, data map[string]interface{}) (int64, error) {
+	defer observe("insert", time.Now())
 	sql, values := GenInsertSql(tableName, data)
-	res, err := this.db.Exec(sql, values...)
+	db, done := this.acquire()
+	defer done()
+	res, err := db.Exec(sql, values...)
 	if err != nil {
 		return 0, err
 	}
@@ -103,8 +210,11 @@ func (this *MysqlConn) Insert(tableName string, data map[string]interface{}) (in
 }
 
 func (this *MysqlConn) Update(tableName string, data map[string]interface{}, where map[string]Condition) (int64, error) {
+	defer observe("update", time.Now())
 	sql, values := GenUpdateSql(tableName, data, where)
-	res, err := this.db.Exec(sql, values...)
+	db, done := this.acquire()
+	defer done()
+	res, err := db.Exec(sql, values...)
 	if err != nil {
 		return 0, err
 	}
@@ -159,14 +269,20 @@ func (this *MysqlConn) Select(tableName string, columns []string, where map[stri
 }
 
 func (this *MysqlConn) SelectOneWithHandler(tableName string, columns []string, where map[string]Condition, order *Order, handler RowHandler) error {
+	defer observe("select", time.Now())
 	sql, values := GenSelectSql(tableName, columns, where, order, 1)
-	row := this.db.QueryRow(sql, values...)
+	db, done := this.acquire()
+	defer done()
+	row := db.QueryRow(sql, values...)
 	return handler(row)
 }
 
 func (this *MysqlConn) SelectWithHandler(tableName string, columns []string, where map[string]Condition, order *Order, limit int, handler RowsHandler) error {
+	defer observe("select", time.Now())
 	sql, values := GenSelectSql(tableName, columns, where, order, limit)
-	rows, err := this.db.Query(sql, values...)
+	db, done := this.acquire()
+	defer done()
+	rows, err := db.Query(sql, values...)
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,7 @@ import (
 	"go.uber.org/zap"
 
 	gozd "github.com/overtalk/bgo/pkg/service/zd"
+	"github.com/overtalk/bgo/utils/net"
 )
 
 // HandlerFunc a Handler wrapper
@@ -64,6 +65,7 @@ func NewServiceWithOption(opt ServiceOption) *Service {
 			Name:       opt.Listener.Name,
 			Network:    opt.Listener.Network,
 			Address:    opt.Listener.Address,
+			Chmod:      opt.Listener.Chmod,
 			MaxConn:    opt.Listener.MaxConn,
 			ReadSynced: opt.Listener.ReadSynced,
 		},
@@ -85,10 +87,11 @@ func (ts *Service) GetName() string { return ts.opt.Name }
 
 // NewListener create a service listener
 func (ts *Service) NewListener() (net.Listener, error) {
-	if ts.opt.Network == "unix" {
+	// the inherited unix socket file is still in use
+	if ts.opt.Network == "unix" && !netutil.IsInherited(ts.opt.Network, ts.opt.Address) {
 		os.Remove(ts.opt.Address)
 	}
-	l, err := net.Listen(ts.opt.Network, ts.opt.Address)
+	l, err := netutil.Listen(ts.opt.Network, ts.opt.Address)
 	if err != nil {
 		// handle error
 		// TODO: log
@@ -130,6 +133,9 @@ func (ts *Service) Serve(l net.Listener) {
 				time.Sleep(tempDelay)
 				continue
 			}
+			if err == gozd.ErrListenerStopped {
+				break
+			}
 			zap.S().Errorf("accept error: %v", err)
 			break
 		}
@@ -0,0 +1,31 @@
+package redispkg
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/overtalk/bgo/pkg/metrics"
+)
+
+var (
+	callDuration = metricspkg.NewHistogramVec("bgo_redis_call_seconds", "Latency of redis commands.", nil, "cmd")
+	callErrors   = metricspkg.NewCounterVec("bgo_redis_call_errors_total", "Total number of failed redis commands, nil replies excluded.", "cmd")
+)
+
+func init() {
+	metricspkg.MustRegister(callDuration, callErrors)
+}
+
+// observeProcess wrap the process of redis commands to observe their latencies
+func observeProcess(process func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+	return func(cmd redis.Cmder) error {
+		start := time.Now()
+		err := process(cmd)
+		callDuration.With(cmd.Name()).ObserveSince(start)
+		if err != nil && err != redis.Nil {
+			callErrors.With(cmd.Name()).Inc()
+		}
+		return err
+	}
+}
@@ -3,32 +3,56 @@ package redispkg
 import (
 	"encoding/xml"
 	"errors"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/overtalk/bgo/pkg/log"
-	"github.com/overtalk/bgo/utils/xml"
+	"github.com/overtalk/bgo/utils/config"
 )
 
 type Config struct {
-	XMLName xml.Name `xml:"xml"`
+	XMLName xml.Name `xml:"xml" json:"-" yaml:"-" toml:"-"`
 	Address struct {
-		Item []string `xml:"item"`
-	} `xml:"address"`
-	Password string `xml:"password"`
-	PoolSize int    `xml:"poolSize"`
+		Item []string `xml:"item" json:"item" yaml:"item" toml:"item" validate:"required"`
+	} `xml:"address" json:"address" yaml:"address" toml:"address"`
+	Password string `xml:"password" json:"password" yaml:"password" toml:"password" secret:"true"`
+	PoolSize int    `xml:"poolSize" json:"poolSize" yaml:"poolSize" toml:"poolSize" default:"10" validate:"min=1"`
+}
+
+// MarshalLogObject log the config with its secrets redacted
+func (this *Config) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return configutil.MarshalLogObject(enc, this)
 }
 
 type RedisClient struct {
+	lock   sync.RWMutex
 	cfg    *Config
-	client redis.Cmdable
+	client *redisConn
+}
+
+// redisConn a client with the operations in progress on it,
+// it's closed after they are done when it's replaced by a reload
+type redisConn struct {
+	redis.Cmdable
+	using sync.WaitGroup
+}
+
+// closeUnused close the client after all operations on it are done
+func (conn *redisConn) closeUnused() {
+	conn.using.Wait()
+	if closer, ok := conn.Cmdable.(io.Closer); ok {
+		closer.Close()
+	}
 }
 
 func NewRedisClient(path string) (*RedisClient, error) {
 	cfg := &Config{}
-	if err := xmlutil.ParseXml(path, cfg); err != nil {
+	if err := configutil.Load(path, cfg); err != nil {
 		return nil, err
 	}
 
@@ -36,39 +60,132 @@ func NewRedisClient(path string) (*RedisClient, error) {
 }
 
 func (this *RedisClient) Connect() error {
-	if len(this.cfg.Address.Item) == 0 {
-		return errors.New("redis address absent")
+	conn, err := connect(this.cfg)
+	if err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	this.client = &redisConn{Cmdable: conn}
+	this.lock.Unlock()
+	return nil
+}
+
+// Reload reload the config, if the address, the password or the pool size changed,
+// it connects to redis again with it, the old connection is closed after the new one
+// is ready and the operations in progress on the old one are done.
+func (this *RedisClient) Reload(path string) error {
+	cfg := &Config{}
+	if err := configutil.Load(path, cfg); err != nil {
+		return err
+	}
+
+	this.lock.RLock()
+	old, oldConn := this.cfg, this.client
+	this.lock.RUnlock()
+	if oldConn != nil && sameAddress(cfg, old) && cfg.Password == old.Password && cfg.PoolSize == old.PoolSize {
+		this.lock.Lock()
+		this.cfg = cfg
+		this.lock.Unlock()
+		return nil
+	}
+
+	conn, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	oldConn = this.client
+	this.cfg, this.client = cfg, &redisConn{Cmdable: conn}
+	this.lock.Unlock()
+	if oldConn != nil {
+		go oldConn.closeUnused()
+	}
+	return nil
+}
+
+func sameAddress(a, b *Config) bool {
+	if len(a.Address.Item) != len(b.Address.Item) {
+		return false
+	}
+	for i := range a.Address.Item {
+		if a.Address.Item[i] != b.Address.Item[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func connect(cfg *Config) (redis.Cmdable, error) {
+	if len(cfg.Address.Item) == 0 {
+		return nil, errors.New("redis address absent")
 	}
 
 	var conn redis.Cmdable
-	if len(this.cfg.Address.Item) > 1 {
-		conn = redis.NewClusterClient(&redis.ClusterOptions{
-			Addrs:    this.cfg.Address.Item,
-			Password: this.cfg.Password,
-			PoolSize: this.cfg.PoolSize,
+	if len(cfg.Address.Item) > 1 {
+		client := redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    cfg.Address.Item,
+			Password: cfg.Password,
+			PoolSize: cfg.PoolSize,
 		})
+		client.WrapProcess(observeProcess)
+		conn = client
 	} else {
-		conn = redis.NewClient(&redis.Options{
-			Addr:     this.cfg.Address.Item[0],
-			Password: this.cfg.Password,
-			PoolSize: this.cfg.PoolSize,
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Address.Item[0],
+			Password: cfg.Password,
+			PoolSize: cfg.PoolSize,
 		})
+		client.WrapProcess(observeProcess)
+		conn = client
 	}
 
 	if _, err := conn.Ping().Result(); err != nil {
 		//logpkg.GetLogger().With(zap.Any("addrs", this.cfg.Address.Item)).Error("failed to ping redis during connection")
-		logpkg.Error("failed to ping redis during connection", zap.Any("addrs", this.cfg.Address.Item))
-		return err
+		logpkg.Error("failed to ping redis during connection", zap.Any("addrs", cfg.Address.Item))
+		return nil, err
 	}
 
-	this.client = conn
-	return nil
+	return conn, nil
+}
+
+// GetConn get the current client, get it again for each use as it's closed after a reload
+func (this *RedisClient) GetConn() redis.Cmdable {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if this.client == nil {
+		return nil
+	}
+	return this.client.Cmdable
 }
 
-func (this *RedisClient) GetConn() redis.Cmdable { return this.client }
+// acquire get the current client for an operation, call the returned func after the operation is done
+func (this *RedisClient) acquire() (redis.Cmdable, func()) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if this.client == nil {
+		return nil, func() {}
+	}
+	conn := this.client
+	conn.using.Add(1)
+	return conn.Cmdable, conn.using.Done
+}
+
+// Ping check whether the connection to redis is alive
+func (this *RedisClient) Ping() error {
+	conn, done := this.acquire()
+	defer done()
+	if conn == nil {
+		return errors.New("redis is not connected")
+	}
+	return conn.Ping().Err()
+}
 
 func (this *RedisClient) Expire(key string, dur time.Duration) error {
-	_, err := this.client.Expire(key, dur).Result()
+	conn, done := this.acquire()
+	defer done()
+	_, err := conn.Expire(key, dur).Result()
 	if err != nil {
 		return err
 	}
@@ -76,7 +193,9 @@ func (this *RedisClient) Expire(key string, dur time.Duration) error {
 }
 
 func (this *RedisClient) Exist(key string) (bool, error) {
-	count, err := this.client.Exists(key).Result()
+	conn, done := this.acquire()
+	defer done()
+	count, err := conn.Exists(key).Result()
 	if err != nil {
 		return false, err
 	}
@@ -87,28 +206,38 @@ func (this *RedisClient) Exist(key string) (bool, error) {
 }
 
 func (this *RedisClient) Get(key string) (string, error) {
-	return this.client.Get(key).Result()
+	conn, done := this.acquire()
+	defer done()
+	return conn.Get(key).Result()
 }
 
 func (this *RedisClient) Set(key string, value interface{}, expiration time.Duration) error {
-	return this.client.Set(key, value, expiration).Err()
+	conn, done := this.acquire()
+	defer done()
+	return conn.Set(key, value, expiration).Err()
 }
 
 func (this *RedisClient) INCR(key string) (int64, error) {
-	return this.client.Incr(key).Result()
+	conn, done := this.acquire()
+	defer done()
+	return conn.Incr(key).Result()
 }
 
 func (this *RedisClient) INCRBy(key string, value int64) (int64, error) {
-	return this.client.IncrBy(key, value).Result()
+	conn, done := this.acquire()
+	defer done()
+	return conn.IncrBy(key, value).Result()
 }
 
 func (this *RedisClient) HSet(key, field string, value interface{}, expiration time.Duration) error {
-	if err := this.client.HSet(key, field, value).Err(); err != nil {
+	conn, done := this.acquire()
+	defer done()
+	if err := conn.HSet(key, field, value).Err(); err != nil {
 		return err
 	}
 
-	if err := this.client.Expire(key, expiration).Err(); err != nil {
-		this.client.Del(key)
+	if err := conn.Expire(key, expiration).Err(); err != nil {
+		conn.Del(key)
 		return err
 	}
 
@@ -116,13 +245,15 @@ func (this *RedisClient) HSet(key, field string, value interface{}, expiration t
 }
 
 func (this *RedisClient) HMSet(key string, fields map[string]interface{}, expiration time.Duration) error {
-	if err := this.client.HMSet(key, fields).Err(); err != nil {
+	conn, done := this.acquire()
+	defer done()
+	if err := conn.HMSet(key, fields).Err(); err != nil {
 		return err
 	}
 
 	if expiration > 0 {
-		if err := this.client.Expire(key, expiration).Err(); err != nil {
-			this.client.Del(key)
+		if err := conn.Expire(key, expiration).Err(); err != nil {
+			conn.Del(key)
 			return err
 		}
 	}
@@ -131,13 +262,19 @@ func (this *RedisClient) HMSet(key string, fields map[string]interface{}, expira
 }
 
 func (this *RedisClient) HGet(key, field string) (string, error) {
-	return this.client.HGet(key, field).Result()
+	conn, done := this.acquire()
+	defer done()
+	return conn.HGet(key, field).Result()
 }
 
 func (this *RedisClient) HGetAll(key string) (map[string]string, error) {
-	return this.client.HGetAll(key).Result()
+	conn, done := this.acquire()
+	defer done()
+	return conn.HGetAll(key).Result()
 }
 
 func (this *RedisClient) Del(keys ...string) {
-	this.client.Del(keys...)
+	conn, done := this.acquire()
+	defer done()
+	conn.Del(keys...)
 }
@@ -0,0 +1,261 @@
+package packet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// aead ciphers
+const (
+	CipherAESGCM           = "aes-gcm"
+	CipherChaCha20Poly1305 = "chacha20-poly1305"
+)
+
+// the key size of the aead ciphers, aes-256-gcm is used
+const AEADKeySize = 32
+
+// an aead packet is DATASIZE + CONNID + PROTOID + PROTOVER + DATAFLAG + SEQ + SEALED(SIGN + DATALOAD) + TAG,
+// the header and the seq are authenticated but not encrypted
+const (
+	aeadHeaderSize = 2 + OptSizeData
+	aeadSeqSize    = 8
+	aeadNonceSize  = 12
+	replayWindow   = 64
+)
+
+// the direction in the nonce, it prevents reusing a nonce in both directions with the same key
+const (
+	directionClient = 0 // from the client to the server
+	directionServer = 1 // from the server to the client
+)
+
+// error definitions
+var (
+	ErrUnknownCipher = errors.New("unknown aead cipher")
+	ErrNotEncrypted  = errors.New("packet is not aead encrypted")
+	ErrReplay        = errors.New("packet is replayed")
+	ErrDecrypt       = errors.New("packet decryption failed")
+)
+
+// DeriveSessionKey derive a session key from the secret and the salt by HKDF-SHA256,
+// the salt should be unique for each session, eg: some random bytes from the client
+func DeriveSessionKey(secret, salt, info []byte) ([]byte, error) {
+	key := make([]byte, AEADKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// AEADCrypto an authenticated encryption crypto for a session,
+// each packet carries an increasing seq which is a part of the nonce, the replayed packets are rejected.
+// don't share it between sessions.
+type AEADCrypto struct {
+	aead    cipher.AEAD
+	sendDir uint32
+	recvDir uint32
+	sendSeq uint64
+
+	// the replay window of the received seqs
+	lock    sync.Mutex
+	recvSeq uint64 // the max received seq
+	recvMap uint64 // the bitmap of the received seqs in [recvSeq-63, recvSeq]
+}
+
+// NewAEADCrypto create an AEADCrypto by the cipher and the session key,
+// isServer should be true on the server side and false on the client side
+func NewAEADCrypto(name string, key []byte, isServer bool) (*AEADCrypto, error) {
+	var aead cipher.AEAD
+	switch name {
+	case CipherAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	case CipherChaCha20Poly1305:
+		var err error
+		if aead, err = chacha20poly1305.New(key); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Wrap(ErrUnknownCipher, name)
+	}
+
+	crypto := &AEADCrypto{aead: aead, sendDir: directionClient, recvDir: directionServer}
+	if isServer {
+		crypto.sendDir, crypto.recvDir = directionServer, directionClient
+	}
+	return crypto, nil
+}
+
+func (this *AEADCrypto) nonce(direction uint32, seq uint64) []byte {
+	nonce := make([]byte, aeadNonceSize)
+	binary.BigEndian.PutUint32(nonce[:4], direction)
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+// Encrypt seal the packet into a new one with the FlagAEAD
+func (this *AEADCrypto) Encrypt(packet Packet) (Packet, error) {
+	if !packet.IsValid() || len(packet) < aeadHeaderSize {
+		return nil, ErrInvalidSize
+	}
+	dataSize := len(packet) - 2 + aeadSeqSize + this.aead.Overhead()
+	if 2+dataSize > MaxMessageSize {
+		return nil, ErrInvalidSize
+	}
+	seq := atomic.AddUint64(&this.sendSeq, 1)
+
+	out := make(Packet, aeadHeaderSize+aeadSeqSize, 2+dataSize)
+	copy(out, packet[:aeadHeaderSize])
+	Packet(out[:2+dataSize]).resetDataSize()
+	out.SetDataFlag(FlagAEAD)
+	binary.BigEndian.PutUint64(out[aeadHeaderSize:], seq)
+	// the additional data mustn't overlap the dst
+	ad := append([]byte(nil), out...)
+	return this.aead.Seal(out, this.nonce(this.sendDir, seq), packet[aeadHeaderSize:], ad), nil
+}
+
+// Decrypt open the packet in place, the FlagAEAD is cleared
+func (this *AEADCrypto) Decrypt(packet Packet) (Packet, error) {
+	if !packet.IsValid() || len(packet) < aeadHeaderSize+aeadSeqSize+this.aead.Overhead() {
+		return nil, ErrInvalidSize
+	}
+	if !packet.HasDataFlag(FlagAEAD) {
+		return nil, ErrNotEncrypted
+	}
+	seq := binary.BigEndian.Uint64(packet[aeadHeaderSize:])
+	if !this.checkSeq(seq) {
+		return nil, ErrReplay
+	}
+
+	ad := packet[:aeadHeaderSize+aeadSeqSize]
+	sealed := packet[aeadHeaderSize+aeadSeqSize:]
+	plain, err := this.aead.Open(sealed[:0], this.nonce(this.recvDir, seq), sealed, ad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	// only the authenticated seqs are recorded
+	if !this.updateSeq(seq) {
+		return nil, ErrReplay
+	}
+
+	// move the dataload forward over the seq
+	size := copy(packet[aeadHeaderSize:], plain)
+	packet = packet[:aeadHeaderSize+size]
+	packet.resetDataSize()
+	packet.ClearDataFlag(FlagAEAD)
+	return packet, nil
+}
+
+// checkSeq check whether the seq is new or in the window but not received yet
+func (this *AEADCrypto) checkSeq(seq uint64) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.isNewSeq(seq)
+}
+
+func (this *AEADCrypto) isNewSeq(seq uint64) bool {
+	switch {
+	case seq == 0:
+		return false
+	case seq > this.recvSeq:
+		return true
+	case this.recvSeq-seq >= replayWindow:
+		return false
+	default:
+		return this.recvMap&(1<<(this.recvSeq-seq)) == 0
+	}
+}
+
+// updateSeq record the seq, it fails if the seq has been received concurrently
+func (this *AEADCrypto) updateSeq(seq uint64) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if !this.isNewSeq(seq) {
+		return false
+	}
+	if seq > this.recvSeq {
+		if shift := seq - this.recvSeq; shift < replayWindow {
+			this.recvMap <<= shift
+		} else {
+			this.recvMap = 0
+		}
+		this.recvSeq = seq
+	}
+	this.recvMap |= 1 << (this.recvSeq - seq)
+	return true
+}
+
+// -------------------------------------------
+// -------------------------------------------
+
+// legacyAccepted whether a packet encrypted by a legacy crypto is accepted, 1: accepted
+var legacyAccepted int32 = 1
+
+// SetLegacyAccepted set whether the legacy packets are accepted by a RolloutCrypto,
+// turn it off after all clients support the aead crypto
+func SetLegacyAccepted(accepted bool) {
+	var v int32
+	if accepted {
+		v = 1
+	}
+	atomic.StoreInt32(&legacyAccepted, v)
+}
+
+// IsLegacyAccepted check whether the legacy packets are accepted by a RolloutCrypto
+func IsLegacyAccepted() bool { return atomic.LoadInt32(&legacyAccepted) == 1 }
+
+// ErrLegacyRejected the legacy packets are not accepted any more
+var ErrLegacyRejected = errors.New("legacy crypto is rejected")
+
+// RolloutCrypto a crypto for the migration from a legacy crypto(eg: XORCrypto) to an AEADCrypto,
+// it decrypts both of them and responds the client by the crypto the client has used,
+// the legacy packets are rejected after SetLegacyAccepted(false).
+type RolloutCrypto struct {
+	aead   ICrypto
+	legacy ICrypto
+	// 1 after receiving an aead packet
+	usingAEAD int32
+}
+
+// NewRolloutCrypto create a RolloutCrypto for a session
+func NewRolloutCrypto(aead, legacy ICrypto) *RolloutCrypto {
+	return &RolloutCrypto{aead: aead, legacy: legacy}
+}
+
+// Encrypt encrypt the packet by the aead crypto if the client has used it, or the legacy one
+func (this *RolloutCrypto) Encrypt(packet Packet) (Packet, error) {
+	if atomic.LoadInt32(&this.usingAEAD) == 1 {
+		return this.aead.Encrypt(packet)
+	}
+	return this.legacy.Encrypt(packet)
+}
+
+// Decrypt decrypt the packet by the crypto indicated by its data flag
+func (this *RolloutCrypto) Decrypt(packet Packet) (Packet, error) {
+	if packet.IsValid() && len(packet) >= aeadHeaderSize && packet.HasDataFlag(FlagAEAD) {
+		packet, err := this.aead.Decrypt(packet)
+		if err == nil {
+			atomic.StoreInt32(&this.usingAEAD, 1)
+		}
+		return packet, err
+	}
+	// the client can't downgrade after using the aead crypto
+	if !IsLegacyAccepted() || atomic.LoadInt32(&this.usingAEAD) == 1 {
+		return nil, ErrLegacyRejected
+	}
+	return this.legacy.Decrypt(packet)
+}
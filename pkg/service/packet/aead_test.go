@@ -0,0 +1,154 @@
+package packet_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+func newAEADPair(t *testing.T, name string) (*packet.AEADCrypto, *packet.AEADCrypto) {
+	key, err := packet.DeriveSessionKey([]byte("secret"), []byte("salt"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := packet.NewAEADCrypto(name, key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := packet.NewAEADCrypto(name, key, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func newDataPacket(data string) packet.Packet {
+	pack := packet.NewFromData([]byte(data), nil, packet.NoneCompresser)
+	pack.SetConnID(101)
+	pack.SetProtoMID(1)
+	pack.SetProtoAID(2)
+	return pack
+}
+
+func TestAEADCrypto(t *testing.T) {
+	for _, name := range []string{packet.CipherAESGCM, packet.CipherChaCha20Poly1305} {
+		client, server := newAEADPair(t, name)
+
+		sealed, err := client.Encrypt(newDataPacket("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sealed.HasDataFlag(packet.FlagAEAD) || bytes.Contains(sealed, []byte("hello")) {
+			t.Fatalf("%s : not sealed %v", name, sealed)
+		}
+		if int(sealed.GetDataSize()) != len(sealed)-2 {
+			t.Errorf("%s : data size = %d, len = %d", name, sealed.GetDataSize(), len(sealed))
+		}
+
+		// a client can't decrypt its own packet as the direction differs
+		if _, err := client.Decrypt(append(packet.Packet{}, sealed...)); err != packet.ErrDecrypt {
+			t.Errorf("%s : expect decrypt error, got %v", name, err)
+		}
+
+		opened, err := server.Decrypt(append(packet.Packet{}, sealed...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(opened.GetDataLoad()) != "hello" || opened.GetConnID() != 101 || opened.GetProtoAID() != 2 ||
+			opened.HasDataFlag(packet.FlagAEAD) || int(opened.GetDataSize()) != len(opened)-2 {
+			t.Errorf("%s : opened = %v", name, opened)
+		}
+
+		// replayed
+		if _, err := server.Decrypt(append(packet.Packet{}, sealed...)); err != packet.ErrReplay {
+			t.Errorf("%s : expect replay error, got %v", name, err)
+		}
+
+		// tampered
+		tampered, _ := client.Encrypt(newDataPacket("hello"))
+		tampered[len(tampered)-1] ^= 0xFF
+		if _, err := server.Decrypt(tampered); err != packet.ErrDecrypt {
+			t.Errorf("%s : expect decrypt error, got %v", name, err)
+		}
+
+		// the response
+		sealed, _ = server.Encrypt(newDataPacket("world"))
+		if opened, err := client.Decrypt(sealed); err != nil || string(opened.GetDataLoad()) != "world" {
+			t.Errorf("%s : response = %v, err = %v", name, opened, err)
+		}
+	}
+}
+
+func TestAEADReplayWindow(t *testing.T) {
+	client, server := newAEADPair(t, packet.CipherAESGCM)
+	var sealed []packet.Packet
+	for i := 0; i < 100; i++ {
+		pack, err := client.Encrypt(newDataPacket("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed = append(sealed, pack)
+	}
+
+	// the packet is decrypted in place
+	decrypt := func(i int) error {
+		_, err := server.Decrypt(append(packet.Packet{}, sealed[i]...))
+		return err
+	}
+
+	// out of order in the window
+	for _, i := range []int{10, 5, 9, 70} {
+		if err := decrypt(i); err != nil {
+			t.Errorf("%d : %v", i, err)
+		}
+	}
+	// received or out of the window
+	for _, i := range []int{9, 5, 6} {
+		if err := decrypt(i); err != packet.ErrReplay {
+			t.Errorf("%d : expect replay error, got %v", i, err)
+		}
+	}
+	if err := decrypt(69); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRolloutCrypto(t *testing.T) {
+	packet.SetCryptoSecret([]byte("xor"))
+	client, server := newAEADPair(t, packet.CipherAESGCM)
+	defer packet.SetLegacyAccepted(true)
+
+	// a legacy client
+	crypto := packet.NewRolloutCrypto(server, packet.XORCrypto)
+	legacy, _ := packet.XORCrypto.Encrypt(newDataPacket("hello"))
+	opened, err := crypto.Decrypt(legacy)
+	if err != nil || string(opened.GetDataLoad()) != "hello" {
+		t.Fatalf("opened = %v, err = %v", opened, err)
+	}
+	if rsp, _ := crypto.Encrypt(newDataPacket("world")); !rsp.HasDataFlag(packet.FlagXOR) {
+		t.Errorf("expect a xor response, got %v", rsp)
+	}
+
+	// the client upgrades
+	sealed, _ := client.Encrypt(newDataPacket("hello"))
+	if _, err := crypto.Decrypt(sealed); err != nil {
+		t.Fatal(err)
+	}
+	if rsp, _ := crypto.Encrypt(newDataPacket("world")); !rsp.HasDataFlag(packet.FlagAEAD) {
+		t.Errorf("expect an aead response, got %v", rsp)
+	}
+	// no downgrade
+	legacy, _ = packet.XORCrypto.Encrypt(newDataPacket("hello"))
+	if _, err := crypto.Decrypt(legacy); err != packet.ErrLegacyRejected {
+		t.Errorf("expect legacy rejected, got %v", err)
+	}
+
+	// the rollout window is closed
+	packet.SetLegacyAccepted(false)
+	crypto = packet.NewRolloutCrypto(server, packet.XORCrypto)
+	legacy, _ = packet.XORCrypto.Encrypt(newDataPacket("hello"))
+	if _, err := crypto.Decrypt(legacy); err != packet.ErrLegacyRejected {
+		t.Errorf("expect legacy rejected, got %v", err)
+	}
+}
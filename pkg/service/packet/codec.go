@@ -0,0 +1,183 @@
+package packet
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"github.com/overtalk/bgo/pkg/service/pool"
+)
+
+// MaxDataLoadSize the max size of a decompressed dataload
+const MaxDataLoadSize = 1024 * 1024
+
+// codecFlags the data flags of all codecs, only one of them is set
+const codecFlags = FlagZLIB | FlagSnappy | FlagZstd
+
+// error definitions
+var (
+	ErrUnknownCodec = errors.New("unknown codec")
+	ErrTooLarge     = errors.New("decompressed dataload too large")
+)
+
+// ICodec a codec to compress and decompress a packet's dataload, identified by its data flag
+type ICodec interface {
+	Flag() uint8
+	Compress(data []byte) ([]byte, error)
+	// Decompress decompress the data, ErrTooLarge is returned if the result exceeds the maxSize
+	Decompress(data []byte, maxSize int) ([]byte, error)
+}
+
+var (
+	codecLock sync.RWMutex
+	codecs    = map[uint8]ICodec{}
+)
+
+func init() {
+	RegisterCodec(zlibCodec{})
+	RegisterCodec(snappyCodec{})
+	RegisterCodec(&zstdCodec{})
+}
+
+// RegisterCodec register a codec, the registered one of the same flag is replaced
+func RegisterCodec(codec ICodec) {
+	codecLock.Lock()
+	codecs[codec.Flag()] = codec
+	codecLock.Unlock()
+}
+
+// GetCodec get the codec of the flag, nil if not registered
+func GetCodec(flag uint8) ICodec {
+	codecLock.RLock()
+	codec := codecs[flag]
+	codecLock.RUnlock()
+	return codec
+}
+
+// Decompress decompress the data by the codec in the data flag, the data is returned if no codec flag
+func Decompress(flag uint8, data []byte) ([]byte, error) {
+	flag &= codecFlags
+	if flag == 0 {
+		return data, nil
+	}
+	codec := GetCodec(flag)
+	if codec == nil {
+		return nil, ErrUnknownCodec
+	}
+	return codec.Decompress(data, MaxDataLoadSize)
+}
+
+// the codec and the threshold of the automatic compression
+var autoCompress atomic.Value // autoCompressOption
+
+type autoCompressOption struct {
+	flag    uint8
+	minSize int
+}
+
+// SetAutoCompress set the codec and the threshold of the automatic compression,
+// the dataload larger than the minSize is compressed, disable it by the flag 0
+func SetAutoCompress(flag uint8, minSize int) {
+	autoCompress.Store(autoCompressOption{flag: flag, minSize: minSize})
+}
+
+// AutoCompresser get a compresser of the automatic compression, zlib for the data larger than 1KB by default
+func AutoCompresser() ICompresser {
+	opt, ok := autoCompress.Load().(autoCompressOption)
+	if !ok {
+		opt = autoCompressOption{flag: FlagZLIB, minSize: 1024}
+	}
+	if opt.flag == 0 {
+		return NoneCompresser
+	}
+	return NewCodecCompresser(opt.flag, opt.minSize)
+}
+
+// -------------------------------------------
+// -------------------------------------------
+
+// zlibCodec a zlib codec by the pools
+type zlibCodec struct{}
+
+func (zlibCodec) Flag() uint8 { return FlagZLIB }
+
+func (zlibCodec) Compress(data []byte) ([]byte, error) {
+	writer := zlibPool.Get()
+	defer writer.Free()
+	return append([]byte{}, writer.Compress(data)...), nil
+}
+
+func (zlibCodec) Decompress(data []byte, maxSize int) ([]byte, error) {
+	reader := zlibReaderPool.Get()
+	defer reader.Free()
+	b, err := reader.Decompress(data, maxSize)
+	if err != nil {
+		if err == pool.ErrTooLarge {
+			return nil, ErrTooLarge
+		}
+		return nil, err
+	}
+	return append([]byte{}, b...), nil
+}
+
+// snappyCodec a snappy codec
+type snappyCodec struct{}
+
+func (snappyCodec) Flag() uint8 { return FlagSnappy }
+
+func (snappyCodec) Compress(data []byte) ([]byte, error) { return snappy.Encode(nil, data), nil }
+
+func (snappyCodec) Decompress(data []byte, maxSize int) ([]byte, error) {
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxSize {
+		return nil, ErrTooLarge
+	}
+	return snappy.Decode(nil, data)
+}
+
+// zstdCodec a zstd codec, the encoder and the decoder are safe for concurrent use
+type zstdCodec struct {
+	once    sync.Once
+	err     error
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (zc *zstdCodec) Flag() uint8 { return FlagZstd }
+
+func (zc *zstdCodec) init() error {
+	zc.once.Do(func() {
+		if zc.encoder, zc.err = zstd.NewWriter(nil); zc.err != nil {
+			return
+		}
+		zc.decoder, zc.err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDataLoadSize))
+	})
+	return zc.err
+}
+
+func (zc *zstdCodec) Compress(data []byte) ([]byte, error) {
+	if err := zc.init(); err != nil {
+		return nil, err
+	}
+	return zc.encoder.EncodeAll(data, nil), nil
+}
+
+func (zc *zstdCodec) Decompress(data []byte, maxSize int) ([]byte, error) {
+	if err := zc.init(); err != nil {
+		return nil, err
+	}
+	b, err := zc.decoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxSize {
+		return nil, ErrTooLarge
+	}
+	return b, nil
+}
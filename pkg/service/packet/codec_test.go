@@ -0,0 +1,66 @@
+package packet_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+func TestCodecs(t *testing.T) {
+	data := bytes.Repeat([]byte("hello, world! "), 200)
+	for _, flag := range []uint8{packet.FlagZLIB, packet.FlagSnappy, packet.FlagZstd} {
+		pack := packet.NewFromData(data, nil, packet.NewCodecCompresser(flag, 1024))
+		if !pack.HasDataFlag(flag) || !pack.IsCompressed() || len(pack.GetDataLoad()) >= len(data) {
+			t.Errorf("flag %x : not compressed, size = %d", flag, len(pack))
+			continue
+		}
+		decompressed, err := pack.DecompressDataLoad()
+		if err != nil || !bytes.Equal(decompressed, data) {
+			t.Errorf("flag %x : decompressed size = %d, err = %v", flag, len(decompressed), err)
+		}
+
+		// too small to compress
+		pack = packet.NewFromData([]byte("hello"), nil, packet.NewCodecCompresser(flag, 1024))
+		if pack.IsCompressed() || string(pack.GetDataLoad()) != "hello" {
+			t.Errorf("flag %x : compressed a small data", flag)
+		}
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	bomb := make([]byte, packet.MaxDataLoadSize+1)
+	for _, flag := range []uint8{packet.FlagZLIB, packet.FlagSnappy, packet.FlagZstd} {
+		compressed, err := packet.GetCodec(flag).Compress(bomb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := packet.Decompress(flag, compressed); err == nil {
+			t.Errorf("flag %x : expect an error decompressing a large data", flag)
+		}
+	}
+
+	if _, err := packet.Decompress(packet.FlagZLIB|packet.FlagSnappy, []byte("data")); err != packet.ErrUnknownCodec {
+		t.Errorf("expect unknown codec, got %v", err)
+	}
+	if data, err := packet.Decompress(packet.FlagXOR, []byte("data")); err != nil || string(data) != "data" {
+		t.Errorf("data = %s, err = %v", data, err)
+	}
+}
+
+func TestAutoCompresser(t *testing.T) {
+	defer packet.SetAutoCompress(packet.FlagZLIB, 1024)
+	data := bytes.Repeat([]byte("a"), 2048)
+
+	if pack := packet.NewFromData(data, nil, packet.AutoCompresser()); !pack.HasDataFlag(packet.FlagZLIB) {
+		t.Error("expect compressed by zlib by default")
+	}
+	packet.SetAutoCompress(packet.FlagZstd, 4096)
+	if pack := packet.NewFromData(data, nil, packet.AutoCompresser()); pack.IsCompressed() {
+		t.Error("expect not compressed below the threshold")
+	}
+	packet.SetAutoCompress(0, 0)
+	if packet.AutoCompresser() != packet.NoneCompresser {
+		t.Error("expect the auto compression disabled")
+	}
+}
@@ -2,17 +2,26 @@ package packet
 
 import "github.com/overtalk/bgo/pkg/service/pool"
 
+// the default size of the zlib pools
+const defaultZlibPoolSize = 1024
+
 // provide some methods to compress a packet of data
-var zlibPool *pool.ZlibWriterPool
+var (
+	zlibPool       = pool.NewZlibWriterPool(defaultZlibPoolSize)
+	zlibReaderPool = pool.NewZlibReaderPool(defaultZlibPoolSize)
+)
 
-// InitZlibPool initialize a zlib pool with a pool size
+// InitZlibPool initialize the zlib pools with a pool size
 func InitZlibPool(size int) {
 	zlibPool = pool.NewZlibWriterPool(size)
+	zlibReaderPool = pool.NewZlibReaderPool(size)
 }
 
 // ICompresser a data compresser
 type ICompresser interface {
 	Compress([]byte) ([]byte, bool)
+	// Flag the data flag of the compressed data
+	Flag() uint8
 	Close()
 }
 
@@ -23,6 +32,7 @@ type noneCompresser struct{}
 var NoneCompresser = &noneCompresser{}
 
 func (*noneCompresser) Compress(b []byte) ([]byte, bool) { return b, false }
+func (*noneCompresser) Flag() uint8                      { return 0 }
 func (*noneCompresser) Close()                           {}
 
 // -------------------------------------------
@@ -48,6 +58,9 @@ func (zc *zlibCompresser) Compress(b []byte) ([]byte, bool) {
 	return b, false
 }
 
+// Flag the data flag of the compressed data
+func (zc *zlibCompresser) Flag() uint8 { return FlagZLIB }
+
 // Close close the compresser
 func (zc *zlibCompresser) Close() {
 	if zc.writer != nil {
@@ -55,3 +68,40 @@ func (zc *zlibCompresser) Close() {
 		zc.writer = nil
 	}
 }
+
+// -------------------------------------------
+// -------------------------------------------
+
+// codecCompresser a compresser of a registered codec
+type codecCompresser struct {
+	codec   ICodec
+	minSize int
+}
+
+// NewCodecCompresser create a compresser by the codec of the flag, it compresses the data larger than the minSize,
+// the NoneCompresser is returned if the codec is not registered
+func NewCodecCompresser(flag uint8, minSize int) ICompresser {
+	codec := GetCodec(flag)
+	if codec == nil {
+		return NoneCompresser
+	}
+	return &codecCompresser{codec: codec, minSize: minSize}
+}
+
+// Compress compress some data, the raw data is returned if it fails or it's not smaller
+func (cc *codecCompresser) Compress(b []byte) ([]byte, bool) {
+	if len(b) <= cc.minSize {
+		return b, false
+	}
+	compressed, err := cc.codec.Compress(b)
+	if err != nil || len(compressed) >= len(b) {
+		return b, false
+	}
+	return compressed, true
+}
+
+// Flag the data flag of the compressed data
+func (cc *codecCompresser) Flag() uint8 { return cc.codec.Flag() }
+
+// Close close the compresser
+func (cc *codecCompresser) Close() {}
@@ -9,12 +9,21 @@ func SetCryptoSecret(sec []byte) {
 	defaultCryptoSecret = append([]byte{}, sec...)
 }
 
-// ICrypto a crypto to encrypt/decrypt a packet
+// ICrypto a crypto to encrypt/decrypt a packet,
+// the returned packet may not share the memory with the input as its size may change
 type ICrypto interface {
-	Encrypt(Packet)
-	Decrypt(Packet)
+	Encrypt(Packet) (Packet, error)
+	Decrypt(Packet) (Packet, error)
 }
 
+type noneCrypto struct{}
+
+// NoneCrypto a crypto doing nothing
+var NoneCrypto ICrypto = noneCrypto{}
+
+func (noneCrypto) Encrypt(packet Packet) (Packet, error) { return packet, nil }
+func (noneCrypto) Decrypt(packet Packet) (Packet, error) { return packet, nil }
+
 type xorCrypto struct{}
 
 // XORCrypto a XOR crypto
@@ -53,16 +62,18 @@ func (xor *xorCrypto) encryptOrDecryptDataLoad(packet Packet) {
 	}
 }
 
-func (xor *xorCrypto) Encrypt(packet Packet) {
+func (xor *xorCrypto) Encrypt(packet Packet) (Packet, error) {
 	xor.encryptOrDecryptDataLoad(packet)
 	xor.encryptOrDecryptOptvals(packet)
 	packet.SetDataFlag(FlagXOR)
+	return packet, nil
 }
 
-func (xor *xorCrypto) Decrypt(packet Packet) {
+func (xor *xorCrypto) Decrypt(packet Packet) (Packet, error) {
 	if packet.HasDataFlag(FlagXOR) {
 		xor.encryptOrDecryptOptvals(packet)
 		xor.encryptOrDecryptDataLoad(packet)
 		packet.ClearDataFlag(FlagXOR)
 	}
+	return packet, nil
 }
@@ -0,0 +1,142 @@
+package packet
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// a large packet exceeding the MaxPacketSize is split into fragments,
+// a fragment is DATASIZE + CONNID + PROTOID + PROTOVER + DATAFLAG + TOTAL + OFFSET + CHUNK,
+// the header is the one of the large packet with the FlagContinuation,
+// TOTAL is the size of the large packet's body after the header, OFFSET is the offset of the CHUNK in the body.
+const (
+	fragmentHeaderSize = 2 + OptSizeData + 8
+	maxChunkSize       = MaxPacketSize - fragmentHeaderSize
+)
+
+// DefaultReassemblyTimeout the default timeout to receive all fragments of a large packet
+const DefaultReassemblyTimeout = 10 * time.Second
+
+// error definitions
+var (
+	ErrInvalidFragment = errors.New("invalid fragment")
+	ErrFragmentTimeout = errors.New("fragments reassembly timeout")
+	ErrMessageTooLarge = errors.New("reassembled packet too large")
+)
+
+// IsLarge check whether it's a large packet which should be split into fragments
+func (packet Packet) IsLarge() bool {
+	return len(packet) > MaxPacketSize
+}
+
+// Fragment split a large packet into fragments, they are returned in a bytes to be written at once,
+// so the fragments of concurrent writers aren't interleaved. the packet itself is returned if it isn't large.
+func (packet Packet) Fragment() []byte {
+	if !packet.IsLarge() {
+		return packet
+	}
+	body := packet[2+OptSizeData:]
+	total := len(body)
+	count := (total + maxChunkSize - 1) / maxChunkSize
+	out := make([]byte, 0, total+count*fragmentHeaderSize)
+	for offset := 0; offset < total; offset += maxChunkSize {
+		end := offset + maxChunkSize
+		if end > total {
+			end = total
+		}
+		frame := Packet(out[len(out) : len(out)+fragmentHeaderSize])
+		copy(frame, packet[:2+OptSizeData])
+		frame.SetDataSize(uint16(fragmentHeaderSize - 2 + end - offset))
+		frame.SetDataFlag(FlagContinuation)
+		binary.BigEndian.PutUint32(frame[2+OptSizeData:], uint32(total))
+		binary.BigEndian.PutUint32(frame[6+OptSizeData:], uint32(offset))
+		out = append(out[:len(out)+fragmentHeaderSize], body[offset:end]...)
+	}
+	return out
+}
+
+// Reassembler reassemble the fragments of a large packet with the limits,
+// it keeps no state between packets and can be shared by sessions with the same limits
+type Reassembler struct {
+	maxSize int
+	timeout time.Duration
+}
+
+// NewReassembler create a Reassembler, the maxSize is the max size of a reassembled packet,
+// and the timeout is the max duration to receive all its fragments
+func NewReassembler(maxSize int, timeout time.Duration) *Reassembler {
+	return &Reassembler{maxSize: maxSize, timeout: timeout}
+}
+
+// IsFragment check whether the frame is a fragment of a large packet by the FlagContinuation and the fragment header.
+// the PROTOID isn't checked as it may be encrypted, eg: the MID 0xFF of a XOR encrypted large packet turns into 0.
+// a cmd carrying data, eg: a handshake, may have the flag bit set by its data, but its size doesn't match the header.
+func (this *Reassembler) IsFragment(frame []byte) bool {
+	pack := Packet(frame)
+	if len(pack) < fragmentHeaderSize || !pack.HasDataFlag(FlagContinuation) || int(pack.GetDataSize()) != len(pack)-2 {
+		return false
+	}
+	total := int(binary.BigEndian.Uint32(pack[2+OptSizeData:]))
+	offset := int(binary.BigEndian.Uint32(pack[6+OptSizeData:]))
+	// the body is split into full chunks except the last one
+	chunk := total - offset
+	if chunk > maxChunkSize {
+		chunk = maxChunkSize
+	}
+	return total > maxChunkSize && offset < total && offset%maxChunkSize == 0 && len(pack)-fragmentHeaderSize == chunk
+}
+
+// Reassemble reassemble a large packet from the first fragment and the following ones read by next before the deadline,
+// a frame returned by next may be reused after the following call.
+// the packet grows as the fragments arrive, so a peer declaring a large packet doesn't pin its whole size.
+// the FlagContinuation of the reassembled packet is cleared.
+func (this *Reassembler) Reassemble(first []byte, next func(deadline time.Time) ([]byte, error)) ([]byte, error) {
+	if len(first) < fragmentHeaderSize {
+		return nil, ErrInvalidFragment
+	}
+	total := int(binary.BigEndian.Uint32(first[2+OptSizeData:]))
+	if total == 0 || binary.BigEndian.Uint32(first[6+OptSizeData:]) != 0 {
+		return nil, ErrInvalidFragment
+	}
+	if 2+OptSizeData+total > this.maxSize {
+		return nil, errors.Wrapf(ErrMessageTooLarge, "size(%d>%d)", 2+OptSizeData+total, this.maxSize)
+	}
+
+	header := string(first[2 : 2+OptSizeData])
+	packet := make(Packet, 2+OptSizeData, 2+OptSizeData+len(first)-fragmentHeaderSize)
+	copy(packet[2:], header)
+
+	deadline := time.Now().Add(this.timeout)
+	frame := first
+	for {
+		chunk := frame[fragmentHeaderSize:]
+		if offset := len(packet) - 2 - OptSizeData; len(chunk) == 0 || offset+len(chunk) > total {
+			return nil, ErrInvalidFragment
+		}
+		packet = append(packet, chunk...)
+		offset := len(packet) - 2 - OptSizeData
+		if offset == total {
+			packet.resetDataSize()
+			packet.ClearDataFlag(FlagContinuation)
+			return packet, nil
+		}
+
+		var err error
+		frame, err = next(deadline)
+		if time.Now().After(deadline) {
+			return nil, ErrFragmentTimeout
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, "read fragment")
+		}
+		// the fragments of a packet are consecutive and share the header
+		if !this.IsFragment(frame) ||
+			string(frame[2:2+OptSizeData]) != header ||
+			int(binary.BigEndian.Uint32(frame[2+OptSizeData:])) != total ||
+			int(binary.BigEndian.Uint32(frame[6+OptSizeData:])) != offset {
+			return nil, ErrInvalidFragment
+		}
+	}
+}
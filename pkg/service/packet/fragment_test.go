@@ -0,0 +1,169 @@
+package packet_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+// splitFrames split the written bytes into frames by their size headers
+func splitFrames(b []byte) [][]byte {
+	var frames [][]byte
+	for len(b) > 0 {
+		size := 2 + int(b[0])<<8 + int(b[1])
+		frames = append(frames, b[:size])
+		b = b[size:]
+	}
+	return frames
+}
+
+func reassemble(r *packet.Reassembler, frames [][]byte) ([]byte, error) {
+	next := 1
+	return r.Reassemble(frames[0], func(time.Time) ([]byte, error) {
+		if next >= len(frames) {
+			return nil, io.EOF
+		}
+		next++
+		return frames[next-1], nil
+	})
+}
+
+func TestFragment(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 20000)
+	pack := newDataPacket(string(data))
+	if !pack.IsLarge() || pack.GetDataSize() != packet.LargeDataSize {
+		t.Fatalf("expect a large packet, size = %d", pack.GetDataSize())
+	}
+
+	frames := splitFrames(pack.Fragment())
+	if len(frames) != 7 {
+		t.Fatalf("fragments = %d", len(frames))
+	}
+	r := packet.NewReassembler(packet.MaxMessageSize, time.Second)
+	for _, frame := range frames {
+		if len(frame) > packet.MaxPacketSize || !r.IsFragment(frame) {
+			t.Fatalf("invalid fragment, size = %d", len(frame))
+		}
+	}
+
+	out, err := reassemble(r, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := packet.Packet(out)
+	if !bytes.Equal(opened, pack) || opened.HasDataFlag(packet.FlagContinuation) ||
+		!bytes.Equal(opened.GetDataLoad(), data) || opened.GetConnID() != 101 {
+		t.Errorf("reassembled packet differs, size = %d", len(opened))
+	}
+
+	// a small packet isn't split
+	small := newDataPacket("hello")
+	if frame := small.Fragment(); !bytes.Equal(frame, small) || r.IsFragment(frame) {
+		t.Errorf("small packet = %v", frame)
+	}
+}
+
+func TestFragmentXOR(t *testing.T) {
+	// the MID of a XOR encrypted large packet is encrypted with the high byte of LargeDataSize
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	pack := newDataPacket(string(data))
+	pack.SetProtoMID(0xFF)
+	sealed, err := pack.Encrypt(packet.XORCrypto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sealed.IsCmdProto() {
+		t.Fatal("the encrypted MID should look like a cmd")
+	}
+
+	frames := splitFrames(sealed.Fragment())
+	r := packet.NewReassembler(packet.MaxMessageSize, time.Second)
+	for _, frame := range frames {
+		if !r.IsFragment(frame) {
+			t.Fatalf("invalid fragment, size = %d", len(frame))
+		}
+	}
+	out, err := reassemble(r, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, _ := packet.Packet(out).Decrypt(packet.XORCrypto)
+	if opened.GetProtoMID() != 0xFF || !bytes.Equal(opened.GetDataLoad(), data) {
+		t.Errorf("reassembled packet differs, mid = %d, size = %d", opened.GetProtoMID(), len(opened))
+	}
+
+	// a cmd with the flag bit set by its data isn't a fragment
+	public := bytes.Repeat([]byte{0xFF}, packet.PublicKeySize)
+	if hello := packet.NewHandshake(7, public, packet.HandshakeMAC(public, nil, ""), ""); r.IsFragment(hello) {
+		t.Error("a handshake shouldn't be a fragment")
+	}
+}
+
+func TestReassembleLimits(t *testing.T) {
+	pack := newDataPacket(string(bytes.Repeat([]byte("a"), 100000)))
+	frames := splitFrames(pack.Fragment())
+
+	// too large
+	if _, err := reassemble(packet.NewReassembler(64*1024, time.Second), frames); errors.Cause(err) != packet.ErrMessageTooLarge {
+		t.Errorf("expect too large error, got %v", err)
+	}
+
+	r := packet.NewReassembler(packet.MaxMessageSize, time.Second)
+	// a lost fragment
+	lost := [][]byte{frames[0], frames[2], frames[3], frames[1]}
+	if _, err := reassemble(r, lost); err != packet.ErrInvalidFragment {
+		t.Errorf("expect invalid fragment, got %v", err)
+	}
+	// another packet interleaved
+	other := splitFrames(newDataPacket(string(bytes.Repeat([]byte("b"), 100000))).Fragment())
+	other[1][3] = 0xFF // another conn id
+	if _, err := reassemble(r, [][]byte{frames[0], other[1]}); err != packet.ErrInvalidFragment {
+		t.Errorf("expect invalid fragment, got %v", err)
+	}
+	// truncated
+	if _, err := reassemble(r, frames[:2]); err == nil {
+		t.Error("expect read error")
+	}
+
+	// timeout
+	r = packet.NewReassembler(packet.MaxMessageSize, 10*time.Millisecond)
+	next := 1
+	_, err := r.Reassemble(frames[0], func(time.Time) ([]byte, error) {
+		time.Sleep(20 * time.Millisecond)
+		next++
+		return frames[next-1], nil
+	})
+	if err != packet.ErrFragmentTimeout {
+		t.Errorf("expect timeout, got %v", err)
+	}
+	// the reader stops at the deadline
+	_, err = r.Reassemble(frames[0], func(deadline time.Time) ([]byte, error) {
+		time.Sleep(time.Until(deadline) + time.Millisecond)
+		return nil, errors.New("i/o timeout")
+	})
+	if err != packet.ErrFragmentTimeout {
+		t.Errorf("expect timeout when the reader stops, got %v", err)
+	}
+}
+
+func TestAEADLargePacket(t *testing.T) {
+	client, server := newAEADPair(t, packet.CipherAESGCM)
+	data := bytes.Repeat([]byte("x"), 70000)
+	sealed, err := client.Encrypt(newDataPacket(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := reassemble(packet.NewReassembler(packet.MaxMessageSize, time.Second), splitFrames(sealed.Fragment()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := server.Decrypt(out)
+	if err != nil || !bytes.Equal(opened.GetDataLoad(), data) {
+		t.Errorf("opened size = %d, err = %v", len(opened), err)
+	}
+}
@@ -0,0 +1,138 @@
+package packet
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/curve25519"
+)
+
+// the size of a X25519 public key
+const PublicKeySize = 32
+
+// the size of the MAC authenticating a handshake, a hmac-sha256
+const HandshakeMACSize = sha256.Size
+
+// the HKDF info of the session keys
+var (
+	cryptoKeyInfo = []byte("bgo session crypto key")
+	signKeyInfo   = []byte("bgo session sign key")
+)
+
+// error definitions
+var (
+	ErrInvalidHandshake    = errors.New("invalid handshake")
+	ErrInvalidPublicKey    = errors.New("invalid public key")
+	ErrInvalidHandshakeMAC = errors.New("invalid handshake mac")
+)
+
+// NewHandshake create a HandshakePacket carrying the public key, the MAC and the chosen cipher,
+// which is DATASIZE + CONNID + PROTOID + PUBLICKEY + MAC + CIPHER,
+// the client sends its public key only, and the server replies with the cipher of the session.
+func NewHandshake(sid uint32, public, mac []byte, cipherName string) Packet {
+	data := make([]byte, 0, len(public)+len(mac)+len(cipherName))
+	data = append(append(append(data, public...), mac...), cipherName...)
+	return NewCmd(CmdHandshake, sid, data)
+}
+
+// ParseHandshake parse the public key, the MAC and the cipher in a HandshakePacket
+func ParseHandshake(packet Packet) (public, mac []byte, cipherName string, err error) {
+	if !packet.IsValid() || packet.GetCmd() != CmdHandshake {
+		return nil, nil, "", ErrInvalidHandshake
+	}
+	data := packet.GetCmdData()
+	if len(data) < PublicKeySize+HandshakeMACSize {
+		return nil, nil, "", ErrInvalidHandshake
+	}
+	return data[:PublicKeySize], data[PublicKeySize : PublicKeySize+HandshakeMACSize],
+		string(data[PublicKeySize+HandshakeMACSize:]), nil
+}
+
+// HandshakeMAC compute the MAC of a handshake with the global sign secret, the client authenticates
+// its public key, and the server authenticates both public keys and the cipher in the reply.
+// The key agreement is bound to the pre-shared secret, so a man in the middle can't replace the public keys.
+func HandshakeMAC(clientPublic, serverPublic []byte, cipherName string) []byte {
+	mac := hmac.New(sha256.New, defaultSignSecret)
+	mac.Write(clientPublic)
+	mac.Write(serverPublic)
+	mac.Write([]byte(cipherName))
+	return mac.Sum(nil)
+}
+
+// VerifyHandshakeMAC verify the MAC of a handshake, ErrInvalidHandshakeMAC is returned if it mismatches
+func VerifyHandshakeMAC(mac, clientPublic, serverPublic []byte, cipherName string) error {
+	if !hmac.Equal(mac, HandshakeMAC(clientPublic, serverPublic, cipherName)) {
+		return ErrInvalidHandshakeMAC
+	}
+	return nil
+}
+
+// SessionKeys the keys of a session derived from the key agreement
+type SessionKeys struct {
+	Crypto []byte // the key of the AEADCrypto
+	Sign   []byte // the secret of the signature
+}
+
+// NewCrypto create an AEADCrypto with the session key
+func (this *SessionKeys) NewCrypto(cipherName string, isServer bool) (*AEADCrypto, error) {
+	return NewAEADCrypto(cipherName, this.Crypto, isServer)
+}
+
+// NewSignature create a signature with the session secret instead of the global one
+func (this *SessionKeys) NewSignature() ISignature {
+	return NewHMACSha1Signature(this.Sign)
+}
+
+// KeyExchange a X25519 key agreement, create one for each handshake
+type KeyExchange struct {
+	private [32]byte
+	public  [32]byte
+}
+
+// NewKeyExchange create a KeyExchange with a random private key
+func NewKeyExchange() (*KeyExchange, error) {
+	kx := &KeyExchange{}
+	if _, err := io.ReadFull(rand.Reader, kx.private[:]); err != nil {
+		return nil, err
+	}
+	curve25519.ScalarBaseMult(&kx.public, &kx.private)
+	return kx, nil
+}
+
+// PublicKey get the public key sent to the peer
+func (kx *KeyExchange) PublicKey() []byte { return append([]byte{}, kx.public[:]...) }
+
+// SessionKeys derive the session keys from the shared secret with the peer's public key,
+// isServer should be true on the server side and false on the client side
+func (kx *KeyExchange) SessionKeys(peer []byte, isServer bool) (*SessionKeys, error) {
+	if len(peer) != PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	var peerKey, shared [32]byte
+	copy(peerKey[:], peer)
+	curve25519.ScalarMult(&shared, &kx.private, &peerKey)
+	// a low order point results in an all-zero secret
+	var zero [32]byte
+	if subtle.ConstantTimeCompare(shared[:], zero[:]) == 1 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	// both sides salt the keys with the public keys in the same order
+	salt := append(kx.PublicKey(), peer...)
+	if isServer {
+		salt = append(append([]byte{}, peer...), kx.public[:]...)
+	}
+	cryptoKey, err := DeriveSessionKey(shared[:], salt, cryptoKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+	signKey, err := DeriveSessionKey(shared[:], salt, signKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionKeys{Crypto: cryptoKey, Sign: signKey}, nil
+}
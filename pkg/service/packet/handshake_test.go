@@ -0,0 +1,103 @@
+package packet_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+func TestKeyExchange(t *testing.T) {
+	client, err := packet.NewKeyExchange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := packet.NewKeyExchange()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client sends its public key
+	hello := packet.NewHandshake(7, client.PublicKey(), packet.HandshakeMAC(client.PublicKey(), nil, ""), "")
+	public, mac, cipherName, err := packet.ParseHandshake(hello)
+	if err != nil || !bytes.Equal(public, client.PublicKey()) || cipherName != "" {
+		t.Fatalf("public = %x, cipher = %s, err = %v", public, cipherName, err)
+	}
+	if err := packet.VerifyHandshakeMAC(mac, public, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	serverKeys, err := server.SessionKeys(public, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server replies its public key and the cipher
+	mac = packet.HandshakeMAC(public, server.PublicKey(), packet.CipherChaCha20Poly1305)
+	reply := packet.NewHandshake(7, server.PublicKey(), mac, packet.CipherChaCha20Poly1305)
+	if !reply.IsCmdProto() || reply.GetCmd() != packet.CmdHandshake || reply.GetConnID() != 7 {
+		t.Fatalf("invalid reply %v", reply)
+	}
+	public, mac, cipherName, err = packet.ParseHandshake(reply)
+	if err != nil || cipherName != packet.CipherChaCha20Poly1305 {
+		t.Fatalf("cipher = %s, err = %v", cipherName, err)
+	}
+	if err := packet.VerifyHandshakeMAC(mac, client.PublicKey(), public, cipherName); err != nil {
+		t.Fatal(err)
+	}
+	clientKeys, err := client.SessionKeys(public, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(clientKeys.Crypto, serverKeys.Crypto) || !bytes.Equal(clientKeys.Sign, serverKeys.Sign) {
+		t.Error("the session keys differ")
+	}
+	if bytes.Equal(clientKeys.Crypto, clientKeys.Sign) {
+		t.Error("the crypto key and the sign key should differ")
+	}
+
+	// the session signature doesn't depend on the global secret
+	packet.SetSignSecret([]byte("global"))
+	a, _ := clientKeys.NewSignature().Sum([]byte("token"), []byte("data"))
+	b, _ := packet.HMACSha1Signature.Sum([]byte("token"), []byte("data"))
+	if bytes.Equal(a, b) {
+		t.Error("the session signature should use the session secret")
+	}
+}
+
+func TestKeyExchangeInvalid(t *testing.T) {
+	kx, err := packet.NewKeyExchange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kx.SessionKeys(make([]byte, packet.PublicKeySize), true); err != packet.ErrInvalidPublicKey {
+		t.Errorf("expect invalid public key for a low order point, got %v", err)
+	}
+	if _, err := kx.SessionKeys([]byte("short"), true); err != packet.ErrInvalidPublicKey {
+		t.Errorf("expect invalid public key, got %v", err)
+	}
+	if _, _, _, err := packet.ParseHandshake(packet.NewRegister(7)); err != packet.ErrInvalidHandshake {
+		t.Errorf("expect invalid handshake, got %v", err)
+	}
+}
+
+func TestHandshakeMAC(t *testing.T) {
+	packet.SetSignSecret([]byte("global"))
+	client, _ := packet.NewKeyExchange()
+	server, _ := packet.NewKeyExchange()
+	mac := packet.HandshakeMAC(client.PublicKey(), server.PublicKey(), packet.CipherAESGCM)
+
+	// the public keys and the cipher are all authenticated
+	if err := packet.VerifyHandshakeMAC(mac, server.PublicKey(), client.PublicKey(), packet.CipherAESGCM); err != packet.ErrInvalidHandshakeMAC {
+		t.Errorf("swapped public keys err = %v", err)
+	}
+	if err := packet.VerifyHandshakeMAC(mac, client.PublicKey(), server.PublicKey(), packet.CipherChaCha20Poly1305); err != packet.ErrInvalidHandshakeMAC {
+		t.Errorf("downgraded cipher err = %v", err)
+	}
+
+	// a MAC without the secret is invalid
+	packet.SetSignSecret([]byte("another"))
+	if err := packet.VerifyHandshakeMAC(mac, client.PublicKey(), server.PublicKey(), packet.CipherAESGCM); err != packet.ErrInvalidHandshakeMAC {
+		t.Errorf("another secret err = %v", err)
+	}
+}
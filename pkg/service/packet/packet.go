@@ -29,19 +29,31 @@ const (
 	OptSizeCmd    = 6
 	OptSizeData   = 8
 	MaxPacketSize = 32 * 1024
+	// MaxMessageSize the default max size of a large packet split into fragments
+	MaxMessageSize = 1024 * 1024
+	// LargeDataSize the DATASIZE of a large packet whose payload exceeds the uint16,
+	// the size of its payload is the length of the packet
+	LargeDataSize = 0xFFFF
 )
 
 // data flags
 const (
-	FlagZLIB     = 0x01
-	FlagXOR      = 0x02
-	FlagHMACSha1 = 0x04
+	FlagZLIB       = 0x01
+	FlagXOR        = 0x02
+	FlagHMACSha1   = 0x04
+	FlagHMACSha256 = 0x08
+	FlagAEAD       = 0x10
+	FlagSnappy     = 0x20
+	FlagZstd       = 0x40
+	// FlagContinuation a fragment of a large packet, followed by a fragment header
+	FlagContinuation = 0x80
 )
 
 // cmd id
 const (
-	CmdPing     = 0x0000
-	CmdRegister = 0x0001
+	CmdPing      = 0x0000
+	CmdRegister  = 0x0001
+	CmdHandshake = 0x0002
 )
 
 // error definitions
@@ -59,6 +71,14 @@ func New(datasize uint16) Packet {
 	return pack
 }
 
+// newLarge create a Packet whose payload may exceed the MaxPacketSize,
+// it's split into fragments when it's written
+func newLarge(datasize int) Packet {
+	pack := Packet(make([]byte, 2+datasize))
+	pack.resetDataSize()
+	return pack
+}
+
 // Check check whether it's a valid packet
 func Check(b []byte) (uint16, error) {
 	dataLen := len(b)
@@ -78,21 +98,28 @@ func NewFromBytes(b []byte) (Packet, error) {
 	return Packet(b[:dataSize]), err
 }
 
-// NewFromData create a Packet from a data
+// NewFromData create a Packet from a data, the sign is a hmac-sha1 signature.
+// a payload exceeding the MaxPacketSize makes a large packet, which is split into fragments by Fragment
+// when it's written, and reassembled by the Reassembler of the reader.
 func NewFromData(data, sign []byte, compressor ICompresser) Packet {
+	return NewFromSignedData(data, sign, FlagHMACSha1, compressor)
+}
+
+// NewFromSignedData create a Packet from a data with the sign of the algorithm flag, FlagHMACSha1 or FlagHMACSha256
+func NewFromSignedData(data, sign []byte, signFlag uint8, compressor ICompresser) Packet {
 	var compressed bool
 	data, compressed = compressor.Compress(data)
 	if signSize := len(sign); signSize > 0 {
-		packet := New(uint16(OptSizeData + 1 + signSize + len(data)))
-		packet.SetZlibCompressed(compressed)
-		packet.SetDataFlag(FlagHMACSha1)
+		packet := newLarge(OptSizeData + 1 + signSize + len(data))
+		packet.setCompressed(compressed, compressor)
+		packet.SetDataFlag(signFlag)
 		packet.SetDataSign(sign)
 		packet.SetDataLoad(data)
 		compressor.Close()
 		return packet
 	}
-	packet := New(uint16(OptSizeData + len(data)))
-	packet.SetZlibCompressed(compressed)
+	packet := newLarge(OptSizeData + len(data))
+	packet.setCompressed(compressed, compressor)
 	packet.SetDataLoad(data)
 	compressor.Close()
 	return packet
@@ -119,6 +146,27 @@ func NewRegister(sid uint32) Packet {
 	return packet
 }
 
+// NewRegisterWithData create a RegisterPacket carrying the data,
+// which is DATASIZE + CONNID + PROTOID + DATA
+func NewRegisterWithData(sid uint32, data []byte) Packet {
+	return NewCmd(CmdRegister, sid, data)
+}
+
+// NewCmd create a cmd packet carrying the data,
+// which is DATASIZE + CONNID + PROTOID + DATA
+func NewCmd(cmd uint16, sid uint32, data []byte) Packet {
+	packet := New(OptSizeCmd + uint16(len(data)))
+	packet.SetConnID(sid)
+	packet.SetProtoID(cmd)
+	copy(packet[2+OptSizeCmd:], data)
+	return packet
+}
+
+// GetCmdData get the data following the cmd, empty if it's only a cmd
+func (packet Packet) GetCmdData() []byte {
+	return packet[2+OptSizeCmd:]
+}
+
 // MakeProtoID make a proto id by the mid and aid
 func MakeProtoID(mid, aid uint8) uint16 {
 	return uint16(mid)<<8 + uint16(aid)
@@ -134,14 +182,14 @@ func (packet Packet) IsValid() bool {
 	return len(packet) >= (2 + OptSizeCmd)
 }
 
-// Encrypt encrypt the packet
-func (packet Packet) Encrypt(crypto ICrypto) {
-	crypto.Encrypt(packet)
+// Encrypt encrypt the packet, use the returned packet instead of the old one
+func (packet Packet) Encrypt(crypto ICrypto) (Packet, error) {
+	return crypto.Encrypt(packet)
 }
 
-// Decrypt decrypt the packet
-func (packet Packet) Decrypt(crypto ICrypto) {
-	crypto.Decrypt(packet)
+// Decrypt decrypt the packet, use the returned packet instead of the old one
+func (packet Packet) Decrypt(crypto ICrypto) (Packet, error) {
+	return crypto.Decrypt(packet)
 }
 
 // IsCmdSize check whether is a cmd packet's size
@@ -159,6 +207,15 @@ func (packet Packet) SetDataSize(datasize uint16) {
 	binary.BigEndian.PutUint16(packet[:2], datasize)
 }
 
+// resetDataSize reset the size of packet's payload by its length, LargeDataSize if it exceeds the uint16
+func (packet Packet) resetDataSize() {
+	datasize := len(packet) - 2
+	if datasize > LargeDataSize {
+		datasize = LargeDataSize
+	}
+	packet.SetDataSize(uint16(datasize))
+}
+
 // GetConnID get the connection id
 func (packet Packet) GetConnID() uint32 {
 	return binary.BigEndian.Uint32(packet[2:6])
@@ -237,8 +294,8 @@ func (packet Packet) HasDataFlag(flag uint8) bool {
 
 // ClearDataFlag clear the data flag
 func (packet Packet) ClearDataFlag(flag uint8) {
-	// FIXME: cannot clear the signature bits, a mark will be ok
-	packet[9] &^= flag
+	// the signature bits locate the dataload, they can't be cleared
+	packet[9] &^= flag &^ (FlagHMACSha1 | FlagHMACSha256)
 }
 
 // ResetDataFlag reset the data flag
@@ -248,7 +305,7 @@ func (packet Packet) ResetDataFlag(flag uint8) {
 
 // HasDataSign check whether it's a signature
 func (packet Packet) HasDataSign() bool {
-	return packet[9]&0x0C != 0
+	return packet[9]&(FlagHMACSha1|FlagHMACSha256) != 0
 }
 
 // SetZlibCompressed set the data flag: ZLIB
@@ -258,6 +315,22 @@ func (packet Packet) SetZlibCompressed(compressed bool) {
 	}
 }
 
+func (packet Packet) setCompressed(compressed bool, compressor ICompresser) {
+	if compressed {
+		packet.SetDataFlag(compressor.Flag())
+	}
+}
+
+// IsCompressed check whether it's compressed by any codec
+func (packet Packet) IsCompressed() bool {
+	return packet[9]&codecFlags != 0
+}
+
+// DecompressDataLoad get the packet's dataload decompressed by the codec in the data flag
+func (packet Packet) DecompressDataLoad() ([]byte, error) {
+	return Decompress(packet.GetDataFlag(), packet.GetDataLoad())
+}
+
 // IsZlibCompressed check whether it's zlib-compressed
 func (packet Packet) IsZlibCompressed() bool {
 	return packet.HasDataFlag(FlagZLIB)
@@ -3,6 +3,10 @@ package packet
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+
+	"github.com/pkg/errors"
 )
 
 var (
@@ -14,19 +18,66 @@ func SetSignSecret(sec []byte) {
 	defaultSignSecret = append([]byte{}, sec...)
 }
 
+// ErrUnknownSignature the data flag has no signature algorithm
+var ErrUnknownSignature = errors.New("unknown signature")
+
 // ISignature a signature to calculate the sum
 type ISignature interface {
 	Sum(token, data []byte) ([]byte, error)
 }
 
-type hmacSha1Signature struct{}
+type hmacSignature struct {
+	hash   func() hash.Hash
+	secret []byte // the global secret is used if nil
+}
+
+// HMACSha1Signature a hmac-sha1 signature with the global secret
+var HMACSha1Signature ISignature = hmacSignature{hash: sha1.New}
 
-// HMACSha1Signature a hmac-sha1 signature
-var HMACSha1Signature ISignature = hmacSha1Signature{}
+// HMACSha256Signature a hmac-sha256 signature with the global secret
+var HMACSha256Signature ISignature = hmacSignature{hash: sha256.New}
+
+// NewHMACSha1Signature create a hmac-sha1 signature with a secret, eg: the secret of a session
+func NewHMACSha1Signature(secret []byte) ISignature {
+	return hmacSignature{hash: sha1.New, secret: append([]byte{}, secret...)}
+}
+
+// NewHMACSha256Signature create a hmac-sha256 signature with a secret, eg: the secret of a session
+func NewHMACSha256Signature(secret []byte) ISignature {
+	return hmacSignature{hash: sha256.New, secret: append([]byte{}, secret...)}
+}
+
+// NewSignature create the signature of the algorithm in the data flag,
+// FlagHMACSha256 or FlagHMACSha1, the global secret is used if the secret is nil
+func NewSignature(flag uint8, secret []byte) (ISignature, error) {
+	var sig hmacSignature
+	switch {
+	case flag&FlagHMACSha256 != 0:
+		sig.hash = sha256.New
+	case flag&FlagHMACSha1 != 0:
+		sig.hash = sha1.New
+	default:
+		return nil, ErrUnknownSignature
+	}
+	if secret != nil {
+		sig.secret = append([]byte{}, secret...)
+	}
+	return sig, nil
+}
+
+// SignedRouteData get the data signed for a request of a route, the MID and the AID are bound with the dataload,
+// so a signature of a route is invalid for the same dataload sent to another route
+func SignedRouteData(mid, aid uint8, data []byte) []byte {
+	return append([]byte{mid, aid}, data...)
+}
 
 // Sum calculate the signature of the dataload
-func (hmacSha1Signature) Sum(token, data []byte) ([]byte, error) {
-	hmac := hmac.New(sha1.New, append(defaultSignSecret, token...))
+func (this hmacSignature) Sum(token, data []byte) ([]byte, error) {
+	secret := this.secret
+	if secret == nil {
+		secret = defaultSignSecret
+	}
+	hmac := hmac.New(this.hash, append(append([]byte{}, secret...), token...))
 	_, err := hmac.Write(data)
 	if err == nil {
 		return hmac.Sum(nil), nil
@@ -0,0 +1,29 @@
+package packet_test
+
+import (
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+func TestSignedPacket(t *testing.T) {
+	sign := make([]byte, 32)
+	pack := packet.NewFromSignedData([]byte("hello"), sign, packet.FlagHMACSha256, packet.NoneCompresser)
+	if !pack.HasDataSign() || len(pack.GetDataSign()) != 32 || string(pack.GetDataLoad()) != "hello" {
+		t.Fatalf("invalid signed packet %v", pack)
+	}
+
+	// the signature bits locate the dataload
+	pack.ClearDataFlag(packet.FlagHMACSha256 | packet.FlagXOR)
+	if !pack.HasDataFlag(packet.FlagHMACSha256) || string(pack.GetDataLoad()) != "hello" {
+		t.Errorf("the signature bits are cleared %v", pack)
+	}
+
+	if _, err := packet.NewSignature(packet.FlagXOR, nil); err != packet.ErrUnknownSignature {
+		t.Errorf("expect unknown signature, got %v", err)
+	}
+	sum, err := packet.HMACSha256Signature.Sum([]byte("token"), []byte("hello"))
+	if err != nil || len(sum) != 32 {
+		t.Errorf("sum = %x, err = %v", sum, err)
+	}
+}
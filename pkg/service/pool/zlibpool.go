@@ -3,6 +3,8 @@ package pool
 import (
 	"bytes"
 	"compress/zlib"
+	"errors"
+	"io"
 )
 
 // ByteBuf a bytes buffer
@@ -125,3 +127,76 @@ func (zp *ZlibWriterPool) put(w *ZlibWriter) {
 		}
 	}
 }
+
+// ErrTooLarge the decompressed data exceeds the limit
+var ErrTooLarge = errors.New("decompressed data too large")
+
+// ZlibReader a zlib reader containg a reader and a buffer
+type ZlibReader struct {
+	buffer *bytes.Buffer
+	reader io.ReadCloser // zlib.Resetter as well
+
+	pool *ZlibReaderPool
+}
+
+// Decompress decompress a compressed bytes of data, the result is valid until Free,
+// ErrTooLarge is returned if the result exceeds the maxSize
+func (r *ZlibReader) Decompress(b []byte, maxSize int) ([]byte, error) {
+	r.buffer.Reset()
+	var err error
+	if r.reader == nil {
+		r.reader, err = zlib.NewReader(bytes.NewReader(b))
+	} else {
+		err = r.reader.(zlib.Resetter).Reset(bytes.NewReader(b), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	n, err := r.buffer.ReadFrom(io.LimitReader(r.reader, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > int64(maxSize) {
+		return nil, ErrTooLarge
+	}
+	return r.buffer.Bytes(), nil
+}
+
+// Free free its underlying resource
+func (r *ZlibReader) Free() {
+	if r.pool != nil {
+		r.pool.put(r)
+	}
+}
+
+// ZlibReaderPool a *ZlibReader pool
+type ZlibReaderPool struct {
+	pool chan *ZlibReader
+}
+
+// NewZlibReaderPool create a ZlibReaderPool struct
+func NewZlibReaderPool(size int) *ZlibReaderPool {
+	return &ZlibReaderPool{pool: make(chan *ZlibReader, size)}
+}
+
+// Get get a free *ZlibReader from the pool
+func (zp *ZlibReaderPool) Get() *ZlibReader {
+	var r *ZlibReader
+	select {
+	case r = <-zp.pool:
+	default:
+		r = &ZlibReader{buffer: new(bytes.Buffer), pool: zp}
+	}
+	return r
+}
+
+// Put put a free *ZlibReader to the pool and if full pool, discard it.
+func (zp *ZlibReaderPool) put(r *ZlibReader) {
+	if r != nil {
+		select {
+		case zp.pool <- r:
+		default:
+			// do nothing, just discard
+		}
+	}
+}
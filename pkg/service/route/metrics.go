@@ -0,0 +1,20 @@
+package route
+
+import (
+	"strconv"
+
+	"github.com/overtalk/bgo/pkg/metrics"
+)
+
+var (
+	dispatchDuration = metricspkg.NewHistogramVec("bgo_route_dispatch_seconds", "Latency of dispatching requests of each module & action.", nil, "mid", "aid")
+	dispatchTimeouts = metricspkg.NewCounterVec("bgo_route_dispatch_timeouts_total", "Total number of timed out requests of each module & action.", "mid", "aid")
+)
+
+func init() {
+	metricspkg.MustRegister(dispatchDuration, dispatchTimeouts)
+}
+
+func routeLabels(mid, aid uint8) (string, string) {
+	return strconv.Itoa(int(mid)), strconv.Itoa(int(aid))
+}
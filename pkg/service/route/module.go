@@ -48,7 +48,7 @@ func (m *baseModule) Handle(r IRequest) IOutProtocol {
 	act, ok := m.actions[actionID]
 	if !ok {
 		act = NoneAction
-		//zaplog.S.Errorf("module %d: action(%d) not found", m.mid, actionID)
+		GetLogger(r).Error("module: action not found")
 	}
 	return act.Handle(r)
 }
@@ -1,5 +1,11 @@
 package route
 
+import (
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/pkg/log"
+)
+
 // IRequest client request
 type IRequest interface {
 	GetMID() uint8
@@ -8,3 +14,18 @@ type IRequest interface {
 	GetData() []byte
 	GetSign() []byte
 }
+
+// ILogRequest a request carrying a request-scoped logger, eg: session.Request
+type ILogRequest interface {
+	GetLogger() *zap.Logger
+}
+
+// GetLogger get the logger of the request, the global logger is returned if it doesn't carry one
+func GetLogger(r IRequest) *zap.Logger {
+	if lr, ok := r.(ILogRequest); ok {
+		if logger := lr.GetLogger(); logger != nil {
+			return logger
+		}
+	}
+	return logpkg.Named("route").With(zap.Uint8(logpkg.KeyMID, r.GetMID()), zap.Uint8(logpkg.KeyAID, r.GetAID()))
+}
@@ -2,6 +2,8 @@ package route
 
 import (
 	"time"
+
+	"go.uber.org/zap"
 )
 
 // IRouteEnabler enable or disable some routes
@@ -16,6 +18,38 @@ func (*fullRouteEnabler) Enabled(_ uint8, _ uint8) bool { return true }
 // FullRouteEnabler a fullRouteEnabler struct
 var FullRouteEnabler IRouteEnabler = &fullRouteEnabler{}
 
+// ISignedRoutes mark the routes whose requests must be signed
+type ISignedRoutes interface {
+	Signed(uint8, uint8) bool
+}
+
+// SignedRoutes the routes marked as signed, a whole module or some actions
+type SignedRoutes struct {
+	modules map[uint8]bool
+	actions map[uint16]bool
+}
+
+// NewSignedRoutes create a SignedRoutes struct
+func NewSignedRoutes() *SignedRoutes {
+	return &SignedRoutes{modules: map[uint8]bool{}, actions: map[uint16]bool{}}
+}
+
+// Add mark the actions of a module as signed, all actions of the module if no action given
+func (s *SignedRoutes) Add(mid uint8, aids ...uint8) *SignedRoutes {
+	if len(aids) == 0 {
+		s.modules[mid] = true
+	}
+	for _, aid := range aids {
+		s.actions[uint16(mid)<<8+uint16(aid)] = true
+	}
+	return s
+}
+
+// Signed check whether the route is signed
+func (s *SignedRoutes) Signed(mid uint8, aid uint8) bool {
+	return s.modules[mid] || s.actions[uint16(mid)<<8+uint16(aid)]
+}
+
 // ITimeouter wait a while and return a timeout proto.Message
 type ITimeouter interface {
 	Timeout() time.Duration
@@ -28,6 +62,7 @@ type Router struct {
 	enabler  IRouteEnabler
 	timeout  ITimeouter
 	noneResp IOutProtocol
+	signed   ISignedRoutes
 }
 
 // RouterOptionFunc set the Router's option
@@ -54,9 +89,16 @@ func OptionNoneResponse(resp IOutProtocol) RouterOptionFunc {
 	}
 }
 
+// OptionSignedRoutes set Router's signed routes, the requests of them are verified before dispatching
+func OptionSignedRoutes(signed ISignedRoutes) RouterOptionFunc {
+	return func(r *Router) {
+		r.signed = signed
+	}
+}
+
 // NewRouter create a Router struct
 func NewRouter(opts ...RouterOptionFunc) *Router {
-	router := &Router{map[uint8]IModule{}, FullRouteEnabler, nil, nil}
+	router := &Router{map[uint8]IModule{}, FullRouteEnabler, nil, nil, nil}
 	for _, opt := range opts {
 		opt(router)
 	}
@@ -70,24 +112,31 @@ func (router *Router) Register(modules ...IModule) {
 	}
 }
 
+// NoneResponse get the response of the requests not dispatched, eg: failing the verification, nil if not set
+func (router *Router) NoneResponse() IOutProtocol { return router.noneResp }
+
+// IsSigned check whether the requests of a route must be signed
+func (router *Router) IsSigned(mid, aid uint8) bool {
+	return router.signed != nil && router.signed.Signed(mid, aid)
+}
+
 // Dispatch dispatch each client's request
 func (router *Router) Dispatch(r IRequest) (IOutProtocol, bool) {
 	moduleID := r.GetMID()
 	actionID := r.GetAID()
+	mid, aid := routeLabels(moduleID, actionID)
+	defer dispatchDuration.With(mid, aid).ObserveSince(time.Now())
 
 	var module IModule
 	if router.enabler.Enabled(moduleID, actionID) {
 		var ok bool
 		module, ok = router.modules[moduleID]
 		if !ok {
-			//TODO: log
-			//zaplog.S.Errorf("router: module(%d) not found", moduleID)
+			GetLogger(r).Error("router: module not found")
 			return router.noneResp, false
 		}
 	} else {
-		//TODO: log
-		//zaplog.S.Errorf(
-		//	"router: module(%d) action(%d) disabled", moduleID, actionID)
+		GetLogger(r).Error("router: route disabled")
 		return router.noneResp, false
 	}
 	if router.timeout == nil {
@@ -98,9 +147,7 @@ func (router *Router) Dispatch(r IRequest) (IOutProtocol, bool) {
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
-				//TODO: log
-				//zaplog.S.Error(err)
-				//zaplog.S.Error(zap.Stack("").String)
+				GetLogger(r).Error("router: handle panic", zap.Any("error", err), zap.Stack("stack"))
 			}
 		}()
 		result <- module.Handle(r)
@@ -109,6 +156,7 @@ func (router *Router) Dispatch(r IRequest) (IOutProtocol, bool) {
 	case pb := <-result:
 		return pb, false
 	case <-time.After(router.timeout.Timeout()):
+		dispatchTimeouts.With(mid, aid).Inc()
 		return router.timeout.Result(), true
 	}
 }
@@ -1,6 +1,9 @@
 package session
 
 import (
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/pkg/log"
 	"github.com/overtalk/bgo/pkg/service/packet"
 	"github.com/overtalk/bgo/pkg/service/zd"
 )
@@ -10,9 +13,14 @@ type Request struct {
 	PVer   uint8 // protocol version
 	MID    uint8 // module id
 	AID    uint8 // action id
+	Flag   uint8 // data flag, it carries the signature algorithm
 	Data   []byte
 	Sign   []byte
 	buffer zd.IPacketBuffer
+	logger *zap.Logger
+
+	// the secret of the signature, eg: the session secret, the global secret is used if nil
+	signSecret []byte
 }
 
 // GetMID get the mid
@@ -30,6 +38,23 @@ func (r *Request) GetData() []byte { return r.Data }
 // GetSign get the signature
 func (r *Request) GetSign() []byte { return r.Sign }
 
+// GetDataFlag get the data flag
+func (r *Request) GetDataFlag() uint8 { return r.Flag }
+
+// GetSignSecret get the secret of the signature
+func (r *Request) GetSignSecret() []byte { return r.signSecret }
+
+// SetSignSecret set the secret of the signature, eg: the secret negotiated by the session's handshake
+func (r *Request) SetSignSecret(secret []byte) { r.signSecret = secret }
+
+// GetLogger get the request-scoped logger, carrying the trace id, MID and AID
+func (r *Request) GetLogger() *zap.Logger { return r.logger }
+
+// SetLogger set the logger of the session, the MID and AID are added
+func (r *Request) SetLogger(logger *zap.Logger) {
+	r.logger = logger.With(zap.Uint8(logpkg.KeyMID, r.MID), zap.Uint8(logpkg.KeyAID, r.AID))
+}
+
 // Free free its underlying resource
 func (r *Request) Free() {
 	if r.buffer != nil {
@@ -44,35 +69,36 @@ func NewRequestFromClient(buffer zd.IPacketBuffer) *Request {
 		return nil
 	}
 	// try to decrypt a game packet using the XORCrypto
-	gamePacket.Decrypt(packet.XORCrypto)
-	if gamePacket.IsCmdSize() || gamePacket.IsCmdProto() {
+	gamePacket, err := gamePacket.Decrypt(packet.XORCrypto)
+	if err != nil || gamePacket.IsCmdSize() || gamePacket.IsCmdProto() {
 		return nil
 	}
-	var signature []byte
-	if gamePacket.HasDataSign() {
-		signature = gamePacket.GetDataSign()
-	}
-	return &Request{
-		MID:    gamePacket.GetProtoMID(),
-		AID:    gamePacket.GetProtoAID(),
-		PVer:   gamePacket.GetProtoVer(),
-		Data:   gamePacket.GetDataLoad(),
-		Sign:   signature,
-		buffer: buffer,
+	request := NewRequestFromAgent(gamePacket)
+	if request != nil {
+		request.buffer = buffer
 	}
+	return request
 }
 
-// NewRequestFromAgent create a Request from a AgentPacket
+// NewRequestFromAgent create a Request from a AgentPacket, the dataload is decompressed,
+// nil if it can't be decompressed
 func NewRequestFromAgent(pack packet.Packet) *Request {
 	var signature []byte
 	if pack.HasDataSign() {
 		signature = pack.GetDataSign()
 	}
+	data, err := pack.DecompressDataLoad()
+	if err != nil {
+		logpkg.Named("session").Error("decompress request error", zap.Uint8(logpkg.KeyMID, pack.GetProtoMID()),
+			zap.Uint8(logpkg.KeyAID, pack.GetProtoAID()), zap.Uint8("flag", pack.GetDataFlag()), zap.Error(err))
+		return nil
+	}
 	return &Request{
 		MID:  pack.GetProtoMID(),
 		AID:  pack.GetProtoAID(),
 		PVer: pack.GetProtoVer(),
-		Data: pack.GetDataLoad(),
+		Flag: pack.GetDataFlag(),
+		Data: data,
 		Sign: signature,
 	}
 }
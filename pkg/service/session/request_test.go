@@ -0,0 +1,46 @@
+package session
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+	"github.com/overtalk/bgo/pkg/service/route"
+)
+
+func TestRequestDecompress(t *testing.T) {
+	data := bytes.Repeat([]byte("hello"), 1000)
+	pack := packet.NewFromData(data, nil, packet.NewCodecCompresser(packet.FlagSnappy, 0))
+	if !pack.HasDataFlag(packet.FlagSnappy) {
+		t.Fatal("not compressed")
+	}
+	r := NewRequestFromAgent(pack)
+	if r == nil || !bytes.Equal(r.GetData(), data) {
+		t.Fatal("invalid decompressed request")
+	}
+
+	// a corrupted dataload
+	pack.GetDataLoad()[0] ^= 0xFF
+	if r := NewRequestFromAgent(pack); r != nil {
+		t.Error("expect nil for a corrupted dataload")
+	}
+}
+
+func TestResponseCompress(t *testing.T) {
+	packet.SetCryptoSecret([]byte("xor"))
+	data := bytes.Repeat([]byte("world"), 1000)
+	rsp := &Response{MID: 1, AID: 2, Result: route.BytesOutProtocol(data)}
+
+	var buf bytes.Buffer
+	if _, err := rsp.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pack, _ := packet.Packet(buf.Bytes()).Decrypt(packet.XORCrypto)
+	if !pack.IsCompressed() || len(pack) >= len(data) {
+		t.Fatalf("response not compressed, size = %d", len(pack))
+	}
+	decompressed, err := pack.DecompressDataLoad()
+	if err != nil || !bytes.Equal(decompressed, data) {
+		t.Errorf("decompressed size = %d, err = %v", len(decompressed), err)
+	}
+}
@@ -3,6 +3,9 @@ package session
 import (
 	"io"
 
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/pkg/log"
 	"github.com/overtalk/bgo/pkg/service/packet"
 	"github.com/overtalk/bgo/pkg/service/route"
 )
@@ -22,14 +25,16 @@ func (rsp *Response) WriteTo(w io.Writer) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	// TODO: log
-	//zaplog.S.Debugf("mid: %d, aid: %d, data: %v", rsp.MID, rsp.AID, out)
-	outPacket := packet.NewFromData(out, nil, packet.NoneCompresser)
+	logpkg.Debug("response", zap.Uint8(logpkg.KeyMID, rsp.MID), zap.Uint8(logpkg.KeyAID, rsp.AID), zap.Int("size", len(out)))
+	outPacket := packet.NewFromData(out, nil, packet.AutoCompresser())
 	outPacket.SetConnID(0)
 	outPacket.SetProtoMID(rsp.MID)
 	outPacket.SetProtoAID(rsp.AID)
 	outPacket.SetProtoVer(rsp.PVer)
 	outPacket.SetDataFlag(rsp.PFlag)
-	outPacket.Encrypt(packet.XORCrypto)
-	return w.Write(outPacket)
+	outPacket, err = outPacket.Encrypt(packet.XORCrypto)
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(outPacket.Fragment())
 }
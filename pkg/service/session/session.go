@@ -1,11 +1,16 @@
 package session
 
 import (
-	"github.com/overtalk/bgo/pkg/service/packet"
-	"github.com/overtalk/bgo/pkg/service/tunnel"
 	"net"
+	"time"
 
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
 	"github.com/overtalk/bgo/pkg/service/route"
+	"github.com/overtalk/bgo/pkg/service/tunnel"
+	"github.com/overtalk/bgo/utils/net"
 )
 
 func BuildServeFunc(optAgent int, router *route.Router) func(net.Conn) {
@@ -14,11 +19,27 @@ func BuildServeFunc(optAgent int, router *route.Router) func(net.Conn) {
 
 // AgentService an agent service
 type AgentService struct {
-	router *route.Router
+	router      *route.Router
+	cipher      string // the cipher negotiated in the handshake
+	verifier    ISignVerifier
+	reassembler *packet.Reassembler // nil: the default limits of the sessions
 }
 
 func NewAgentService(router *route.Router) *AgentService {
-	return &AgentService{router: router}
+	return &AgentService{router: router, cipher: packet.CipherAESGCM}
+}
+
+// SetCipher set the cipher of the sessions negotiated in the handshake, packet.CipherAESGCM by default
+func (as *AgentService) SetCipher(cipherName string) { as.cipher = cipherName }
+
+// SetSignVerifier set the signature verifier of the signed routes,
+// the signatures are computed with the global secret as the clients' sessions are on the agents
+func (as *AgentService) SetSignVerifier(verifier ISignVerifier) { as.verifier = verifier }
+
+// SetReassemblyLimit set the limits of the large packets from the agents, the maxSize is the max size of a packet,
+// and the timeout is the max duration to receive all its fragments
+func (as *AgentService) SetReassemblyLimit(maxSize int, timeout time.Duration) {
+	as.reassembler = packet.NewReassembler(maxSize, timeout)
 }
 
 func (as *AgentService) handleAgentCmd(sess *tunnel.BackendSession, pack packet.Packet) {
@@ -26,92 +47,110 @@ func (as *AgentService) handleAgentCmd(sess *tunnel.BackendSession, pack packet.
 	switch cmd {
 	case packet.CmdPing:
 		sess.UpdatePing()
+	case packet.CmdRegister:
+		// the agent registers its id, the trace ids of its requests are derived from it
+		sess.SetID(pack.GetConnID())
+		info, err := sess.HandleRegister(pack)
+		if err != nil {
+			sess.GetLogger().Error("invalid register", zap.Error(err))
+			return
+		}
+		if info == nil {
+			sess.GetLogger().Info("agent registered")
+		} else {
+			sess.GetLogger().Info("agent registered", info.Fields()...)
+		}
+		// reply the build info, the agent refuses an incompatible backend or the one not replying
+		if err := sess.Register(pack.GetConnID()); err != nil {
+			sess.GetLogger().Error("reply register error", zap.Error(err))
+		}
+	case packet.CmdHandshake:
+		// a handshake out of order may re-key the session, it's closed then
+		if err := sess.HandleHandshake(pack, as.cipher); err != nil {
+			sess.GetLogger().Error("handshake error", zap.Error(err))
+			sess.Close()
+		}
 	default:
-		// TODO: log
-		//zaplog.S.Errorf("agent@%s: packet: %v, invalid cmd(%d)",
-		//	sess.ClientAddr(), cmd)
+		sess.GetLogger().Error("invalid cmd", zap.Uint16("cmd", cmd))
 	}
 }
 
 // handleAgentRequest handle the cmd request
 func (this *AgentService) handleAgentRequest(sess *tunnel.BackendSession, req *tunnel.BackendRequest) {
 	sess.AddRequest()
+	logger := sess.GetLogger()
 	defer func() {
 		if err := recover(); err != nil {
-			//TODO: add log
+			logger.Error("handle agent request panic", zap.Any("error", err), zap.Stack("stack"))
 		}
 		req.Free()
 		sess.DoneRequest()
 	}()
 
-	// no need to decrypt the data from an agent server
-	inPacket := req.GetPacket()
-	if inPacket.IsCmdSize() || inPacket.IsCmdProto() {
-		this.handleAgentCmd(sess, inPacket)
+	// the data from an agent server is encrypted by the session keys after the handshake
+	inPacket, err := req.GetPacket().Decrypt(sess.GetCrypto())
+	if err != nil {
+		logger.Error("decrypt agent request error", zap.Error(err))
 		return
 	}
 
 	connID := inPacket.GetConnID()
-	//show packet content
-	// TODO: add log
-	//zaplog.S.Debugf("agent@%s: cid: %d, packet: %v, size: %d",
-	//	sess.ClientAddr(), connID, inPacket, len(inPacket))
 	clientRequest := NewRequestFromAgent(inPacket)
-
-	result, isTimeout := this.router.Dispatch(clientRequest)
-	if isTimeout {
-		//TODO: add log
-		//zaplog.S.Errorf(
-		//	"agent@%s response timeout: cid: %d, mid: %d, aid: %d",
-		//	sess.ClientAddr(), connID, clientRequest.MID, clientRequest.AID)
+	if clientRequest == nil {
+		return
+	}
+	clientRequest.SetLogger(sess.GetRequestLogger(connID))
+	logger = clientRequest.GetLogger()
+	logger.Debug("request", zap.Int("size", len(inPacket)))
+
+	var result route.IOutProtocol
+	if err := verifyRequest(this.router, this.verifier, clientRequest); err != nil {
+		// reply the none response, or an empty one, the frontend is waiting for it
+		logger.Error("verify request error", zap.Error(err))
+		result = this.router.NoneResponse()
+	} else {
+		var isTimeout bool
+		if result, isTimeout = this.router.Dispatch(clientRequest); isTimeout {
+			logger.Error("response timeout")
+		}
 	}
 
-	// TODO: log
-	//zaplog.S.Debugf(
-	//	"agent@%s response: cid: %d, mid: %d, aid: %d, out: [%v]",
-	//	sess.ClientAddr(), connID, clientRequest.MID, clientRequest.AID, result,
-	//)
-
-	dataLoad, err := result.Marshal()
-	if err != nil {
-		//TODO: log
-		//zaplog.S.Errorf(
-		//	"agent@%s marshal error: cid: %d, mid: %d, aid: %d, err: %v",
-		//	sess.ClientAddr(), connID, clientRequest.MID, clientRequest.AID, err)
-		return
+	var dataLoad []byte
+	if result != nil {
+		if dataLoad, err = result.Marshal(); err != nil {
+			logger.Error("marshal response error", zap.Error(err))
+			return
+		}
 	}
 
-	outPacket := packet.NewFromData(dataLoad, nil, packet.NoneCompresser)
+	outPacket := packet.NewFromData(dataLoad, nil, packet.AutoCompresser())
 	outPacket.SetConnID(connID)
 	outPacket.SetProtoMID(inPacket.GetProtoMID())
 	outPacket.SetProtoAID(inPacket.GetProtoAID())
 	outPacket.SetProtoVer(inPacket.GetProtoVer())
 
-	// zaplog.S.Debugf(
-	//	"agent@%s response: cid: %d, mid: %d, aid: %d, out: %v",
-	//	as.sess.ClientAddr(), connID, clientRequest.MID, clientRequest.AID, outPacket,
-	// )
+	logger.Debug("response", zap.Int("size", len(outPacket)))
 
+	// the response is encrypted by the session keys after the handshake
 	_, err = sess.Write(outPacket)
 	if err != nil {
-		//TODO: log
-		//zaplog.S.Errorf(
-		//	"write agent@%s response: cid: %d, mid: %d, aid: %d, err: %v",
-		//	sess.ClientAddr(), connID, clientRequest.MID,
-		//	clientRequest.AID, zeroutil.ParseNetError(err))
+		logger.Error("write response error", zap.Error(err))
 	}
-
 }
 
 func (this *AgentService) Serve(nc net.Conn) {
 	backendSess := tunnel.NewBackendSession(0, nc)
 	defer func() {
 		if err := recover(); err != nil {
-			//TODO: log
+			backendSess.GetLogger().Error("serve agent panic", zap.Any("error", err), zap.Stack("stack"))
 		}
 		backendSess.Close()
 	}()
 
+	if this.reassembler != nil {
+		backendSess.SetReassembler(this.reassembler)
+	}
+
 	// it's a long session
 	backendSess.CheckPing()
 
@@ -119,110 +158,172 @@ func (this *AgentService) Serve(nc net.Conn) {
 		inReq, err := backendSess.ReadRequest()
 		if err != nil {
 			inReq.Free()
-			// TODO: add log
+			if e := errors.Cause(err); !netutil.IsNetTimeout(e) {
+				backendSess.GetLogger().Error("read agent request error", zap.Error(err))
+				break
+			}
+		} else if inPacket := inReq.GetPacket(); inPacket.IsCmdSize() || inPacket.IsCmdProto() {
+			// the cmds are handled in order, the requests following a handshake are encrypted
+			this.handleAgentCmd(backendSess, inPacket)
+			inReq.Free()
+		} else if !backendSess.IsHandshakeDone() {
+			// a plaintext request from an agent skipping the handshake
+			backendSess.GetLogger().Error("refuse agent : request before the handshake")
+			inReq.Free()
+			break
 		} else {
 			go this.handleAgentRequest(backendSess, inReq)
 		}
 	}
 }
 
+// CryptoFactory create the crypto of a frontend session, eg: a RolloutCrypto during migrating to the AEADCrypto
+type CryptoFactory func(sess *tunnel.FrontendSession) (packet.ICrypto, error)
+
 // LocalAgentSession a local agent session
 type LocalAgentService struct {
-	router *route.Router
+	router        *route.Router
+	cryptoFactory CryptoFactory
+	cipher        string // the cipher negotiated in the handshake
+	verifier      ISignVerifier
+	reassembler   *packet.Reassembler // nil: the default limits of the sessions
 }
 
 // NewLocalAgentService create a LocalAgentService struct
 func NewLocalAgentService(router *route.Router) *LocalAgentService {
-	return &LocalAgentService{router: router}
+	return &LocalAgentService{router: router, cipher: packet.CipherAESGCM}
+}
+
+// SetCipher set the cipher of the sessions negotiated in the handshake, packet.CipherAESGCM by default
+func (as *LocalAgentService) SetCipher(cipherName string) { as.cipher = cipherName }
+
+// SetSignVerifier set the signature verifier of the signed routes,
+// the signatures are computed with the session secret after the handshake
+func (as *LocalAgentService) SetSignVerifier(verifier ISignVerifier) { as.verifier = verifier }
+
+// SetReassemblyLimit set the limits of the large packets from the clients, the maxSize is the max size of a packet,
+// and the timeout is the max duration to receive all its fragments
+func (as *LocalAgentService) SetReassemblyLimit(maxSize int, timeout time.Duration) {
+	as.reassembler = packet.NewReassembler(maxSize, timeout)
+}
+
+// SetCryptoFactory set the crypto factory of the frontend sessions, the XORCrypto is used if not set
+func (as *LocalAgentService) SetCryptoFactory(factory CryptoFactory) {
+	as.cryptoFactory = factory
 }
 
 // Serve serve a tcp session from the frontend
 func (as *LocalAgentService) Serve(nc net.Conn) {
 	frontendSess := tunnel.NewFrontendSession(nc)
+	logger := frontendSess.GetLogger()
 	defer func() {
 		if err := recover(); err != nil {
-			//TODO: log
-			//zaplog.S.Error(err)
-			//			zaplog.S.Error(zap.Stack("").String)
+			logger.Error("serve client panic", zap.Any("error", err), zap.Stack("stack"))
 		}
 		frontendSess.Close()
 	}()
 
+	if as.cryptoFactory != nil {
+		crypto, err := as.cryptoFactory(frontendSess)
+		if err != nil {
+			logger.Error("create crypto error", zap.Error(err))
+			return
+		}
+		frontendSess.SetCrypto(crypto)
+	}
+	if as.reassembler != nil {
+		frontendSess.SetReassembler(as.reassembler)
+	}
+
 	inPacket, err := frontendSess.ReadPacket()
 	if err != nil {
-		// TODO: error handler
-		//if e := errors.Cause(err); !zeroutil.IsNetTimeout(e) {
-		//	zaplog.S.Errorf(
-		//		"read client@%s request: %v", frontendSess.ClientAddr(), err)
-		//	return
-		//}
+		if e := errors.Cause(err); !netutil.IsNetTimeout(e) {
+			logger.Error("read client request error", zap.Error(err))
+		}
+		return
 	}
 
 	if !inPacket.IsValid() {
-		//TODO: log
-		//zaplog.S.Errorf("read client@%s request: %v, invalid packet",
-		//	frontendSess.ClientAddr(), inPacket)
+		logger.Error("read client request: invalid packet", zap.Int("size", len(inPacket)))
 		return
 	}
 
-	// decrypt the packet and clear the FlagXOR,
+	// the client may negotiate the session keys before the request
+	if inPacket.IsCmdProto() && inPacket.GetCmd() == packet.CmdHandshake {
+		if err := frontendSess.Handshake(inPacket, as.cipher); err != nil {
+			logger.Error("handshake error", zap.Error(err))
+			return
+		}
+		if inPacket, err = frontendSess.ReadPacket(); err != nil {
+			logger.Error("read client request error", zap.Error(err))
+			return
+		}
+		if !inPacket.IsValid() {
+			logger.Error("read client request: invalid packet", zap.Int("size", len(inPacket)))
+			return
+		}
+	}
+
+	// decrypt the packet and clear the FlagXOR or FlagAEAD,
 	// and a game server doesn't decrypt it again
-	inPacket.Decrypt(packet.XORCrypto)
+	inPacket, err = inPacket.Decrypt(frontendSess.GetCrypto())
+	if err != nil {
+		logger.Error("decrypt client request error", zap.Error(err))
+		return
+	}
 
 	// cmd proto is not permited
 	if inPacket.IsCmdSize() || inPacket.IsCmdProto() {
-		//TODO: add log
-		//zaplog.S.Errorf(
-		//	"read client@%s request: %v, cmd(%d) is not permitted",
-		//	frontendSess.ClientAddr(), inPacket, inPacket.GetCmd())
+		logger.Error("read client request: cmd is not permitted", zap.Uint16("cmd", inPacket.GetCmd()))
 		return
 	}
 
 	sid := inPacket.GetConnID()
-	// show packet content
-	//zaplog.S.Debugf("client@%s: packet: %v, size: %d",
-	//	frontendSess.ClientAddr(), inPacket, len(inPacket))
-
 	clientRequest := NewRequestFromAgent(inPacket)
-	result, isTimeout := as.router.Dispatch(clientRequest)
-	if isTimeout {
-		// TODO: log
-		//	zaplog.S.Errorf(
-		//		"client@%s response timeout: mid: %d, aid: %d",
-		//		frontendSess.ClientAddr(), clientRequest.MID, clientRequest.AID)
+	if clientRequest == nil {
+		return
 	}
+	clientRequest.SetLogger(logger)
+	logger = clientRequest.GetLogger()
+	logger.Debug("request", zap.Int("size", len(inPacket)))
 
-	//zaplog.S.Debugf(
-	//	"agent@%s response: mid: %d, aid: %d, out: [%v]",
-	//	frontendSess.ClientAddr(), clientRequest.MID, clientRequest.AID, result,
-	//)
+	if keys := frontendSess.GetSessionKeys(); keys != nil {
+		clientRequest.SetSignSecret(keys.Sign)
+	}
+	var result route.IOutProtocol
+	if err := verifyRequest(as.router, as.verifier, clientRequest); err != nil {
+		// reply the none response, or an empty one, the client is waiting for it
+		logger.Error("verify request error", zap.Error(err))
+		result = as.router.NoneResponse()
+	} else {
+		var isTimeout bool
+		if result, isTimeout = as.router.Dispatch(clientRequest); isTimeout {
+			logger.Error("response timeout")
+		}
+	}
 
-	dataload, err := result.Marshal()
-	if err != nil {
-		//TODO : log
-		//zaplog.S.Errorf(
-		//	"agent@%s marshal error: mid: %d, aid: %d, err: %v",
-		//	frontendSess.ClientAddr(), clientRequest.MID, clientRequest.AID, err)
-		return
+	var dataload []byte
+	if result != nil {
+		if dataload, err = result.Marshal(); err != nil {
+			logger.Error("marshal response error", zap.Error(err))
+			return
+		}
 	}
-	outPacket := packet.NewFromData(dataload, nil, packet.NoneCompresser)
+	outPacket := packet.NewFromData(dataload, nil, packet.AutoCompresser())
 	outPacket.SetConnID(sid)
 	outPacket.SetProtoMID(inPacket.GetProtoMID())
 	outPacket.SetProtoAID(inPacket.GetProtoAID())
 	outPacket.SetProtoVer(inPacket.GetProtoVer())
-	outPacket.Encrypt(packet.XORCrypto)
+	outPacket, err = outPacket.Encrypt(frontendSess.GetCrypto())
+	if err != nil {
+		logger.Error("encrypt response error", zap.Error(err))
+		return
+	}
 
-	// zaplog.S.Debugf(
-	//	"client@%s response: mid: %d, aid: %d, out: %v",
-	//	frontendSess.ClientAddr(), clientRequest.MID, clientRequest.AID, outPacket,
-	// )
+	logger.Debug("response", zap.Int("size", len(outPacket)))
 
 	_, err = frontendSess.Write(outPacket)
 	if err != nil {
-		// TODO: log
-		//zaplog.S.Errorf(
-		//	"write agent@%s response: mid: %d, aid: %d, err: %v",
-		//	frontendSess.ClientAddr(), clientRequest.MID,
-		//	clientRequest.AID, zeroutil.ParseNetError(err))
+		logger.Error("write response error", zap.Error(err))
 	}
 }
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"crypto/hmac"
+
+	"github.com/pkg/errors"
+
+	"github.com/overtalk/bgo/3rdparty/auth"
+	"github.com/overtalk/bgo/pkg/service/packet"
+	"github.com/overtalk/bgo/pkg/service/route"
+)
+
+// error definitions
+var (
+	ErrSignMissing    = errors.New("signature missing")
+	ErrSignInvalid    = errors.New("signature invalid")
+	ErrSignNoVerifier = errors.New("no signature verifier")
+	ErrUnknownUser    = errors.New("unknown user")
+)
+
+// ISignVerifier a stage verifying the signature of a request of a signed route before dispatching
+type ISignVerifier interface {
+	Verify(r *Request) error
+}
+
+// UserResolver resolve the user id of a request, eg: from the login state of the session
+type UserResolver func(r *Request) string
+
+// TokenSignVerifier verify the signature by the user's token secret in a GameTokenCache,
+// the signature is ISignature.Sum(tokenSecret, SignedRouteData(MID, AID, dataload)) of the algorithm in the data flag.
+type TokenSignVerifier struct {
+	tokens  *auth.GameTokenCache
+	resolve UserResolver
+}
+
+// NewTokenSignVerifier create a TokenSignVerifier struct
+func NewTokenSignVerifier(tokens *auth.GameTokenCache, resolve UserResolver) *TokenSignVerifier {
+	return &TokenSignVerifier{tokens: tokens, resolve: resolve}
+}
+
+// Verify verify the signature of the request
+func (v *TokenSignVerifier) Verify(r *Request) error {
+	if len(r.GetSign()) == 0 {
+		return ErrSignMissing
+	}
+	userID := v.resolve(r)
+	if len(userID) == 0 {
+		return ErrUnknownUser
+	}
+	_, _, tokenSecret := v.tokens.GetToken(userID)
+	if len(tokenSecret) == 0 {
+		return auth.ErrTokenNotExist
+	}
+
+	signature, err := packet.NewSignature(r.GetDataFlag(), r.GetSignSecret())
+	if err != nil {
+		return err
+	}
+	sum, err := signature.Sum([]byte(tokenSecret), packet.SignedRouteData(r.GetMID(), r.GetAID(), r.GetData()))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sum, r.GetSign()) {
+		return ErrSignInvalid
+	}
+	return nil
+}
+
+// verifyRequest verify the request if its route is signed,
+// the requests of the signed routes are rejected without a verifier
+func verifyRequest(router *route.Router, verifier ISignVerifier, r *Request) error {
+	if !router.IsSigned(r.GetMID(), r.GetAID()) {
+		return nil
+	}
+	if verifier == nil {
+		return ErrSignNoVerifier
+	}
+	return verifier.Verify(r)
+}
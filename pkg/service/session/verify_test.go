@@ -0,0 +1,196 @@
+package session
+
+import (
+	"net"
+	"testing"
+
+	"github.com/overtalk/bgo/3rdparty/auth"
+	"github.com/overtalk/bgo/pkg/service/packet"
+	"github.com/overtalk/bgo/pkg/service/route"
+	"github.com/overtalk/bgo/pkg/service/tunnel"
+)
+
+func newSignedRequest(t *testing.T, flag uint8, secret []byte, tokenSecret string) *Request {
+	data := []byte("hello")
+	signature, err := packet.NewSignature(flag, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign, err := signature.Sum([]byte(tokenSecret), packet.SignedRouteData(1, 2, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack := packet.NewFromSignedData(data, sign, flag, packet.NoneCompresser)
+	pack.SetProtoMID(1)
+	pack.SetProtoAID(2)
+	return NewRequestFromAgent(pack)
+}
+
+func TestVerifyRequest(t *testing.T) {
+	packet.SetSignSecret([]byte("global"))
+	tokens := auth.NewGameTokenCache()
+	_, tokenSecret := tokens.SetToken("user", "dev")
+	verifier := NewTokenSignVerifier(tokens, func(*Request) string { return "user" })
+	router := route.NewRouter(route.OptionSignedRoutes(route.NewSignedRoutes().Add(1, 2, 4)))
+
+	for _, flag := range []uint8{packet.FlagHMACSha1, packet.FlagHMACSha256} {
+		r := newSignedRequest(t, flag, nil, tokenSecret)
+		if err := verifyRequest(router, verifier, r); err != nil {
+			t.Errorf("flag %x : %v", flag, err)
+		}
+
+		// signed by the session secret
+		r = newSignedRequest(t, flag, []byte("session"), tokenSecret)
+		if err := verifyRequest(router, verifier, r); err != ErrSignInvalid {
+			t.Errorf("flag %x : expect invalid signature, got %v", flag, err)
+		}
+		r.SetSignSecret([]byte("session"))
+		if err := verifyRequest(router, verifier, r); err != nil {
+			t.Errorf("flag %x : %v", flag, err)
+		}
+
+		// a wrong token secret
+		r = newSignedRequest(t, flag, nil, "wrong")
+		if err := verifyRequest(router, verifier, r); err != ErrSignInvalid {
+			t.Errorf("flag %x : expect invalid signature, got %v", flag, err)
+		}
+
+		// the signed request sent to another signed route
+		r = newSignedRequest(t, flag, nil, tokenSecret)
+		r.AID = 4
+		if err := verifyRequest(router, verifier, r); err != ErrSignInvalid {
+			t.Errorf("flag %x : expect invalid signature for another route, got %v", flag, err)
+		}
+	}
+
+	// missing signature
+	pack := packet.NewFromData([]byte("hello"), nil, packet.NoneCompresser)
+	pack.SetProtoMID(1)
+	pack.SetProtoAID(2)
+	if err := verifyRequest(router, verifier, NewRequestFromAgent(pack)); err != ErrSignMissing {
+		t.Errorf("expect missing signature, got %v", err)
+	}
+	if err := verifyRequest(router, nil, NewRequestFromAgent(pack)); err != ErrSignNoVerifier {
+		t.Errorf("expect no verifier, got %v", err)
+	}
+
+	// not a signed route
+	pack.SetProtoAID(3)
+	if err := verifyRequest(router, verifier, NewRequestFromAgent(pack)); err != nil {
+		t.Errorf("expect no verification, got %v", err)
+	}
+
+	// an unknown user
+	verifier = NewTokenSignVerifier(tokens, func(*Request) string { return "nobody" })
+	if err := verifyRequest(router, verifier, newSignedRequest(t, packet.FlagHMACSha1, nil, tokenSecret)); err != auth.ErrTokenNotExist {
+		t.Errorf("expect token not exist, got %v", err)
+	}
+}
+
+func TestVerifyFailureReply(t *testing.T) {
+	tunnel.InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	router := route.NewRouter(route.OptionSignedRoutes(route.NewSignedRoutes().Add(1, 2)))
+	defer serveAgent(NewAgentService(router), agentConn, backendConn)()
+
+	agentSess := tunnel.NewBackendSession(7, agentConn)
+	handshakeAgent(t, agentSess)
+
+	// the request of a signed route without a signature
+	pack := packet.NewFromData([]byte("hello"), nil, packet.NoneCompresser)
+	pack.SetConnID(101)
+	pack.SetProtoMID(1)
+	pack.SetProtoAID(2)
+	go agentSess.Write(pack)
+
+	req, err := agentSess.ReadRequest()
+	if err != nil {
+		t.Fatalf("expect a reply, got %v", err)
+	}
+	defer req.Free()
+	rsp, err := req.GetPacket().Decrypt(agentSess.GetCrypto())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.GetConnID() != 101 || rsp.GetProtoMID() != 1 || rsp.GetProtoAID() != 2 {
+		t.Errorf("reply = %v", rsp)
+	}
+}
+
+// serveAgent serve the agent connection, call the returned func to close it and wait the service to exit
+func serveAgent(as *AgentService, agentConn, backendConn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		as.Serve(backendConn)
+	}()
+	return func() {
+		agentConn.Close()
+		<-done
+	}
+}
+
+// handshakeAgent key the agent session with the AgentService serving the other end
+func handshakeAgent(t *testing.T, agentSess *tunnel.BackendSession) {
+	go agentSess.StartHandshake()
+	req, err := agentSess.ReadRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer req.Free()
+	if err := agentSess.HandleHandshake(req.GetPacket(), ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestBeforeHandshake(t *testing.T) {
+	tunnel.InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer serveAgent(NewAgentService(route.NewRouter()), agentConn, backendConn)()
+
+	// a plaintext request skipping the handshake closes the session
+	agentSess := tunnel.NewBackendSession(7, agentConn)
+	pack := packet.NewFromData([]byte("hello"), nil, packet.NoneCompresser)
+	pack.SetConnID(101)
+	pack.SetProtoMID(1)
+	pack.SetProtoAID(3)
+	go agentSess.Write(pack)
+
+	req, err := agentSess.ReadRequest()
+	defer req.Free()
+	if err == nil {
+		t.Errorf("expect the session closed, got %v", req.GetPacket())
+	}
+}
+
+func TestLocalVerifyFailureReply(t *testing.T) {
+	tunnel.InitFrontendPool()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	router := route.NewRouter(
+		route.OptionSignedRoutes(route.NewSignedRoutes().Add(1, 2)),
+		route.OptionNoneResponse(route.BytesOutProtocol("none")),
+	)
+	go NewLocalAgentService(router).Serve(serverConn)
+
+	// the request of a signed route without a signature
+	clientSess := tunnel.NewFrontendSession(clientConn)
+	pack := packet.NewFromData([]byte("hello"), nil, packet.NoneCompresser)
+	pack.SetProtoMID(1)
+	pack.SetProtoAID(2)
+	pack, _ = pack.Encrypt(packet.XORCrypto)
+	go clientSess.Write(pack)
+
+	rsp, err := clientSess.ReadPacket()
+	if err != nil {
+		t.Fatalf("expect a reply, got %v", err)
+	}
+	// the reply is encrypted by the crypto of the session
+	if !rsp.HasDataFlag(packet.FlagXOR) {
+		t.Errorf("the reply should be encrypted : %v", rsp)
+	}
+	rsp, _ = rsp.Decrypt(packet.XORCrypto)
+	if rsp.GetProtoMID() != 1 || rsp.GetProtoAID() != 2 || string(rsp.GetDataLoad()) != "none" {
+		t.Errorf("reply = %v", rsp)
+	}
+}
@@ -6,6 +6,14 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/pkg/service/packet"
+	"github.com/overtalk/bgo/utils/net"
 )
 
 type hostItem struct {
@@ -18,6 +26,9 @@ const (
 	serviceOFF = 1
 )
 
+// replyTimeout the max duration to wait for the handshake reply or the register reply of a backend
+const replyTimeout = 5 * time.Second
+
 // BackendSessionMgr a manager for BackendSession
 type BackendSessionMgr struct {
 	//TODO: use sync.Map to reduce the lock contention
@@ -31,6 +42,9 @@ type BackendSessionMgr struct {
 	buffedHosts chan hostItem
 	hosts       atomic.Value // map[uint32]string
 	connStates  map[string]*backendConnState
+
+	// check the build info registered by the backend, the backend is refused on errors
+	buildInfoChecker func(*core.BuildInfo) error
 }
 
 func NewBackendSessionMgr() *BackendSessionMgr {
@@ -45,6 +59,12 @@ func GetBackendSessionMgr() *BackendSessionMgr {
 	return defaultBackendSessionMgr
 }
 
+// SetBuildInfoChecker set the checker of the backends' build info, eg: core.CheckMajorVersion,
+// it should be set before connecting the backends
+func (mgr *BackendSessionMgr) SetBuildInfoChecker(checker func(*core.BuildInfo) error) {
+	mgr.buildInfoChecker = checker
+}
+
 // GetServiceState get the manager's service state
 func (mgr *BackendSessionMgr) GetServiceState() int32 {
 	return atomic.LoadInt32(&mgr.serviceState)
@@ -130,8 +150,7 @@ func (mgr *BackendSessionMgr) connectSIDAgain(id uint32) bool {
 		}
 		return true
 	}
-	//TODO: add log
-	//zaplog.S.Errorf("cannot find the host for backend-%d", id)
+	logpkg.Error("cannot find the host for backend", zap.Uint32(logpkg.KeyBackendID, id))
 	return false
 }
 
@@ -148,8 +167,70 @@ func (mgr *BackendSessionMgr) dialBackend(item hostItem) {
 		sess, err := mgr.NewSession(item.id, item.host)
 		if err == nil {
 			connState.reset()
+			// the backend is added after negotiating the session keys and replying the register,
+			// so the requests are encrypted, and an incompatible one is refused
+			if err := mgr.handshake(sess); err != nil {
+				sess.GetLogger().Error("handshake to backend error", zap.Error(err))
+				sess.Close()
+				return
+			}
+			if err := mgr.register(sess); err != nil {
+				sess.GetLogger().Error("refuse backend", zap.Error(err))
+				sess.Close()
+				return
+			}
+			mgr.AddSession(sess)
 			// start to handle session request and do ping
-			go handleBackendResponse(sess)
+			go handleBackendResponse(mgr, sess)
+		}
+	}
+}
+
+// handshake negotiate the session keys with the backend and wait for its reply
+func (mgr *BackendSessionMgr) handshake(sess *BackendSession) error {
+	if err := sess.StartHandshake(); err != nil {
+		return err
+	}
+	return mgr.waitCmd(sess, packet.CmdHandshake, replyTimeout)
+}
+
+// register register the id to the backend, which derives the trace ids from it,
+// and wait for the reply carrying its build info. a backend not replying in time is refused.
+func (mgr *BackendSessionMgr) register(sess *BackendSession) error {
+	if err := sess.Register(sess.GetID()); err != nil {
+		return errors.WithMessage(err, "register to backend")
+	}
+	return mgr.waitCmd(sess, packet.CmdRegister, replyTimeout)
+}
+
+// waitCmd wait for a cmd from the backend before handling its responses, the other cmds are handled as well
+func (mgr *BackendSessionMgr) waitCmd(sess *BackendSession, cmd uint16, timeout time.Duration) error {
+	defer sess.conn.SetReadTimeout(sessionTimeout)
+	deadline := time.Now().Add(timeout)
+	for {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return errors.Errorf("wait cmd(%d) timeout", cmd)
+		}
+		sess.conn.SetReadTimeout(wait)
+		req, err := sess.ReadRequest()
+		if err != nil {
+			req.Free()
+			if netutil.IsNetTimeout(errors.Cause(err)) {
+				continue
+			}
+			return err
+		}
+		pack := req.GetPacket()
+		if !pack.IsCmdSize() && !pack.IsCmdProto() {
+			req.Free()
+			return errors.Errorf("unexpected response before cmd(%d)", cmd)
+		}
+		got := pack.GetCmd()
+		err = mgr.handleBackendCmd(sess, pack)
+		req.Free()
+		if err != nil || got == cmd {
+			return err
 		}
 	}
 }
@@ -159,9 +240,7 @@ func (mgr *BackendSessionMgr) connectHosts() {
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
-				//TODO: add log
-				//zaplog.S.Error(err)
-				//zaplog.S.Error(zap.Stack("").String)
+				logpkg.Error("connect hosts panic", zap.Any("error", err), zap.Stack("stack"))
 			}
 		}()
 
@@ -199,16 +278,13 @@ func (mgr *BackendSessionMgr) TryGetSession(id uint32) *BackendSession {
 	return nil
 }
 
-// NewSession create a session by the host
+// NewSession create a session by the host, it's added by AddSession after being registered
 func (mgr *BackendSessionMgr) NewSession(id uint32, host string) (*BackendSession, error) {
 	nc, err := net.DialTimeout("tcp", host, 5*time.Second)
 	if err == nil {
-		sess := NewBackendSession(id, nc)
-		mgr.AddSession(sess)
-		return sess, nil
+		return NewBackendSession(id, nc), nil
 	}
-	// TODO: add log
-	//zaplog.S.Errorf("dial a backend-%d@%s, %v", id, host, err)
+	logpkg.Error("dial backend error", zap.Uint32(logpkg.KeyBackendID, id), zap.String(logpkg.KeyRemoteAddr, host), zap.Error(err))
 	return nil, err
 }
 
@@ -227,8 +303,7 @@ func (mgr *BackendSessionMgr) AddSession(sess *BackendSession) {
 		mgr.services[id] = sess
 		mgr.serviceLock.Unlock()
 	} else {
-		// TODO: add log
-		//zaplog.S.Error("cannot add a backend session, id <= 0")
+		logpkg.Error("cannot add a backend session, id <= 0")
 	}
 }
 
@@ -244,7 +319,6 @@ func (mgr *BackendSessionMgr) DelSession(id uint32) {
 			mgr.delConnState(sess.conn.RemoteAddr())
 		}
 	} else {
-		// TODO: add log
-		//zaplog.S.Error("cannot del a backend session, id <= 0")
+		logpkg.Error("cannot del a backend session, id <= 0")
 	}
 }
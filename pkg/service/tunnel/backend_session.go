@@ -1,14 +1,22 @@
 package tunnel
 
 import (
-	"github.com/overtalk/bgo/3rdparty/slab"
-	"github.com/overtalk/bgo/pkg/service/packet"
-	"github.com/overtalk/bgo/pkg/service/pool"
-	"github.com/overtalk/bgo/pkg/service/zd"
+	"encoding/json"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/3rdparty/slab"
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/pkg/metrics"
+	"github.com/overtalk/bgo/pkg/service/packet"
+	"github.com/overtalk/bgo/pkg/service/pool"
+	"github.com/overtalk/bgo/pkg/service/zd"
 )
 
 var backendPool *SessionPool
@@ -18,19 +26,34 @@ func InitBackendPool() {
 		slab.NewAtomPool(512, 32*1024, 2, 8*1024*1024), // pre-allocated: 56MBytes
 		pool.NewBufReaderPool(1000, 64*1024),
 	)
+	metricspkg.RegisterSlabPool("backend", backendPool.GetRdrBufPool())
 }
 
+// ErrUnexpectedHandshake a handshake which isn't the first packet of a session or is repeated
+var ErrUnexpectedHandshake = errors.New("unexpected handshake")
+
+// the states of the handshake, it's accepted only as the first packet and only once
+const (
+	handshakeOpen int32 = iota
+	handshakeDone
+	handshakeClosed
+)
+
+// defaultReassembler the default reassembler of the sessions
+var defaultReassembler = packet.NewReassembler(packet.MaxMessageSize, packet.DefaultReassemblyTimeout)
+
 // BackendRequest a request for backend
 type BackendRequest struct {
 	buffer zd.IPacketBuffer
 }
 
-// NewBackendRequest create a BackendRequest
-func NewBackendRequest() *BackendRequest {
+// NewBackendRequest create a BackendRequest, the fragments of a large packet are reassembled by the reassembler
+func NewBackendRequest(reassembler zd.IReassembler) *BackendRequest {
 	return &BackendRequest{
-		buffer: zd.NewPacketBuffer(
+		buffer: zd.NewReassemblyPacketBuffer(
 			packet.MaxPacketSize,
 			backendPool.GetRdrBufPool(),
+			reassembler,
 		),
 	}
 }
@@ -104,16 +127,36 @@ type BackendSession struct {
 
 	// wait all requests being done
 	waitRequest *sync.WaitGroup
+
+	// the session logger carrying the backend id and the remote addr, *zap.Logger
+	logger atomic.Value
+	// the build info of the other endpoint sent in the register, *core.BuildInfo
+	peerBuildInfo atomic.Value
+	// the salt of the trace ids generated by the agent and sent in the register, string
+	traceSalt atomic.Value
+
+	// the pending key agreement on the agent side
+	kxLock sync.Mutex
+	kx     *packet.KeyExchange
+	// the state of the handshake, handshakeOpen|handshakeDone|handshakeClosed
+	handshake int32
+	// the security negotiated by the handshake, *security
+	security atomic.Value
+	// the reassembler of the large packets, *packet.Reassembler
+	reassembler atomic.Value
 }
 
 const minPingTime = 20
 
+// sessionTimeout the read and write timeout of the sessions
+const sessionTimeout = 10 * time.Second
+
 // NewBackendSession create a BackendSession struct
 func NewBackendSession(id uint32, nc net.Conn) *BackendSession {
 	baseConn := zd.NewBaseConn(nc, backendPool.GetBufReader(nc))
-	baseConn.SetTimeout(10 * time.Second)
+	baseConn.SetTimeout(sessionTimeout)
 	nowTime := time.Now()
-	return &BackendSession{
+	sess := &BackendSession{
 		id:          id,
 		conn:        baseConn,
 		closed:      0,
@@ -124,29 +167,220 @@ func NewBackendSession(id uint32, nc net.Conn) *BackendSession {
 		timeStart:   nowTime,
 		waitRequest: new(sync.WaitGroup),
 	}
+	sess.traceSalt.Store(logpkg.NewTraceID())
+	sess.SetID(id)
+	sess.reassembler.Store(defaultReassembler)
+	return sess
 }
 
 // GetID get the session id
-func (this *BackendSession) GetID() uint32 { return this.id }
+func (this *BackendSession) GetID() uint32 { return atomic.LoadUint32(&this.id) }
+
+// SetID set the session id, eg: the id registered by the agent on the backend
+func (this *BackendSession) SetID(id uint32) {
+	atomic.StoreUint32(&this.id, id)
+	this.logger.Store(logpkg.Named("tunnel").With(
+		zap.Uint32(logpkg.KeyBackendID, id),
+		zap.String(logpkg.KeyRemoteAddr, this.ClientAddr()),
+	))
+}
+
+// GetLogger get the session logger, carrying the backend id and the remote addr
+func (this *BackendSession) GetLogger() *zap.Logger { return this.logger.Load().(*zap.Logger) }
+
+// GetTraceID get the trace id of a request from a frontend session, the agent and the backend get the same one
+func (this *BackendSession) GetTraceID(connID uint32) string {
+	return logpkg.TraceID(this.traceSalt.Load().(string), connID)
+}
+
+// GetRequestLogger get the logger of a request from a frontend session,
+// it carries the conn id and the trace id as the frontend session does
+func (this *BackendSession) GetRequestLogger(connID uint32) *zap.Logger {
+	return this.GetLogger().With(
+		zap.Uint32(logpkg.KeyConnID, connID),
+		zap.String(logpkg.KeyTraceID, this.GetTraceID(connID)),
+	)
+}
 
 // ClientAddr get the remote client address
 func (this *BackendSession) ClientAddr() string { return this.conn.RemoteAddr() }
 
-// ReadRequest read a request
+// SetReassembler set the reassembler limiting the large packets from another endpoint
+func (this *BackendSession) SetReassembler(reassembler *packet.Reassembler) {
+	this.reassembler.Store(reassembler)
+}
+
+// ReadRequest read a request, the fragments of a large packet are reassembled.
+// the handshake is refused after any other packet is read.
 func (this *BackendSession) ReadRequest() (*BackendRequest, error) {
-	req := NewBackendRequest()
+	req := NewBackendRequest(this.reassembler.Load().(*packet.Reassembler))
 	err := req.Read(this.conn)
+	if err == nil {
+		if pack := req.GetPacket(); !pack.IsCmdProto() || pack.GetCmd() != packet.CmdHandshake {
+			atomic.CompareAndSwapInt32(&this.handshake, handshakeOpen, handshakeClosed)
+		}
+	}
 	return req, err
 }
 
-func (this *BackendSession) Write(b []byte) (int, error) { return this.conn.Write(b) }
+// Write write a packet, a large packet is split into fragments.
+// the packets except the cmds are encrypted by the session keys after the handshake.
+func (this *BackendSession) Write(b []byte) (int, error) {
+	pack := packet.Packet(b)
+	if sec := this.getSecurity(); sec != nil && !pack.IsCmdSize() && !pack.IsCmdProto() {
+		var err error
+		if pack, err = sec.crypto.Encrypt(pack); err != nil {
+			return 0, err
+		}
+	}
+	return this.conn.Write(pack.Fragment())
+}
+
+// registerData the data of a register, the build info with the salt of the trace ids
+type registerData struct {
+	*core.BuildInfo
+	TraceSalt string `json:"trace_salt,omitempty"`
+}
 
-// Register register it to an agent
+// Register register the id to another endpoint with the local build info and the salt of the trace ids,
+// the agent registers to the backend and the backend replies in the same way
 func (this *BackendSession) Register(sid uint32) error {
-	_, err := this.Write(packet.NewRegister(sid))
+	data, err := json.Marshal(&registerData{
+		BuildInfo: core.GetBuildInfo().Brief(),
+		TraceSalt: this.traceSalt.Load().(string),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = this.Write(packet.NewRegisterWithData(sid, data))
 	return err
 }
 
+// HandleRegister parse the build info of the other endpoint in a register packet,
+// the info is nil if the endpoint doesn't send it.
+// the backend adopts the salt of the agent, so they derive the same trace ids.
+func (this *BackendSession) HandleRegister(pack packet.Packet) (*core.BuildInfo, error) {
+	data := pack.GetCmdData()
+	if len(data) == 0 {
+		return nil, nil
+	}
+	reg := &registerData{BuildInfo: &core.BuildInfo{}}
+	if err := json.Unmarshal(data, reg); err != nil {
+		return nil, err
+	}
+	if len(reg.TraceSalt) > 0 {
+		this.traceSalt.Store(reg.TraceSalt)
+	}
+	info := reg.BuildInfo
+	this.peerBuildInfo.Store(info)
+	return info, nil
+}
+
+// GetPeerBuildInfo get the build info of the other endpoint, nil if not registered
+func (this *BackendSession) GetPeerBuildInfo() *core.BuildInfo {
+	info, _ := this.peerBuildInfo.Load().(*core.BuildInfo)
+	return info
+}
+
+// StartHandshake start the handshake on the agent side with a new key agreement,
+// the backend replies with its public key which is handled by HandleHandshake.
+// it should be the first packet, and the requests should be sent after the handshake is done.
+func (this *BackendSession) StartHandshake() error {
+	kx, err := packet.NewKeyExchange()
+	if err != nil {
+		return err
+	}
+	this.kxLock.Lock()
+	this.kx = kx
+	this.kxLock.Unlock()
+	mac := packet.HandshakeMAC(kx.PublicKey(), nil, "")
+	_, err = this.Write(packet.NewHandshake(this.GetID(), kx.PublicKey(), mac, ""))
+	return err
+}
+
+// HandleHandshake handle a handshake, the agent completes its key agreement by the reply,
+// and the backend accepts the agent's handshake with the cipher and replies.
+// the crypto and the signature of the session are replaced with the ones of the session keys.
+// ErrUnexpectedHandshake is returned if it isn't the first packet read or it's repeated,
+// and packet.ErrInvalidHandshakeMAC if the public keys aren't authenticated with the sign secret.
+func (this *BackendSession) HandleHandshake(pack packet.Packet, cipherName string) error {
+	if !atomic.CompareAndSwapInt32(&this.handshake, handshakeOpen, handshakeDone) {
+		return ErrUnexpectedHandshake
+	}
+	this.kxLock.Lock()
+	kx := this.kx
+	this.kx = nil
+	this.kxLock.Unlock()
+
+	// the backend side
+	if kx == nil {
+		sec, reply, err := acceptHandshake(pack, cipherName)
+		if err != nil {
+			return err
+		}
+		if _, err := this.Write(reply); err != nil {
+			return err
+		}
+		this.security.Store(sec)
+		this.GetLogger().Debug("handshake done", zap.String("cipher", cipherName))
+		return nil
+	}
+
+	// the agent side, the cipher is chosen by the backend
+	public, mac, cipherName, err := packet.ParseHandshake(pack)
+	if err != nil {
+		return err
+	}
+	if err := packet.VerifyHandshakeMAC(mac, kx.PublicKey(), public, cipherName); err != nil {
+		return err
+	}
+	keys, err := kx.SessionKeys(public, false)
+	if err != nil {
+		return err
+	}
+	sec, err := newSecurity(keys, cipherName, false)
+	if err != nil {
+		return err
+	}
+	this.security.Store(sec)
+	this.GetLogger().Debug("handshake done", zap.String("cipher", cipherName))
+	return nil
+}
+
+func (this *BackendSession) getSecurity() *security {
+	sec, _ := this.security.Load().(*security)
+	return sec
+}
+
+// IsHandshakeDone check whether the handshake has succeeded, the packets except the cmds
+// should be refused before it, as they are not encrypted
+func (this *BackendSession) IsHandshakeDone() bool { return this.getSecurity() != nil }
+
+// GetCrypto get the crypto of the packets between the agent and the backend, the packets read
+// should be decrypted by it, they are not encrypted before the handshake
+func (this *BackendSession) GetCrypto() packet.ICrypto {
+	if sec := this.getSecurity(); sec != nil {
+		return sec.crypto
+	}
+	return packet.NoneCrypto
+}
+
+// GetSignature get the signature of the session, the one with the global secret before the handshake
+func (this *BackendSession) GetSignature() packet.ISignature {
+	if sec := this.getSecurity(); sec != nil {
+		return sec.signature
+	}
+	return packet.HMACSha1Signature
+}
+
+// GetSessionKeys get the session keys, nil before the handshake
+func (this *BackendSession) GetSessionKeys() *packet.SessionKeys {
+	if sec := this.getSecurity(); sec != nil {
+		return sec.keys
+	}
+	return nil
+}
+
 // AddRequest add a request to be done
 func (this *BackendSession) AddRequest() { this.waitRequest.Add(1) }
 
@@ -164,9 +398,7 @@ func (this *BackendSession) Ping() {
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
-				// TODO: add log
-				//zaplog.S.Error(err)
-				//zaplog.S.Error(zap.Stack("").String)
+				this.GetLogger().Error("ping panic", zap.Any("error", err), zap.Stack("stack"))
 			}
 		}()
 
@@ -174,14 +406,10 @@ func (this *BackendSession) Ping() {
 		for {
 			select {
 			case <-ticker.C:
-				//TODO: add log
-				//zaplog.S.Infof("ping: agent@%s ---> backend-%d@%s",
-				//	s.conn.LocalAddr(), s.id, s.ClientAddr())
+				this.GetLogger().Debug("ping", zap.String("local_addr", this.conn.LocalAddr()))
 				_, err := this.conn.Write(packet.PingPacket)
 				if err != nil {
-					// TODO: add log
-					//zaplog.S.Errorf("ping: agent@%s ---> backend-%d@%s, %v",
-					//	s.conn.LocalAddr(), s.id, s.ClientAddr(), err)
+					this.GetLogger().Error("ping error", zap.String("local_addr", this.conn.LocalAddr()), zap.Error(err))
 					this.conn.Close()
 					ticker.Stop()
 					return
@@ -199,9 +427,7 @@ func (this *BackendSession) CheckPing() {
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
-				// TODO: add log
-				//zaplog.S.Error(err)
-				//zaplog.S.Error(zap.Stack("").String)
+				this.GetLogger().Error("check ping panic", zap.Any("error", err), zap.Stack("stack"))
 			}
 		}()
 
@@ -211,10 +437,8 @@ func (this *BackendSession) CheckPing() {
 			case now := <-ticker.C:
 				lastPingTime := atomic.LoadInt64(&this.pingTime)
 				if now.Unix()-lastPingTime > minPingTime {
-					// TODO: add log
-					// ping timeout
-					//zaplog.S.Errorf("ping timeout: agent@%s -> backend-%d@%s",
-					//	s.ClientAddr(), s.id, s.conn.LocalAddr())
+					this.GetLogger().Error("ping timeout", zap.String("local_addr", this.conn.LocalAddr()),
+						zap.Int64("last_ping", lastPingTime))
 					this.conn.Close()
 					ticker.Stop()
 					return
@@ -0,0 +1,82 @@
+package tunnel
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+func TestLargePacket(t *testing.T) {
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	backendSess := NewBackendSession(0, backendConn)
+
+	data := bytes.Repeat([]byte("large"), 40000)
+	pack := packet.NewFromData(data, nil, packet.NoneCompresser)
+	pack.SetConnID(101)
+	pack.SetProtoMID(1)
+	pack.SetProtoAID(2)
+	go func() {
+		backendSess.Write(pack)
+		backendSess.Write(packet.NewFromData([]byte("small"), nil, packet.NoneCompresser))
+	}()
+
+	req, err := agentSess.ReadRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := req.GetPacket(); !bytes.Equal(out.GetDataLoad(), data) || out.GetConnID() != 101 || out.GetProtoAID() != 2 {
+		t.Errorf("reassembled packet differs, size = %d", len(out))
+	}
+	req.Free()
+
+	// the following packet
+	req, err = agentSess.ReadRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := req.GetPacket(); string(out.GetDataLoad()) != "small" {
+		t.Errorf("packet = %v", out)
+	}
+	req.Free()
+
+	// a packet exceeding the session limit
+	agentSess.SetReassembler(packet.NewReassembler(64*1024, time.Second))
+	go backendSess.Write(pack)
+	req, err = agentSess.ReadRequest()
+	req.Free()
+	if err == nil {
+		t.Error("expect too large error")
+	}
+}
+
+func TestLargePacketStalled(t *testing.T) {
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	agentSess.SetReassembler(packet.NewReassembler(packet.MaxMessageSize, 50*time.Millisecond))
+
+	// only the first fragment is written
+	pack := packet.NewFromData(bytes.Repeat([]byte("large"), 40000), nil, packet.NoneCompresser)
+	pack.SetProtoMID(1)
+	frames := pack.Fragment()
+	go backendConn.Write(frames[:2+int(frames[0])<<8+int(frames[1])])
+
+	start := time.Now()
+	req, err := agentSess.ReadRequest()
+	req.Free()
+	if err != packet.ErrFragmentTimeout {
+		t.Errorf("expect fragment timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the reassembly stops after %v", elapsed)
+	}
+}
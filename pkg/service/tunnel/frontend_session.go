@@ -5,7 +5,11 @@ import (
 	"sync/atomic"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/overtalk/bgo/3rdparty/slab"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/pkg/metrics"
 	"github.com/overtalk/bgo/pkg/service/packet"
 	"github.com/overtalk/bgo/pkg/service/pool"
 	"github.com/overtalk/bgo/pkg/service/zd"
@@ -19,6 +23,7 @@ func InitFrontendPool() {
 		slab.NewAtomPool(512, 4*1024, 2, 4*1024*1024), // pre-allocated: 16MBytes
 		pool.NewBufReaderPool(10000, 1024),
 	)
+	metricspkg.RegisterSlabPool("frontend", frontendPool.GetRdrBufPool())
 }
 
 type FrontendSession struct {
@@ -30,23 +35,86 @@ type FrontendSession struct {
 
 	// connected backend
 	backend *BackendSession
+	// the crypto of the packets between the client and the server, XORCrypto by default
+	crypto packet.ICrypto
+	// the security negotiated by the handshake, nil before the handshake
+	security *security
+
+	// baseLogger carries the remote addr, logger carries the conn id and the trace id as well
+	baseLogger *zap.Logger
+	logger     *zap.Logger
 }
 
 func NewFrontendSession(nc net.Conn) *FrontendSession {
 	baseConn := zd.NewBaseConn(nc, frontendPool.GetBufReader(nc))
 	baseConn.SetReadTimeout(10 * time.Second)
+	baseLogger := logpkg.Named("tunnel").With(zap.String(logpkg.KeyRemoteAddr, baseConn.RemoteAddr()))
 	return &FrontendSession{
-		id:     0,
-		conn:   baseConn,
-		buffer: zd.NewPacketBuffer(packet.MaxPacketSize, frontendPool.GetRdrBufPool()),
-		done:   make(chan struct{}),
+		id:         0,
+		conn:       baseConn,
+		buffer:     zd.NewReassemblyPacketBuffer(packet.MaxPacketSize, frontendPool.GetRdrBufPool(), defaultReassembler),
+		done:       make(chan struct{}),
+		crypto:     packet.XORCrypto,
+		baseLogger: baseLogger,
+		// the trace id is derived from the conn id after binding to a backend session
+		logger: baseLogger.With(zap.String(logpkg.KeyTraceID, logpkg.NewTraceID())),
 	}
 }
 
 func (this *FrontendSession) ClientAddr() string { return this.conn.RemoteAddr() }
 func (this *FrontendSession) GetID() uint32      { return this.id }
 
+// GetLogger get the session logger, carrying the remote addr, the conn id and the trace id
+func (this *FrontendSession) GetLogger() *zap.Logger { return this.logger }
+
+// GetCrypto get the crypto of the session
+func (this *FrontendSession) GetCrypto() packet.ICrypto { return this.crypto }
+
+// SetCrypto set the crypto of the session, eg: an AEADCrypto with the session key
+func (this *FrontendSession) SetCrypto(crypto packet.ICrypto) { this.crypto = crypto }
+
+// GetSignature get the signature of the session, the one with the global secret before the handshake
+func (this *FrontendSession) GetSignature() packet.ISignature {
+	if this.security != nil {
+		return this.security.signature
+	}
+	return packet.HMACSha1Signature
+}
+
+// GetSessionKeys get the session keys, nil before the handshake
+func (this *FrontendSession) GetSessionKeys() *packet.SessionKeys {
+	if this.security != nil {
+		return this.security.keys
+	}
+	return nil
+}
+
+// Handshake handle the handshake from the client, the session keys are derived by the X25519 key agreement,
+// then the crypto and the signature of the session are replaced with the ones of the session keys.
+// the reply carrying the server's public key and the cipher is written.
+func (this *FrontendSession) Handshake(pack packet.Packet, cipherName string) error {
+	sec, reply, err := acceptHandshake(pack, cipherName)
+	if err != nil {
+		return err
+	}
+	if _, err := this.Write(reply); err != nil {
+		return err
+	}
+	this.security, this.crypto = sec, sec.crypto
+	this.logger.Debug("handshake done", zap.String("cipher", cipherName))
+	return nil
+}
+
+// SetReassembler set the reassembler limiting the large packets from the client, call it before reading
+func (this *FrontendSession) SetReassembler(reassembler *packet.Reassembler) {
+	this.buffer.Free()
+	this.buffer = zd.NewReassemblyPacketBuffer(packet.MaxPacketSize, frontendPool.GetRdrBufPool(), reassembler)
+}
+
+// ReadPacket read a packet, the fragments of a large packet are reassembled
 func (this *FrontendSession) ReadPacket() (packet.Packet, error) {
+	// release the previous packet
+	this.buffer.Free()
 	if err := this.conn.ReadPacket(this.buffer); err != nil {
 		return nil, err
 	}
@@ -54,7 +122,10 @@ func (this *FrontendSession) ReadPacket() (packet.Packet, error) {
 	return this.buffer.Bytes(), nil
 }
 
-func (this *FrontendSession) Write(b []byte) (int, error) { return this.conn.Write(b) }
+// Write write a packet, a large packet is split into fragments
+func (this *FrontendSession) Write(b []byte) (int, error) {
+	return this.conn.Write(packet.Packet(b).Fragment())
+}
 
 func (this *FrontendSession) Close() {
 	if atomic.CompareAndSwapInt32(&this.closed, 0, 1) {
@@ -69,6 +140,11 @@ func (this *FrontendSession) BindBackendSession(backend *BackendSession) {
 	if this.backend == nil {
 		this.id = backend.NewFrontendSessionID()
 		this.backend = backend
+		this.logger = this.baseLogger.With(
+			zap.Uint32(logpkg.KeyBackendID, backend.GetID()),
+			zap.Uint32(logpkg.KeyConnID, this.id),
+			zap.String(logpkg.KeyTraceID, backend.GetTraceID(this.id)),
+		)
 		backend.AddFrontendSession(this)
 	}
 }
@@ -86,8 +162,7 @@ func (this *FrontendSession) WaitResponse() {
 	select {
 	case <-this.done:
 	case <-time.After(10 * time.Second):
-		//TODO: add log
-		//zaplog.S.Errorf("client-%d@%s: response timeout", s.id, s.ClientAddr())
+		this.logger.Error("response timeout")
 	}
 }
 
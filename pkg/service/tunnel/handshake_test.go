@@ -0,0 +1,174 @@
+package tunnel
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+func readPacket(t *testing.T, sess *BackendSession) packet.Packet {
+	req, err := sess.ReadRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer req.Free()
+	return append(packet.Packet{}, req.GetPacket()...)
+}
+
+// handshake key the agent session and the backend session connected by a pipe
+func handshake(t *testing.T, agentSess, backendSess *BackendSession) {
+	go agentSess.StartHandshake()
+	hello := readPacket(t, backendSess)
+	errChan := make(chan error, 1)
+	go func() { errChan <- backendSess.HandleHandshake(hello, packet.CipherChaCha20Poly1305) }()
+	reply := readPacket(t, agentSess)
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	if err := agentSess.HandleHandshake(reply, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	backendSess := NewBackendSession(7, backendConn)
+	if agentSess.GetCrypto() != packet.NoneCrypto || agentSess.GetSessionKeys() != nil {
+		t.Fatal("no crypto before the handshake")
+	}
+
+	go agentSess.StartHandshake()
+	hello := readPacket(t, backendSess)
+	errChan := make(chan error, 1)
+	go func() { errChan <- backendSess.HandleHandshake(hello, packet.CipherChaCha20Poly1305) }()
+	reply := readPacket(t, agentSess)
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	if err := agentSess.HandleHandshake(reply, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	agentKeys, backendKeys := agentSess.GetSessionKeys(), backendSess.GetSessionKeys()
+	if agentKeys == nil || backendKeys == nil || !bytes.Equal(agentKeys.Crypto, backendKeys.Crypto) {
+		t.Fatalf("agent keys = %v, backend keys = %v", agentKeys, backendKeys)
+	}
+
+	// the response from the backend is encrypted by the session keys
+	rsp := packet.NewFromData([]byte("hello"), nil, packet.NoneCompresser)
+	rsp.SetProtoMID(1)
+	go backendSess.Write(rsp)
+	sealed := readPacket(t, agentSess)
+	if !sealed.HasDataFlag(packet.FlagAEAD) {
+		t.Fatalf("sealed = %v", sealed)
+	}
+	opened, err := agentSess.GetCrypto().Decrypt(sealed)
+	if err != nil || string(opened.GetDataLoad()) != "hello" {
+		t.Errorf("opened = %v, err = %v", opened, err)
+	}
+
+	// a handshake can't re-key the session
+	if err := backendSess.HandleHandshake(hello, packet.CipherChaCha20Poly1305); err != ErrUnexpectedHandshake {
+		t.Errorf("repeated handshake err = %v", err)
+	}
+}
+
+func TestHandshakeNotFirst(t *testing.T) {
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	backendSess := NewBackendSession(7, backendConn)
+
+	go func() {
+		agentSess.Write(packet.PingPacket)
+		agentSess.StartHandshake()
+	}()
+	readPacket(t, backendSess)
+	hello := readPacket(t, backendSess)
+	if err := backendSess.HandleHandshake(hello, packet.CipherChaCha20Poly1305); err != ErrUnexpectedHandshake {
+		t.Errorf("handshake after a ping err = %v", err)
+	}
+	if backendSess.GetSessionKeys() != nil {
+		t.Error("the session shouldn't be keyed")
+	}
+}
+
+func TestForwardTamperedResponse(t *testing.T) {
+	InitFrontendPool()
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	backendSess := NewBackendSession(7, backendConn)
+	handshake(t, agentSess, backendSess)
+
+	clientConn, _ := net.Pipe()
+	defer clientConn.Close()
+	frontendSess := NewFrontendSession(clientConn)
+	frontendSess.BindBackendSession(agentSess)
+
+	rsp := packet.NewFromData([]byte("hello"), nil, packet.NoneCompresser)
+	rsp.SetProtoMID(1)
+	rsp.SetConnID(frontendSess.GetID())
+	sealed, err := backendSess.GetCrypto().Encrypt(rsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 0xFF
+	go backendSess.conn.Write(sealed)
+
+	req, err := agentSess.ReadRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwardToFrontend(agentSess, req)
+	select {
+	case <-frontendSess.done:
+	default:
+		t.Error("the response of the frontend session should be done even if it can't be decrypted")
+	}
+}
+
+func TestHandshakeTamperedPublicKey(t *testing.T) {
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	backendSess := NewBackendSession(7, backendConn)
+
+	// a man in the middle replaces the public key of the agent
+	go agentSess.StartHandshake()
+	hello := readPacket(t, backendSess)
+	mitm, _ := packet.NewKeyExchange()
+	copy(hello.GetCmdData(), mitm.PublicKey())
+	if err := backendSess.HandleHandshake(hello, packet.CipherChaCha20Poly1305); err != packet.ErrInvalidHandshakeMAC {
+		t.Errorf("tampered hello err = %v", err)
+	}
+	if backendSess.IsHandshakeDone() {
+		t.Error("the backend shouldn't be keyed by a tampered hello")
+	}
+
+	// and the public key of the backend in the reply
+	sec, reply, err := acceptHandshake(packet.NewHandshake(7, agentSess.kx.PublicKey(),
+		packet.HandshakeMAC(agentSess.kx.PublicKey(), nil, ""), ""), packet.CipherChaCha20Poly1305)
+	if err != nil || sec == nil {
+		t.Fatal(err)
+	}
+	copy(reply.GetCmdData(), mitm.PublicKey())
+	if err := agentSess.HandleHandshake(reply, ""); err != packet.ErrInvalidHandshakeMAC {
+		t.Errorf("tampered reply err = %v", err)
+	}
+	if agentSess.IsHandshakeDone() {
+		t.Error("the agent shouldn't be keyed by a tampered reply")
+	}
+}
@@ -0,0 +1,79 @@
+package tunnel
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+func TestRegisterBuildInfo(t *testing.T) {
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	backendSess := NewBackendSession(0, backendConn)
+
+	// the backend replies the register with a different major version
+	core.SetBuildInfo("v2.0.0", "", "")
+	defer core.SetBuildInfo("", "", "")
+	go backendSess.Register(7)
+
+	req, err := agentSess.ReadRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer req.Free()
+	pack := req.GetPacket()
+	if !pack.IsCmdProto() || pack.GetCmd() != packet.CmdRegister || pack.GetConnID() != 7 {
+		t.Fatalf("invalid register packet %v", pack)
+	}
+
+	mgr := NewBackendSessionMgr()
+	if err := mgr.handleBackendCmd(agentSess, pack); err != nil {
+		t.Fatalf("accept any backend without a checker : %v", err)
+	}
+	if info := agentSess.GetPeerBuildInfo(); info == nil || info.Version != "v2.0.0" {
+		t.Errorf("peer build info = %+v", info)
+	}
+
+	core.SetBuildInfo("v1.0.0", "", "")
+	mgr.SetBuildInfoChecker(core.CheckMajorVersion)
+	if err := mgr.handleBackendCmd(agentSess, pack); err == nil {
+		t.Error("expect refusing an incompatible backend")
+	}
+}
+
+func TestRegisterReply(t *testing.T) {
+	InitBackendPool()
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	backendSess := NewBackendSession(0, backendConn)
+	mgr := NewBackendSessionMgr()
+
+	// an older backend doesn't reply the register
+	go func() {
+		if req, err := backendSess.ReadRequest(); err == nil {
+			req.Free()
+		}
+	}()
+	if err := agentSess.Register(7); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.waitCmd(agentSess, packet.CmdRegister, 50*time.Millisecond); err == nil {
+		t.Error("expect refusing a backend not replying the register")
+	}
+
+	go backendSess.Register(7)
+	if err := mgr.waitCmd(agentSess, packet.CmdRegister, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if agentSess.GetPeerBuildInfo() == nil {
+		t.Error("the build info of the backend should be registered")
+	}
+}
@@ -0,0 +1,47 @@
+package tunnel
+
+import (
+	"github.com/overtalk/bgo/pkg/service/packet"
+)
+
+// security the crypto and the signature of a session negotiated by the handshake
+type security struct {
+	keys      *packet.SessionKeys
+	crypto    packet.ICrypto
+	signature packet.ISignature
+}
+
+func newSecurity(keys *packet.SessionKeys, cipherName string, isServer bool) (*security, error) {
+	crypto, err := keys.NewCrypto(cipherName, isServer)
+	if err != nil {
+		return nil, err
+	}
+	return &security{keys: keys, crypto: crypto, signature: keys.NewSignature()}, nil
+}
+
+// acceptHandshake accept a handshake from the client with the cipher of the session,
+// it returns the security of the session and the reply.
+// the public key of the client is verified with the sign secret before deriving the keys.
+func acceptHandshake(pack packet.Packet, cipherName string) (*security, packet.Packet, error) {
+	public, mac, _, err := packet.ParseHandshake(pack)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := packet.VerifyHandshakeMAC(mac, public, nil, ""); err != nil {
+		return nil, nil, err
+	}
+	kx, err := packet.NewKeyExchange()
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := kx.SessionKeys(public, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	sec, err := newSecurity(keys, cipherName, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	mac = packet.HandshakeMAC(public, kx.PublicKey(), cipherName)
+	return sec, packet.NewHandshake(pack.GetConnID(), kx.PublicKey(), mac, cipherName), nil
+}
@@ -0,0 +1,71 @@
+package tunnel
+
+import (
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/overtalk/bgo/pkg/log"
+)
+
+func TestTraceID(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logpkg.ReplaceLogger(zap.New(core))
+	InitFrontendPool()
+	InitBackendPool()
+
+	// the agent side
+	agentConn, backendConn := net.Pipe()
+	defer agentConn.Close()
+	defer backendConn.Close()
+	agentSess := NewBackendSession(7, agentConn)
+	clientConn, _ := net.Pipe()
+	defer clientConn.Close()
+	frontendSess := NewFrontendSession(clientConn)
+	frontendSess.BindBackendSession(agentSess)
+	frontendSess.GetLogger().Info("agent")
+
+	// the backend side, the id and the salt are registered by the agent
+	backendSess := NewBackendSession(0, backendConn)
+	go agentSess.Register(7)
+	req, err := backendSess.ReadRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backendSess.SetID(req.GetPacket().GetConnID())
+	if _, err := backendSess.HandleRegister(req.GetPacket()); err != nil {
+		t.Fatal(err)
+	}
+	req.Free()
+	backendSess.GetRequestLogger(frontendSess.GetID()).Info("backend")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v", entries)
+	}
+	want := agentSess.GetTraceID(frontendSess.GetID())
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if fields[logpkg.KeyTraceID] != want {
+			t.Errorf("%s : trace id = %v, want %s", entry.Message, fields[logpkg.KeyTraceID], want)
+		}
+		if fields[logpkg.KeyConnID] != frontendSess.GetID() {
+			t.Errorf("%s : conn id = %v, want %d", entry.Message, fields[logpkg.KeyConnID], frontendSess.GetID())
+		}
+	}
+}
+
+func TestTraceIDUnique(t *testing.T) {
+	InitBackendPool()
+	a, _ := net.Pipe()
+	b, _ := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	// the agents registering the same id, or an agent reconnecting
+	if NewBackendSession(7, a).GetTraceID(101) == NewBackendSession(7, b).GetTraceID(101) {
+		t.Error("trace ids of different tunnel connections collide")
+	}
+}
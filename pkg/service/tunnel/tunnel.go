@@ -1,25 +1,26 @@
 package tunnel
 
 import (
-	"github.com/overtalk/bgo/pkg/service/packet"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/core"
+	"github.com/overtalk/bgo/pkg/log"
+	"github.com/overtalk/bgo/pkg/service/packet"
+	"github.com/overtalk/bgo/utils/net"
 )
 
-func handleBackendResponse(sess *BackendSession) {
+func handleBackendResponse(mgr *BackendSessionMgr, sess *BackendSession) {
+	logger := sess.GetLogger()
 	defer func() {
 		if err := recover(); err != nil {
-			//TODO: add log
-			//zaplog.S.Error(err)
-			//zaplog.S.Error(zap.Stack("").String)
+			logger.Error("handle backend response panic", zap.Any("error", err), zap.Stack("stack"))
 		}
-		defaultBackendSessionMgr.DelSession(sess.GetID())
+		mgr.DelSession(sess.GetID())
 		sess.Close()
 	}()
 
-	// TODO: add log
-	//zaplog.S.Infof("connected: agent@%s ---> backend-%d@%s",
-	//	sess.conn.LocalAddr(), sess.GetID(), sess.ClientAddr())
+	logger.Info("backend connected", zap.String("local_addr", sess.conn.LocalAddr()))
 
 	// it's a long session
 	sess.Ping()
@@ -28,63 +29,112 @@ func handleBackendResponse(sess *BackendSession) {
 	for {
 		inRequest, err := sess.ReadRequest()
 		if err == nil {
+			if inPacket := inRequest.GetPacket(); inPacket.IsCmdSize() || inPacket.IsCmdProto() {
+				err = mgr.handleBackendCmd(sess, inPacket)
+				inRequest.Free()
+				if err != nil {
+					logger.Error("refuse backend", zap.Error(err))
+					break
+				}
+				continue
+			}
+			if !sess.IsHandshakeDone() {
+				// a plaintext response before the handshake
+				inRequest.Free()
+				logger.Error("refuse backend", zap.Error(errors.New("response before the handshake")))
+				break
+			}
 			go forwardToFrontend(sess, inRequest)
 		} else {
 			inRequest.Free()
-			if e := errors.Cause(err); !zeroutil.IsNetTimeout(e) {
-				// TODO: log
-				//zaplog.S.Errorf(
-				//	"backend-%d@%s: %v", sess.GetID(), sess.ClientAddr(), err)
+			if e := errors.Cause(err); !netutil.IsNetTimeout(e) {
+				logger.Error("read backend response error", zap.Error(err))
 				break
 			}
 		}
 	}
 }
 
+// handleBackendCmd handle the cmd from the backend, the backend replies the register with its build info
+func (mgr *BackendSessionMgr) handleBackendCmd(sess *BackendSession, pack packet.Packet) error {
+	cmd := pack.GetCmd()
+	switch cmd {
+	case packet.CmdPing:
+		sess.UpdatePing()
+	case packet.CmdRegister:
+		info, err := sess.HandleRegister(pack)
+		if err != nil {
+			return errors.Wrap(err, "invalid register")
+		}
+		if info == nil {
+			sess.GetLogger().Warn("backend registered without build info")
+			info = &core.BuildInfo{}
+		} else {
+			sess.GetLogger().Info("backend registered", info.Fields()...)
+		}
+		if mgr.buildInfoChecker != nil {
+			return mgr.buildInfoChecker(info)
+		}
+	case packet.CmdHandshake:
+		if err := sess.HandleHandshake(pack, ""); err != nil {
+			return errors.Wrap(err, "handshake")
+		}
+	default:
+		sess.GetLogger().Error("invalid cmd", zap.Uint16("cmd", cmd))
+	}
+	return nil
+}
+
 // forwardToFrontend forward the backend server's response to the frontend client
 func forwardToFrontend(sess *BackendSession, req *BackendRequest) {
+	inPacket := req.GetPacket()
+	connID := inPacket.GetConnID()
+	logger := sess.GetRequestLogger(connID)
 	defer func() {
 		if err := recover(); err != nil {
-			// TODO: log
-			//zaplog.S.Error(err)
-			//zaplog.S.Error(zap.Stack("").String)
+			logger.Error("forward to frontend panic", zap.Any("error", err), zap.Stack("stack"))
 		}
 		req.Free()
 	}()
 
-	inPacket := req.GetPacket()
-	// find the connected frontend session
-	connID := inPacket.GetConnID()
+	// find the connected frontend session, it's waiting for the response even if the response is invalid
 	frontendSess := sess.GetFrontendSession(connID)
 	if frontendSess == nil {
-		//zaplog.S.Errorf("client-%d: not found", connID)
-		// TODO: log
+		logger.Error("frontend session not found")
 		return
 	}
+	defer frontendSess.DoneResponse()
 	if frontendSess.IsClosed() {
-		// TODO: log
-		//zaplog.S.Errorf("client-%d@%s: closed",
-		//	connID, frontendSess.ClientAddr())
+		logger.Error("frontend session closed")
 		return
 	}
+
+	// decrypt the response by the session keys
+	inPacket, err := inPacket.Decrypt(sess.GetCrypto())
+	if err != nil {
+		logger.Error("decrypt backend response error", zap.Error(err))
+		return
+	}
+	logger = frontendSess.GetLogger().With(
+		zap.Uint8(logpkg.KeyMID, inPacket.GetProtoMID()),
+		zap.Uint8(logpkg.KeyAID, inPacket.GetProtoAID()),
+	)
 	// reset the server id
 	inPacket.SetConnID(sess.GetID())
 
-	// TODO: log
-	//zaplog.S.Debugf("client-%d@%s: response(%v)", connID,
-	//	frontendSess.ClientAddr(), inPacket)
+	logger.Debug("response", zap.Int("size", len(inPacket)))
 
 	// encrypt the packet
-	inPacket.Encrypt(packet.XORCrypto)
+	outPacket, err := inPacket.Encrypt(frontendSess.GetCrypto())
+	if err != nil {
+		logger.Error("encrypt response error", zap.Error(err))
+		return
+	}
 	// write to frontend buffer
-	_, err := frontendSess.Write(inPacket)
+	_, err = frontendSess.Write(outPacket)
 	if err == nil {
-		// TODO: log
-		//zaplog.S.Debugf("client-%d@%s: response(%d bytes) done",
-		//	frontendSess.GetID(), frontendSess.ClientAddr(), len(inPacket))
+		logger.Debug("response done", zap.Int("size", len(outPacket)))
 	} else {
-		// TODO: log
-		//zaplog.S.Errorf("client-%d@%s: %v", frontendSess.GetID(), frontendSess.ClientAddr(), err)
+		logger.Error("write response error", zap.Error(err))
 	}
-	frontendSess.DoneResponse()
 }
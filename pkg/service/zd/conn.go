@@ -60,10 +60,20 @@ func (c *BaseConn) RemoteAddr() string {
 
 // ReadPacket read the basic packet
 func (c *BaseConn) ReadPacket(p IPacketBuffer) (err error) {
+	r := &connReader{conn: c}
 	if c.rdTimeout > 0 {
-		c.netConn.SetReadDeadline(time.Now().Add(c.rdTimeout))
+		r.deadline = time.Now().Add(c.rdTimeout)
+		c.netConn.SetReadDeadline(r.deadline)
+	}
+	n, err := p.ReadFrom(r)
+	if r.reset {
+		// the deadline of the reassembly is reset
+		c.netConn.SetReadDeadline(time.Time{})
+	}
+	bytesRead.Add(float64(n))
+	if err == nil {
+		packetsRead.Inc()
 	}
-	_, err = p.ReadFrom(c.bufReader)
 	return
 }
 
@@ -72,16 +82,42 @@ func (c *BaseConn) Read(b []byte) (int, error) {
 	if c.rdTimeout > 0 {
 		c.netConn.SetReadDeadline(time.Now().Add(c.rdTimeout))
 	}
-	return c.bufReader.Read(b)
+	n, err := c.bufReader.Read(b)
+	bytesRead.Add(float64(n))
+	return n, err
 }
 
-// Write write some bytes to the wrapped net conn
+// Write write some bytes to the wrapped net conn, each call is counted as a packet
 func (c *BaseConn) Write(b []byte) (int, error) {
 	if c.wrTimeout > 0 {
 		c.netConn.SetWriteDeadline(time.Now().Add(c.wrTimeout))
 	}
-	return c.netConn.Write(b)
+	n, err := c.netConn.Write(b)
+	bytesWritten.Add(float64(n))
+	if err == nil {
+		packetsWritten.Inc()
+	}
+	return n, err
 }
 
 // Close close the wrapped net conn
 func (c *BaseConn) Close() (err error) { return c.netConn.Close() }
+
+// connReader the buffered reader of a BaseConn reading a packet, the deadline of the reassembly of
+// a large packet can be set on it, which never exceeds the deadline of the read timeout
+type connReader struct {
+	conn     *BaseConn
+	deadline time.Time // the deadline of the read timeout, zero if no timeout
+	reset    bool      // the deadline should be reset after reading
+}
+
+func (r *connReader) Read(b []byte) (int, error) { return r.conn.bufReader.Read(b) }
+
+// SetReadDeadline set the deadline of the following reads
+func (r *connReader) SetReadDeadline(t time.Time) error {
+	if !r.deadline.IsZero() && r.deadline.Before(t) {
+		t = r.deadline
+	}
+	r.reset = r.deadline.IsZero()
+	return r.conn.netConn.SetReadDeadline(t)
+}
@@ -1,6 +1,7 @@
 package zd
 
 import (
+	"errors"
 	"io"
 	"net"
 	"sync"
@@ -74,6 +75,9 @@ func (l *limitListenerConn) Close() error {
 	return err
 }
 
+// ErrListenerStopped is returned by Accept after the NetListener is stopped or closed
+var ErrListenerStopped = errors.New("listener is stopped")
+
 // socket types
 const (
 	unixSocket = "unix"
@@ -107,7 +111,8 @@ type NetListener struct {
 	maxConn int
 	closed  int32
 
-	wg *sync.WaitGroup
+	wg      *sync.WaitGroup
+	service *NetService // count the connections of the service
 	// if readSynced is true, when Listener is closed,
 	// all its connections will not read any data.
 	readSynced bool
@@ -135,6 +140,10 @@ func (nl *NetListener) Accept() (net.Conn, error) {
 		nl.addConn()
 		return &NetListenerConn{c, 0, nl}, nil
 	}
+	if nl.isClosed() {
+		// the deadline error of Stop is temporary, the servicer should not retry
+		return nil, ErrListenerStopped
+	}
 	return nil, err
 }
 
@@ -162,10 +171,16 @@ func (nl *NetListener) isClosed() bool {
 // addConn the listener has accepted a conn
 func (nl *NetListener) addConn() {
 	nl.wg.Add(1)
+	if nl.service != nil {
+		nl.service.RegisterConn()
+	}
 }
 
 // delConn a conn form the listener has been done
 func (nl *NetListener) delConn() {
+	if nl.service != nil {
+		nl.service.UnregisterConn()
+	}
 	nl.wg.Done()
 }
 
@@ -0,0 +1,21 @@
+package zd
+
+import (
+	"github.com/overtalk/bgo/pkg/metrics"
+)
+
+var (
+	connAccepted = metricspkg.NewCounterVec("bgo_net_conn_accepted_total", "Total number of accepted connections of each net service.", "service")
+	connActive   = metricspkg.NewGaugeVec("bgo_net_conn_active", "Number of active connections of each net service.", "service")
+	packetsTotal = metricspkg.NewCounterVec("bgo_net_packets_total", "Total number of packets read or written by connections.", "direction")
+	bytesTotal   = metricspkg.NewCounterVec("bgo_net_bytes_total", "Total number of bytes read or written by connections.", "direction")
+
+	packetsRead    = packetsTotal.With("read")
+	packetsWritten = packetsTotal.With("write")
+	bytesRead      = bytesTotal.With("read")
+	bytesWritten   = bytesTotal.With("write")
+)
+
+func init() {
+	metricspkg.MustRegister(connAccepted, connActive, packetsTotal, bytesTotal)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/overtalk/bgo/3rdparty/slab"
 	"github.com/pkg/errors"
 	"io"
+	"time"
 )
 
 // IPacketReader read some data to a IPacketBuffer
@@ -26,11 +27,28 @@ type IPacketBuffer interface {
 //////////////////////////////////////////////////////////////////
 //////////////////////////////////////////////////////////////////
 
+// IReassembler reassemble the fragments of a large packet exceeding the max size of a buffer
+type IReassembler interface {
+	// IsFragment check whether the frame is a fragment
+	IsFragment(frame []byte) bool
+	// Reassemble reassemble a packet from the first fragment and the following ones read by next before the deadline
+	Reassemble(first []byte, next func(deadline time.Time) ([]byte, error)) ([]byte, error)
+}
+
+// deadlineReader a reader whose reading stops at a deadline, eg: the reader of a BaseConn
+type deadlineReader interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
 // A basePacketBuffer with a buffer and valid size
 type basePacketBuffer struct {
 	data    []byte
 	maxSize int
 	pool    slab.Pool
+	// whether the data is allocated from the pool, a reassembled packet isn't
+	pooled      bool
+	reassembler IReassembler
 }
 
 // 确保 basePacketBuffer 实现了 IPacketBuffer 接口
@@ -41,6 +59,12 @@ func NewPacketBuffer(maxSize int, pool slab.Pool) IPacketBuffer {
 	return &basePacketBuffer{data: nil, maxSize: maxSize, pool: pool}
 }
 
+// NewReassemblyPacketBuffer create a IPacketBuffer interface reassembling the fragments of a large packet,
+// the maxSize limits each fragment and the reassembler limits the reassembled packet
+func NewReassemblyPacketBuffer(maxSize int, pool slab.Pool, reassembler IReassembler) IPacketBuffer {
+	return &basePacketBuffer{data: nil, maxSize: maxSize, pool: pool, reassembler: reassembler}
+}
+
 // Alloc get the underlying buffer
 func (buf *basePacketBuffer) alloc(size int) {
 	if size > buf.maxSize {
@@ -48,13 +72,41 @@ func (buf *basePacketBuffer) alloc(size int) {
 	}
 	if buf.pool != nil {
 		buf.data = buf.pool.Alloc(size)
+		buf.pooled = true
 	} else {
 		buf.data = make([]byte, size)
 	}
 }
 
-// ReadPacket read a packet of data from a Reader
+// ReadPacket read a packet of data from a Reader, the fragments are reassembled if it's a fragment
 func (buf *basePacketBuffer) ReadFrom(r io.Reader) (int, error) {
+	n, err := buf.readFrame(r)
+	if err != nil || buf.reassembler == nil || !buf.reassembler.IsFragment(buf.data) {
+		return n, err
+	}
+
+	// the following fragments are read into a temporary buffer before the deadline of the reassembly
+	frame := &basePacketBuffer{maxSize: buf.maxSize, pool: buf.pool}
+	data, err := buf.reassembler.Reassemble(buf.data, func(deadline time.Time) ([]byte, error) {
+		frame.Free()
+		if dr, ok := r.(deadlineReader); ok {
+			dr.SetReadDeadline(deadline)
+		}
+		nn, err := frame.readFrame(r)
+		n += nn
+		return frame.data, err
+	})
+	frame.Free()
+	buf.Free()
+	if err != nil {
+		return 0, err
+	}
+	buf.data = data
+	return n, nil
+}
+
+// readFrame read a frame with the size header
+func (buf *basePacketBuffer) readFrame(r io.Reader) (int, error) {
 	var sizeHeader [2]byte
 	// read data length(2 bytes)
 	nn, err := io.ReadFull(r, sizeHeader[:2])
@@ -90,15 +142,18 @@ func (buf *basePacketBuffer) Bytes() []byte {
 
 // Clone clone the underlying data excluding the buf field
 func (buf *basePacketBuffer) Clone() IPacketBuffer {
-	return &basePacketBuffer{data: nil, maxSize: buf.maxSize, pool: buf.pool}
+	return &basePacketBuffer{data: nil, maxSize: buf.maxSize, pool: buf.pool, reassembler: buf.reassembler}
 }
 
 // Free release the buffer to its pool
 func (buf *basePacketBuffer) Free() {
-	if buf.pool != nil && buf.data != nil {
+	if buf.data == nil {
+		return
+	}
+	if buf.pooled {
 		buf.pool.Free(buf.data)
-		buf.data = nil
 	}
+	buf.data, buf.pooled = nil, false
 }
 
 //////////////////////////////////////////////////////////////////
@@ -1,12 +1,17 @@
 package zd
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/overtalk/bgo/utils/net"
 )
 
 // INetServicer a net service
@@ -19,9 +24,10 @@ type INetServicer interface {
 
 // A NetService supporting stoppable listeners
 type NetService struct {
-	listener *NetListener // listener instance
-	servicer INetServicer // listener handler
-	connNum  int32        // current connection number
+	listener *NetListener                  // listener instance
+	limiter  *netutil.DynamicLimitListener // limit the connections of the listener, served by the servicer
+	servicer INetServicer                  // listener handler
+	connNum  int32                         // current connection number
 }
 
 // GetConnNum get the current active conn number
@@ -34,19 +40,25 @@ func (ns *NetService) GetMaxConnNum() int {
 	return ns.listener.GetMaxConn()
 }
 
-// SetMaxConnNum set the max active conn number
+// SetMaxConnNum set the max active conn number, it takes effect immediately
 func (ns *NetService) SetMaxConnNum(n int) {
 	ns.listener.SetMaxConn(n)
+	if ns.limiter != nil {
+		ns.limiter.SetLimit(n)
+	}
 }
 
 // RegisterConn register a network connection
 func (ns *NetService) RegisterConn() {
 	atomic.AddInt32(&ns.connNum, 1)
+	connAccepted.With(ns.GetName()).Inc()
+	connActive.With(ns.GetName()).Inc()
 }
 
 // UnregisterConn unregister a network connection
 func (ns *NetService) UnregisterConn() {
 	atomic.AddInt32(&ns.connNum, -1)
+	connActive.With(ns.GetName()).Dec()
 }
 
 // GetName get the service's name
@@ -59,9 +71,17 @@ func (ns *NetService) GetAddr() string {
 	return ns.listener.GetAddr()
 }
 
+// IsListening check whether the underlying listener is accepting connections
+func (ns *NetService) IsListening() bool {
+	return ns.listener != nil && !ns.listener.isClosed()
+}
+
 // CloseListener close the underlying listener
 func (ns *NetService) CloseListener() {
-	if ns.listener != nil {
+	if ns.limiter != nil {
+		// wake up the servicer waiting for the connection limitation
+		ns.limiter.Close()
+	} else if ns.listener != nil {
 		ns.listener.Close()
 	}
 }
@@ -81,11 +101,7 @@ func (ns *NetService) Start() {
 			zap.S().Error(zap.Stack("").String)
 		}
 	}()
-	var l net.Listener = ns.listener
-	if maxConn := ns.listener.GetMaxConn(); maxConn > 0 {
-		l = LimitListener(l, maxConn)
-	}
-	ns.servicer.Serve(l)
+	ns.servicer.Serve(ns.limiter)
 }
 
 // A NetServer contains several network services
@@ -95,13 +111,15 @@ type NetServer struct {
 	exitTimeout time.Duration
 	exitChan    chan struct{}
 	exitOnce    sync.Once
+	started     bool
 }
 
 const (
 	defaultExitTimeout = 3 * time.Minute
 )
 
-func newNetServer() *NetServer {
+// NewNetServer create a network server without any service
+func NewNetServer() *NetServer {
 	return &NetServer{
 		services:    map[string]*NetService{},
 		waitGroup:   new(sync.WaitGroup),
@@ -115,9 +133,64 @@ func (mgr *NetServer) GetService(name string) *NetService {
 	return mgr.services[name]
 }
 
-// AddService add a net service
-func (mgr *NetServer) AddService(ns INetServicer) {
+// GetServiceNames get the names of all network services
+func (mgr *NetServer) GetServiceNames() []string {
+	names := make([]string, 0, len(mgr.services))
+	for name := range mgr.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetConnNum get the active conn number of all network services
+func (mgr *NetServer) GetConnNum() int32 {
+	var n int32
+	for _, ns := range mgr.services {
+		n += ns.GetConnNum()
+	}
+	return n
+}
+
+// AddService add a net service, it should be called before Start
+func (mgr *NetServer) AddService(ns INetServicer) error {
+	if mgr.started {
+		return errors.New("net server has been started")
+	}
+	if _, ok := mgr.services[ns.GetName()]; ok {
+		return fmt.Errorf("net service %s is already added", ns.GetName())
+	}
 	mgr.services[ns.GetName()] = &NetService{servicer: ns, connNum: 0}
+	return nil
+}
+
+// Start start listening and serving all network services,
+// the listeners created are closed if any of them fails
+func (mgr *NetServer) Start() error {
+	if mgr.started {
+		return errors.New("net server has been started")
+	}
+	if err := mgr.initListeners(); err != nil {
+		mgr.closeListeners()
+		return err
+	}
+	mgr.started = true
+	mgr.runServices()
+	return nil
+}
+
+// Stop stop accepting and wait the active connections within exitTimeout, then close all listeners.
+func (mgr *NetServer) Stop() error {
+	if !mgr.started {
+		return nil
+	}
+	mgr.stopListeners()
+	drained := mgr.waitListeners()
+	mgr.closeListeners()
+	if !drained {
+		return fmt.Errorf("net server exit with %d active connections after %v", mgr.GetConnNum(), mgr.exitTimeout)
+	}
+	return nil
 }
 
 // startService start a network service
@@ -132,7 +205,7 @@ func (mgr *NetServer) runServices() {
 	}
 }
 
-// initServices init all net services
+// initServices init all net services, the servicers should create listeners by netutil.Listen
 func (mgr *NetServer) initListeners() error {
 	// start listening
 	for _, ns := range mgr.services {
@@ -140,31 +213,38 @@ func (mgr *NetServer) initListeners() error {
 		if err != nil {
 			return err
 		}
+		listener, ok := l.(Listener)
+		if !ok {
+			l.Close()
+			return fmt.Errorf("net service %s : listener %T does not support deadline", ns.GetName(), l)
+		}
 		opt := ns.servicer.GetListenerOption()
 		ns.listener = &NetListener{
-			Listener:   l.(Listener),
-			address:    opt.Address,
+			Listener:   listener,
+			address:    listener.Addr().String(), // the bound address, eg: the port is chosen if it's 0
 			maxConn:    opt.MaxConn,
 			closed:     0,
 			wg:         mgr.waitGroup,
+			service:    ns,
 			readSynced: opt.ReadSynced,
 		}
+		ns.limiter = netutil.NewDynamicLimitListener(ns.listener, opt.MaxConn)
 	}
 	return nil
 }
 
-// getExitTimeout get the exit timeout
-func (mgr *NetServer) getExitTimeout() time.Duration {
+// GetExitTimeout get the exit timeout
+func (mgr *NetServer) GetExitTimeout() time.Duration {
 	return mgr.exitTimeout
 }
 
-// getExitTimeoutInSecond get the exit timeout in several seconds
-func (mgr *NetServer) getExitTimeoutInSecond() int64 {
+// GetExitTimeoutInSecond get the exit timeout in several seconds
+func (mgr *NetServer) GetExitTimeoutInSecond() int64 {
 	return int64(mgr.exitTimeout / time.Second)
 }
 
-// setExitTimeout set the exit timeout
-func (mgr *NetServer) setExitTimeout(t time.Duration) {
+// SetExitTimeout set the exit timeout
+func (mgr *NetServer) SetExitTimeout(t time.Duration) {
 	if t > 0 {
 		mgr.exitTimeout = t
 	}
@@ -177,13 +257,20 @@ func (mgr *NetServer) stopListeners() {
 	}
 }
 
+// closeListeners close all listeners
+func (mgr *NetServer) closeListeners() {
+	for _, v := range mgr.services {
+		v.CloseListener()
+	}
+}
+
 // exitWaitListeners wait listeners not anymore
 func (mgr *NetServer) stopWaitListeners() {
 	mgr.exitOnce.Do(func() { close(mgr.exitChan) })
 }
 
-// waitListeners wait all listeners exit safely
-func (mgr *NetServer) waitListeners() {
+// waitListeners wait all listeners exit safely, returns false on timeout
+func (mgr *NetServer) waitListeners() bool {
 	if mgr.exitTimeout > 0 {
 		// shutdown process safely
 		go func() {
@@ -193,6 +280,8 @@ func (mgr *NetServer) waitListeners() {
 		select {
 		case <-mgr.exitChan:
 		case <-time.After(mgr.exitTimeout):
+			return false
 		}
 	}
+	return true
 }
@@ -0,0 +1,152 @@
+package zd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/overtalk/bgo/utils/net"
+)
+
+// echoServicer echo the first byte of each connection
+type echoServicer struct {
+	name    string
+	address string
+	maxConn int
+}
+
+func (s *echoServicer) GetName() string { return s.name }
+
+func (s *echoServicer) GetListenerOption() ListenerOption {
+	return ListenerOption{Address: s.address, MaxConn: s.maxConn}
+}
+
+func (s *echoServicer) NewListener() (net.Listener, error) {
+	return netutil.Listen("tcp", s.address)
+}
+
+func (s *echoServicer) Serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			buf := make([]byte, 1)
+			if _, err := conn.Read(buf); err == nil {
+				conn.Write(buf)
+			}
+		}()
+	}
+}
+
+func waitConnNum(t *testing.T, svr *NetServer, n int32) {
+	deadline := time.Now().Add(time.Second)
+	for svr.GetConnNum() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("conn num = %d, want %d", svr.GetConnNum(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNetServer(t *testing.T) {
+	svr := NewNetServer()
+	svr.SetExitTimeout(time.Second)
+	if err := svr.AddService(&echoServicer{name: "echo", address: "127.0.0.1:0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svr.AddService(&echoServicer{name: "echo", address: "127.0.0.1:0"}); err == nil {
+		t.Error("duplicated service should fail")
+	}
+	if err := svr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := svr.AddService(&echoServicer{name: "other", address: "127.0.0.1:0"}); err == nil {
+		t.Error("adding a service after start should fail")
+	}
+
+	ns := svr.GetService("echo")
+	if !ns.IsListening() {
+		t.Fatal("service is not listening")
+	}
+	conn, err := net.Dial("tcp", ns.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitConnNum(t, svr, 1)
+
+	// Stop waits for the active connection
+	done := make(chan error)
+	go func() { done <- svr.Stop() }()
+	time.Sleep(50 * time.Millisecond)
+	if ns.IsListening() {
+		t.Error("service is still listening after stop")
+	}
+
+	conn.Write([]byte{'x'})
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); err != nil || buf[0] != 'x' {
+		t.Errorf("read %q, %v", buf, err)
+	}
+	conn.Close()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	waitConnNum(t, svr, 0)
+}
+
+func TestNetServerExitTimeout(t *testing.T) {
+	svr := NewNetServer()
+	svr.SetExitTimeout(50 * time.Millisecond)
+	svr.AddService(&echoServicer{name: "echo", address: "127.0.0.1:0"})
+	if err := svr.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", svr.GetService("echo").listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitConnNum(t, svr, 1)
+
+	if err := svr.Stop(); err == nil {
+		t.Error("stop should fail with an active connection")
+	}
+}
+
+func TestNetServiceMaxConn(t *testing.T) {
+	svr := NewNetServer()
+	svr.SetExitTimeout(time.Second)
+	svr.AddService(&echoServicer{name: "echo", address: "127.0.0.1:0", maxConn: 1})
+	if err := svr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ns := svr.GetService("echo")
+	address := ns.listener.Addr().String()
+
+	first, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := svr.GetConnNum(); n != 1 {
+		t.Errorf("conn num = %d, want 1", n)
+	}
+
+	// raising the limitation accepts the pending connection
+	ns.SetMaxConnNum(2)
+	waitConnNum(t, svr, 2)
+
+	first.Close()
+	second.Close()
+	if err := svr.Stop(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,83 +1,43 @@
+// Package zaplog is kept for compatibility, the logger is built by logpkg,
+// use logpkg or the internal.log module instead.
 package zaplog
 
 import (
 	"log"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
 
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-
-	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
-	fileutil "github.com/overtalk/bgo/utils/file"
+	"github.com/overtalk/bgo/pkg/log"
 )
 
+// InitLogger build the global logger of logpkg with the config, which replaces the zap globals as well
 func InitLogger(cfg *Config) {
-	var encoder zapcore.Encoder
-
+	encoding := logpkg.EncodingConsole
 	if cfg.UseJsonFormat {
-		encoder = zapcore.NewJSONEncoder(encoderConfig)
-	} else {
-		encoder = zapcore.NewConsoleEncoder(encoderConfig)
-	}
-
-	var minLevel zapcore.Level
-	if err := minLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
-		log.Fatalf("failed to parse log level : %s\n", cfg.LogLevel)
+		encoding = logpkg.EncodingJSON
 	}
-	Level.SetLevel(minLevel)
 
-	var cores []zapcore.Core
+	var sinks []logpkg.SinkConfig
 	if len(cfg.LogFilePath) > 0 {
-		if !fileutil.PathExists(cfg.LogFilePath) {
-			panic("log path " + cfg.LogFilePath + " is absent")
-		}
-		if !fileutil.IsDir(cfg.LogFilePath) {
-			panic("log path " + cfg.LogFilePath + " should be a dir")
-		}
-
-		cfg.LogFilePath = filepath.Join(cfg.LogFilePath, cfg.LogFilePrefix+".log")
-
-		hook, err := rotatelogs.New(
-			strings.Replace(cfg.LogFilePath, ".log", "", -1)+"-%Y%m%d%H.log",
-			rotatelogs.WithLinkName(cfg.LogFilePath),
-			rotatelogs.WithMaxAge(time.Duration(cfg.MaxKeepHour)*time.Hour),
-			rotatelogs.WithRotationTime(time.Duration(cfg.RotationHour)*time.Hour),
-		)
-		if err != nil {
-			log.Fatalf("failed to build logger : %v\n", err)
-		}
-
-		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(hook), Level))
+		sinks = append(sinks, logpkg.SinkConfig{
+			Name:         logpkg.SinkFile,
+			Type:         logpkg.SinkFile,
+			Encoding:     encoding,
+			Path:         cfg.LogFilePath,
+			Prefix:       cfg.LogFilePrefix,
+			MaxKeepHour:  cfg.MaxKeepHour,
+			RotationHour: cfg.RotationHour,
+		})
 	}
-
-	if cfg.OpenConsole || len(cores) == 0 {
-		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), Level))
+	if cfg.OpenConsole || len(sinks) == 0 {
+		sinks = append(sinks, logpkg.SinkConfig{Name: logpkg.SinkConsole, Type: logpkg.SinkConsole, Encoding: encoding})
 	}
 
-	zap.ReplaceGlobals(zap.New(zapcore.NewTee(cores...), zap.AddCaller()))
-	return
+	if err := logpkg.Init(&logpkg.Config{Level: cfg.LogLevel, Sinks: sinks}); err != nil {
+		log.Fatalf("failed to build logger : %v\n", err)
+	}
 }
 
-var (
-	Level         = zap.NewAtomicLevelAt(zapcore.InfoLevel)
-	encoderConfig = zapcore.EncoderConfig{
-		TimeKey:      "ts",
-		MessageKey:   "msg",
-		LevelKey:     "lvl",
-		CallerKey:    "caller",
-		EncodeLevel:  zapcore.CapitalLevelEncoder,
-		EncodeCaller: zapcore.ShortCallerEncoder,
-		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendString(t.Format("2006-01-02 15:04:05"))
-		},
-		EncodeDuration: func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendInt64(int64(d) / 1000000)
-		},
-	}
-)
+// Level the global level of logpkg
+var Level = logpkg.GetAtomicLevel()
 
 func init() {
 	InitLogger(&Config{
@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/overtalk/bgo/app"
 
+	_ "github.com/overtalk/bgo/internal/log/src"
 	_ "github.com/overtalk/bgo/internal/pprof/src"
 	_ "github.com/overtalk/bgo/modules/mysql/src"
 	_ "github.com/overtalk/bgo/modules/redis/src"
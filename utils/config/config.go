@@ -0,0 +1,82 @@
+package configutil
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Decoder decode the content of a config file into v
+type Decoder func(data []byte, v interface{}) error
+
+var (
+	lock     sync.RWMutex
+	decoders = map[string]Decoder{
+		".xml":  xml.Unmarshal,
+		".json": json.Unmarshal,
+		".yaml": yaml.Unmarshal,
+		".yml":  yaml.Unmarshal,
+		".toml": toml.Unmarshal,
+	}
+)
+
+// RegisterDecoder register the decoder of the config files with the extension, eg: ".ini"
+func RegisterDecoder(ext string, decoder Decoder) {
+	lock.Lock()
+	defer lock.Unlock()
+	decoders[strings.ToLower(ext)] = decoder
+}
+
+// GetDecoder get the decoder of the config file according to its extension
+func GetDecoder(path string) (Decoder, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	lock.RLock()
+	defer lock.RUnlock()
+	decoder, ok := decoders[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported config format %q of %s", ext, path)
+	}
+	return decoder, nil
+}
+
+// Load read the config file and decode it into v,
+// the decoder is chosen by the file extension, {xml|json|yaml|yml|toml}.
+// Fields tagged `default:"..."` are set if missing in the file.
+// After decoding, the overrides of the module bound to the path are applied,
+// "${ENV_VAR}" and "file://" in string values are resolved, and at last the config is validated.
+func Load(path string, v interface{}) error {
+	decoder, err := GetDecoder(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := applyDefaults(v, false); err != nil {
+		return fmt.Errorf("config %s : %v", path, err)
+	}
+	if err := decoder(data, v); err != nil {
+		return fmt.Errorf("decode config %s : %v", path, err)
+	}
+	if err := override(path, v); err != nil {
+		return fmt.Errorf("config %s : %v", path, err)
+	}
+	if err := applyDefaults(v, true); err != nil {
+		return fmt.Errorf("config %s : %v", path, err)
+	}
+	if err := interpolateFields(v); err != nil {
+		return fmt.Errorf("config %s : %v", path, err)
+	}
+	return Validate(path, v)
+}
@@ -0,0 +1,197 @@
+package configutil_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/overtalk/bgo/utils/config"
+)
+
+type testConfig struct {
+	Name string `xml:"name" json:"name" yaml:"name" toml:"name"`
+	Port int    `xml:"port" json:"port" yaml:"port" toml:"port"`
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bgo-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"test.xml":  "<xml><name>bgo</name><port>9999</port></xml>",
+		"test.json": `{"name": "bgo", "port": 9999}`,
+		"test.yaml": "name: bgo\nport: 9999\n",
+		"test.yml":  "name: bgo\nport: 9999\n",
+		"test.toml": "name = \"bgo\"\nport = 9999\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &testConfig{}
+		if err := configutil.Load(path, cfg); err != nil {
+			t.Errorf("load %s : %v", name, err)
+			continue
+		}
+		if cfg.Name != "bgo" || cfg.Port != 9999 {
+			t.Errorf("load %s : got %+v", name, cfg)
+		}
+	}
+
+	if err := configutil.Load(filepath.Join(dir, "test.ini"), &testConfig{}); err == nil {
+		t.Error("expect an error for unsupported format")
+	}
+}
+
+type secretConfig struct {
+	Address struct {
+		Item []string `xml:"item" json:"item"`
+	} `xml:"address" json:"address"`
+	Username string `xml:"username" json:"username"`
+	Password string `xml:"password" json:"password" secret:"true"`
+	PoolSize int    `xml:"poolSize" json:"poolSize"`
+}
+
+func TestOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bgo-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(secret, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.json")
+	content := `{"address": {"item": ["${BGO_TEST_HOST}:6379"]}, "username": "root", "password": "file://` + secret + `", "poolSize": 10}`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("BGO_TEST_HOST", "127.0.0.1")
+	os.Setenv("BGO_TEST_MODULE_POOLSIZE", "20")
+	os.Setenv("BGO_TEST_MODULE_USERNAME", "env")
+	defer os.Unsetenv("BGO_TEST_HOST")
+	defer os.Unsetenv("BGO_TEST_MODULE_POOLSIZE")
+	defer os.Unsetenv("BGO_TEST_MODULE_USERNAME")
+	if err := configutil.SetOverride("test.module.username=flag"); err != nil {
+		t.Fatal(err)
+	}
+	configutil.Bind(path, "test.module")
+
+	cfg := &secretConfig{}
+	if err := configutil.Load(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Address.Item[0] != "127.0.0.1:6379" {
+		t.Errorf("env interpolation : got %s", cfg.Address.Item[0])
+	}
+	if cfg.Password != "secret" {
+		t.Errorf("file interpolation : got %s", cfg.Password)
+	}
+	if cfg.PoolSize != 20 {
+		t.Errorf("env override : got %d", cfg.PoolSize)
+	}
+	if cfg.Username != "flag" {
+		t.Errorf("flag override : got %s", cfg.Username)
+	}
+
+	redacted := configutil.Redact(cfg).(*secretConfig)
+	if redacted.Password != configutil.RedactedValue || cfg.Password != "secret" {
+		t.Errorf("redact : got %s, origin %s", redacted.Password, cfg.Password)
+	}
+}
+
+// nestedConfig a config with secrets in the nested pointers, slices and maps
+type nestedConfig struct {
+	Main    *secretConfig           `json:"main"`
+	Backups []secretConfig          `json:"backups"`
+	Named   map[string]secretConfig `json:"named"`
+}
+
+func (c *nestedConfig) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return configutil.MarshalLogObject(enc, c)
+}
+
+func TestRedactNested(t *testing.T) {
+	cfg := &nestedConfig{
+		Main:    &secretConfig{Password: "main"},
+		Backups: []secretConfig{{Password: "backup"}},
+		Named:   map[string]secretConfig{"a": {Password: "named"}},
+	}
+	redacted := configutil.Redact(cfg).(*nestedConfig)
+	if redacted.Main.Password != configutil.RedactedValue || redacted.Backups[0].Password != configutil.RedactedValue ||
+		redacted.Named["a"].Password != configutil.RedactedValue {
+		t.Errorf("redact : got %+v", redacted)
+	}
+	if cfg.Main.Password != "main" || cfg.Backups[0].Password != "backup" || cfg.Named["a"].Password != "named" {
+		t.Errorf("the origin is modified : %+v", cfg)
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	if err := cfg.MarshalLogObject(enc); err != nil {
+		t.Fatal(err)
+	}
+	if main := enc.Fields["main"].(*secretConfig); main.Password != configutil.RedactedValue {
+		t.Errorf("log object : got %+v", main)
+	}
+	if _, ok := enc.Fields["backups"]; !ok {
+		t.Errorf("log object : got %v", enc.Fields)
+	}
+}
+
+type validateConfig struct {
+	Network  string   `xml:"network" default:"tcp" validate:"oneof=tcp|unix"`
+	Host     string   `xml:"host" default:"0.0.0.0"`
+	Port     int      `xml:"port" validate:"required,min=1,max=65535"`
+	Items    []string `xml:"item" default:"a,b"`
+	MaxConn  int      `xml:"maxConn" default:"100" validate:"min=0"`
+	Services []struct {
+		Name    string `xml:"name" validate:"required"`
+		Network string `xml:"network" default:"tcp"`
+	} `xml:"services>service"`
+}
+
+func TestDefaultAndValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bgo-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(path, []byte("<xml><port>9999</port><maxConn>0</maxConn><services><service><name>a</name></service></services></xml>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &validateConfig{}
+	if err := configutil.Load(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Network != "tcp" || cfg.Host != "0.0.0.0" || len(cfg.Items) != 2 || cfg.MaxConn != 0 {
+		t.Errorf("defaults : got %+v", cfg)
+	}
+	if len(cfg.Services) != 1 || cfg.Services[0].Network != "tcp" {
+		t.Errorf("defaults of slice elements : got %+v", cfg.Services)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("<xml><network>udp</network><port>70000</port></xml>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = configutil.Load(path, &validateConfig{})
+	verr, ok := err.(*configutil.ValidationError)
+	if !ok {
+		t.Fatalf("expect a validation error, got %v", err)
+	}
+	if verr.Path != path || len(verr.Fields) != 2 || verr.Fields[0].Field != "network" || verr.Fields[1].Field != "port" {
+		t.Errorf("validation error : %v", verr)
+	}
+}
@@ -0,0 +1,31 @@
+package configutil
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// applyDefaults set the values of fields tagged `default:"..."`,
+// scalar fields are set before decoding so that the values in the file take precedence,
+// slices are set after decoding only if they are empty, since some decoders append to them.
+// the fields of slice elements only exist after decoding, so they are set with slices if they are zero.
+func applyDefaults(v interface{}, slices bool) error {
+	return walk(v, func(key string, field reflect.StructField, value reflect.Value) error {
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+		afterDecoding := value.Kind() == reflect.Slice || strings.Contains(key, "[")
+		if afterDecoding != slices {
+			return nil
+		}
+		if afterDecoding && !isZero(value) {
+			return nil
+		}
+		if err := setValue(value, def); err != nil {
+			return fmt.Errorf("default value of field %s : %v", key, err)
+		}
+		return nil
+	})
+}
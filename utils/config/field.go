@@ -0,0 +1,136 @@
+package configutil
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldFunc is called for each leaf field of a config struct,
+// key is the dotted path of the field, eg: "address.item"
+type fieldFunc func(key string, field reflect.StructField, value reflect.Value) error
+
+// fieldName get the name of a field used in keys, the json tag is preferred
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "yaml", "toml", "xml"} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if len(name) > 0 {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+// walk call fn for each leaf field of the struct v points to,
+// nested structs and slices of structs are walked recursively
+func walk(v interface{}, fn fieldFunc) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("config must be a non-nil pointer, got %T", v)
+	}
+	value = value.Elem()
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return walkStruct(value, "", fn)
+}
+
+func walkStruct(value reflect.Value, prefix string, fn fieldFunc) error {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			// unexported
+			continue
+		}
+		name := fieldName(field)
+		if len(name) == 0 {
+			continue
+		}
+		key := name
+		if len(prefix) > 0 {
+			key = prefix + "." + name
+		}
+
+		fieldValue := value.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			if err := walkStruct(fieldValue, key, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			for j := 0; j < fieldValue.Len(); j++ {
+				if err := walkStruct(fieldValue.Index(j), fmt.Sprintf("%s[%d]", key, j), fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := fn(key, field, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setValue parse the string and set it to the field,
+// slices of basic types are separated by commas
+func setValue(value reflect.Value, s string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		items := strings.Split(s, ",")
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package configutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const filePrefix = "file://"
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolate resolve a config value,
+// "${ENV_VAR}" is replaced by the environment variable,
+// "file:///path/to/secret" is replaced by the content of the file with trailing newlines trimmed.
+func interpolate(s string) (string, error) {
+	var err error
+	s = envPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := envPattern.FindStringSubmatch(match)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(s, filePrefix) {
+		data, err := ioutil.ReadFile(strings.TrimPrefix(s, filePrefix))
+		if err != nil {
+			return "", err
+		}
+		s = strings.TrimRight(string(data), "\r\n")
+	}
+	return s, nil
+}
+
+// interpolateFields interpolate all string fields of the config
+func interpolateFields(v interface{}) error {
+	return walk(v, func(key string, field reflect.StructField, value reflect.Value) error {
+		switch {
+		case value.Kind() == reflect.String:
+			s, err := interpolate(value.String())
+			if err != nil {
+				return fmt.Errorf("field %s : %v", key, err)
+			}
+			value.SetString(s)
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+			for i := 0; i < value.Len(); i++ {
+				s, err := interpolate(value.Index(i).String())
+				if err != nil {
+					return fmt.Errorf("field %s : %v", key, err)
+				}
+				value.Index(i).SetString(s)
+			}
+		}
+		return nil
+	})
+}
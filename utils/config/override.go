@@ -0,0 +1,70 @@
+package configutil
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// EnvPrefix prefix of the environment variables overriding module configs,
+// eg: BGO_MODULES_MYSQL_PASSWORD overrides the field "password" of module "modules.mysql"
+const EnvPrefix = "BGO"
+
+var (
+	// config path -> module name
+	bindings = make(map[string]string)
+	// module.field -> value, set by command line flags
+	overrides = make(map[string]string)
+)
+
+// Bind bind a config path to the module name, so the overrides of the module are applied when loading it
+func Bind(path, name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	bindings[path] = name
+}
+
+// SetOverride set an override in the form of "module.field=value", eg: "modules.mysql.password=xxx",
+// nested fields are separated by dots, eg: "modules.redis.address.item=127.0.0.1:6379,127.0.0.1:6380"
+func SetOverride(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || !strings.Contains(kv[0], ".") {
+		return fmt.Errorf("invalid override %q, expect module.field=value", s)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	overrides[strings.ToLower(kv[0])] = kv[1]
+	return nil
+}
+
+// envName get the name of the environment variable overriding the field of the module
+func envName(module, key string) string {
+	name := EnvPrefix + "_" + module + "_" + key
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// override apply the overrides of the module to the config, precedence: file < env < flag
+func override(path string, v interface{}) error {
+	lock.RLock()
+	defer lock.RUnlock()
+	module, ok := bindings[path]
+	if !ok {
+		return nil
+	}
+
+	return walk(v, func(key string, field reflect.StructField, value reflect.Value) error {
+		s, ok := os.LookupEnv(envName(module, key))
+		if flag, exist := overrides[strings.ToLower(module+"."+key)]; exist {
+			s, ok = flag, true
+		}
+		if !ok {
+			return nil
+		}
+		if err := setValue(value, s); err != nil {
+			return fmt.Errorf("override field %s of module %s : %v", key, module, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,147 @@
+package configutil
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactedValue the value replacing secret fields
+const RedactedValue = "******"
+
+// Redact return a deep copy of the config with all fields tagged `secret:"true"` redacted,
+// the secrets in the nested structs, pointers, slices and maps are redacted as well.
+// it should be used whenever a config is dumped, the config types marshal themselves by it in the logs.
+func Redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redact(reflect.ValueOf(v)).Interface()
+}
+
+// redact get a redacted copy of the value, the values without any struct are shared
+func redact(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		cp := reflect.New(value.Type().Elem())
+		cp.Elem().Set(redact(value.Elem()))
+		return cp
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		cp := reflect.New(value.Type()).Elem()
+		cp.Set(redact(value.Elem()))
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(value.Type()).Elem()
+		cp.Set(value)
+		redactStruct(cp)
+		return cp
+	case reflect.Slice:
+		if value.IsNil() || !hasStruct(value.Type().Elem()) {
+			return value
+		}
+		cp := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			cp.Index(i).Set(redact(value.Index(i)))
+		}
+		return cp
+	case reflect.Array:
+		if !hasStruct(value.Type().Elem()) {
+			return value
+		}
+		cp := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.Len(); i++ {
+			cp.Index(i).Set(redact(value.Index(i)))
+		}
+		return cp
+	case reflect.Map:
+		if value.IsNil() || !hasStruct(value.Type().Elem()) {
+			return value
+		}
+		cp := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(iter.Key(), redact(iter.Value()))
+		}
+		return cp
+	}
+	return value
+}
+
+// redactStruct redact the fields of an addressable struct in place
+func redactStruct(value reflect.Value) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if field.Tag.Get("secret") == "true" {
+			switch fieldValue.Kind() {
+			case reflect.String:
+				if fieldValue.Len() > 0 {
+					fieldValue.SetString(RedactedValue)
+				}
+			default:
+				fieldValue.Set(reflect.Zero(field.Type))
+			}
+			continue
+		}
+		fieldValue.Set(redact(fieldValue))
+	}
+}
+
+// hasStruct check whether the values of the type may contain a struct
+func hasStruct(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasStruct(t.Elem())
+	}
+	return false
+}
+
+// MarshalLogObject marshal the redacted config to a zap object, the config types implement
+// zapcore.ObjectMarshaler by it, so zap.Any and zap.Object never log their secrets:
+//
+//	func (c *Config) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+//		return configutil.MarshalLogObject(enc, c)
+//	}
+func MarshalLogObject(enc zapcore.ObjectEncoder, v interface{}) error {
+	value := reflect.ValueOf(Redact(v))
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return enc.AddReflected("value", value.Interface())
+	}
+
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(field.PkgPath) > 0 || field.Type == reflect.TypeOf(xml.Name{}) || name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if err := enc.AddReflected(name, value.Field(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
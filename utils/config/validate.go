@@ -0,0 +1,126 @@
+package configutil
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError the error of a config field
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string { return fmt.Sprintf("field %s : %v", e.Field, e.Err) }
+
+// ValidationError all field errors of a config file
+type ValidationError struct {
+	Path   string
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	errs := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		errs = append(errs, field.Error())
+	}
+	return fmt.Sprintf("invalid config %s : %s", e.Path, strings.Join(errs, "; "))
+}
+
+// Validate validate the config according to the `validate:"..."` tags, rules are separated by commas:
+//  required     : the field must not be zero
+//  min=N, max=N : the range of numbers, or the length of strings and slices
+//  oneof=a|b|c  : the field must be one of the values, empty values are skipped unless required
+func Validate(path string, v interface{}) error {
+	verr := &ValidationError{Path: path}
+	err := walk(v, func(key string, field reflect.StructField, value reflect.Value) error {
+		rules, ok := field.Tag.Lookup("validate")
+		if !ok {
+			return nil
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			if err := check(strings.TrimSpace(rule), value); err != nil {
+				verr.Fields = append(verr.Fields, &FieldError{Field: key, Err: err})
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func check(rule string, value reflect.Value) error {
+	name, arg := rule, ""
+	if index := strings.Index(rule, "="); index >= 0 {
+		name, arg = rule[:index], rule[index+1:]
+	}
+
+	switch name {
+	case "":
+		return nil
+	case "required":
+		if isZero(value) {
+			return fmt.Errorf("required")
+		}
+	case "min", "max":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid rule %q", rule)
+		}
+		n, ok := measure(value)
+		if !ok {
+			return fmt.Errorf("rule %q is not supported by type %s", rule, value.Type())
+		}
+		if name == "min" && n < bound {
+			return fmt.Errorf("%v is less than %s", value.Interface(), arg)
+		}
+		if name == "max" && n > bound {
+			return fmt.Errorf("%v is greater than %s", value.Interface(), arg)
+		}
+	case "oneof":
+		if isZero(value) {
+			return nil
+		}
+		s := fmt.Sprint(value.Interface())
+		for _, item := range strings.Split(arg, "|") {
+			if s == item {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, arg)
+	default:
+		return fmt.Errorf("unknown rule %q", rule)
+	}
+	return nil
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
+
+// measure get the number to compare with min & max
+func measure(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(value.Len()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,11 @@
+package netutil
+
+import "net"
+
+// IsNetTimeout check whether the error is a timeout of the network
+func IsNetTimeout(err error) bool {
+	if e, ok := err.(net.Error); ok {
+		return e.Timeout()
+	}
+	return false
+}
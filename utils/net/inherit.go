@@ -0,0 +1,222 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvListenFds the env passing the listeners to the child process in a graceful restart,
+// it's a comma separated list of "network://address", the fd of the i-th listener is 3+i.
+const EnvListenFds = "BGO_LISTEN_FDS"
+
+// EnvReadyFd the env passing the fd of a pipe to the child process in a graceful restart,
+// the child process notifies the parent by NotifyReady after it starts serving.
+const EnvReadyFd = "BGO_READY_FD"
+
+// the first fd of ExtraFiles in the child process
+const firstExtraFd = 3
+
+var errNotReady = errors.New("the child process is not ready")
+
+var (
+	inheritOnce sync.Once
+	inheritLock sync.Mutex
+	inherited   map[string]*os.File     // the listener files inherited from the parent process
+	listeners   map[string]net.Listener // the listeners created by Listen, to be passed to the child process
+)
+
+func listenerKey(network, address string) string { return network + "://" + address }
+
+// loadInherited parse the listeners inherited from the parent process
+func loadInherited() {
+	inherited = make(map[string]*os.File)
+	listeners = make(map[string]net.Listener)
+
+	env := os.Getenv(EnvListenFds)
+	if len(env) == 0 {
+		return
+	}
+	os.Unsetenv(EnvListenFds)
+	for i, key := range strings.Split(env, ",") {
+		inherited[key] = os.NewFile(uintptr(firstExtraFd+i), key)
+	}
+}
+
+// IsInherited check whether there is a listener on the network address inherited from the parent process,
+// eg: the unix socket file should not be removed before Listen if it's inherited
+func IsInherited(network, address string) bool {
+	inheritOnce.Do(loadInherited)
+	inheritLock.Lock()
+	defer inheritLock.Unlock()
+	_, ok := inherited[listenerKey(network, address)]
+	return ok
+}
+
+// Listen announce on the network address like net.Listen, but it resumes the listener
+// inherited from the parent process if any, and the listener can be passed to the child process by Restart.
+// all listeners of a server should be created by it, so they are served without interruption
+// in a graceful restart: the child process keeps serving them after the parent process closes its copies.
+func Listen(network, address string) (net.Listener, error) {
+	inheritOnce.Do(loadInherited)
+	key := listenerKey(network, address)
+
+	inheritLock.Lock()
+	defer inheritLock.Unlock()
+
+	var (
+		l   net.Listener
+		err error
+	)
+	if file, ok := inherited[key]; ok {
+		delete(inherited, key)
+		l, err = net.FileListener(file)
+		// FileListener dups the fd
+		file.Close()
+	} else {
+		l, err = net.Listen(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listeners[key] = l
+	return l, nil
+}
+
+// listenerFiles get the files of all listeners created by Listen, closed ones are skipped
+func listenerFiles() ([]string, []*os.File) {
+	inheritOnce.Do(loadInherited)
+	inheritLock.Lock()
+	defer inheritLock.Unlock()
+
+	var (
+		keys  []string
+		files []*os.File
+	)
+	for key, l := range listeners {
+		var (
+			file *os.File
+			err  error
+		)
+		switch listener := l.(type) {
+		case *net.TCPListener:
+			file, err = listener.File()
+		case *net.UnixListener:
+			// the socket file is used by the child process, so don't remove it on close
+			listener.SetUnlinkOnClose(false)
+			file, err = listener.File()
+		default:
+			continue
+		}
+		if err != nil {
+			// the listener has been closed
+			delete(listeners, key)
+			continue
+		}
+		keys = append(keys, key)
+		files = append(files, file)
+	}
+	return keys, files
+}
+
+// Restart fork-exec the current binary with the same arguments, and pass all listeners created by Listen
+// to the child process, which resumes them by Listen. It waits until the child process calls NotifyReady
+// within the readyTimeout(<= 0 means waiting forever), the child process is killed if it exits or times out
+// before that. The caller should stop accepting and drain its connections after a successful restart,
+// the pending connections are queued by the kernel until the child accepts them.
+func Restart(readyTimeout time.Duration) (*os.Process, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	keys, files := listenerFiles()
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, EnvListenFds+"=") && !strings.HasPrefix(kv, EnvReadyFd+"=") {
+			env = append(env, kv)
+		}
+	}
+	if len(keys) > 0 {
+		env = append(env, fmt.Sprintf("%s=%s", EnvListenFds, strings.Join(keys, ",")))
+	}
+
+	// the child process writes to the pipe when it's ready
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	env = append(env, fmt.Sprintf("%s=%d", EnvReadyFd, firstExtraFd+len(files)))
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(append([]*os.File{}, files...), writer)
+	err = cmd.Start()
+	// only the child process holds the writer, the reader gets EOF if the child exits before ready
+	writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitReady(reader, readyTimeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+func waitReady(reader *os.File, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		if _, err := reader.Read(b[:]); err != nil {
+			done <- errNotReady
+			return
+		}
+		done <- nil
+	}()
+
+	if timeout <= 0 {
+		return <-done
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("%v after %v", errNotReady, timeout)
+	}
+}
+
+// NotifyReady notify the parent process that the child process is serving after a graceful restart,
+// then the parent process stops serving. It does nothing if the process isn't started by Restart.
+func NotifyReady() error {
+	env := os.Getenv(EnvReadyFd)
+	if len(env) == 0 {
+		return nil
+	}
+	os.Unsetenv(EnvReadyFd)
+	fd, err := strconv.Atoi(env)
+	if err != nil {
+		return err
+	}
+	file := os.NewFile(uintptr(fd), "ready")
+	defer file.Close()
+	_, err = file.Write([]byte{1})
+	return err
+}
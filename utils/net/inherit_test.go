@@ -0,0 +1,104 @@
+package netutil
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+const envInheritHelper = "BGO_TEST_INHERIT_HELPER"
+
+// TestInheritHelper runs in the child process started by TestRestart
+func TestInheritHelper(t *testing.T) {
+	address := os.Getenv(envInheritHelper)
+	if address == "" {
+		t.Skip("only run in the child process")
+	}
+	if !IsInherited("tcp", address) {
+		t.Fatal("listener is not inherited")
+	}
+
+	l, err := Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := NotifyReady(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("child"))
+	conn.Close()
+}
+
+func TestRestart(t *testing.T) {
+	if os.Getenv(envInheritHelper) != "" {
+		t.Skip("already in the child process")
+	}
+
+	l, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the child process listens on the same address
+	address := l.Addr().String()
+	l.Close()
+	if l, err = Listen("tcp", address); err != nil {
+		t.Fatal(err)
+	}
+
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestInheritHelper$"}
+	os.Setenv(envInheritHelper, address)
+	process, err := Restart(10 * time.Second)
+	os.Args = args
+	os.Unsetenv(envInheritHelper)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stop accepting, the connection should be accepted by the child process
+	l.Close()
+	conn, err := net.DialTimeout("tcp", address, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	data, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "child" {
+		t.Errorf("got %q, want %q", data, "child")
+	}
+
+	state, err := process.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Success() {
+		t.Errorf("child process exit with %v", state)
+	}
+}
+
+func TestRestartNotReady(t *testing.T) {
+	if os.Getenv(envInheritHelper) != "" {
+		t.Skip("already in the child process")
+	}
+
+	// the child process exits without notifying
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^$"}
+	_, err := Restart(10 * time.Second)
+	os.Args = args
+	if err == nil {
+		t.Error("expect an error if the child process isn't ready")
+	}
+}
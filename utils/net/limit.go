@@ -0,0 +1,126 @@
+package netutil
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var errListenerClosed = errors.New("use of closed listener")
+
+// DynamicLimitListener a Listener that accepts at most n simultaneous connections
+// like LimitListener, but n can be changed at runtime. n <= 0 means no limitation.
+type DynamicLimitListener struct {
+	net.Listener
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	closed bool
+}
+
+// NewDynamicLimitListener create a DynamicLimitListener with the initial limitation
+func NewDynamicLimitListener(l net.Listener, n int) *DynamicLimitListener {
+	dl := &DynamicLimitListener{Listener: l, limit: n}
+	dl.cond = sync.NewCond(&dl.lock)
+	return dl
+}
+
+// SetLimit change the maximum number of simultaneous connections
+func (l *DynamicLimitListener) SetLimit(n int) {
+	l.lock.Lock()
+	l.limit = n
+	l.lock.Unlock()
+	l.cond.Broadcast()
+}
+
+// GetLimit get the maximum number of simultaneous connections
+func (l *DynamicLimitListener) GetLimit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.limit
+}
+
+// GetActive get the number of active connections
+func (l *DynamicLimitListener) GetActive() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.active
+}
+
+// acquire wait until a connection is allowed, returns false if the listener is closed
+func (l *DynamicLimitListener) acquire() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for !l.closed && l.limit > 0 && l.active >= l.limit {
+		l.cond.Wait()
+	}
+	if l.closed {
+		return false
+	}
+	l.active++
+	return true
+}
+
+func (l *DynamicLimitListener) release() {
+	l.lock.Lock()
+	l.active--
+	l.lock.Unlock()
+	// Drain may be waiting as well
+	l.cond.Broadcast()
+}
+
+// Drain wait until all active connections are closed, returns false on timeout.
+// timeout <= 0 means waiting forever.
+func (l *DynamicLimitListener) Drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		l.lock.Lock()
+		for l.active > 0 {
+			l.cond.Wait()
+		}
+		l.lock.Unlock()
+		close(done)
+	}()
+
+	if timeout <= 0 {
+		<-done
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (l *DynamicLimitListener) Accept() (net.Conn, error) {
+	acquired := l.acquire()
+	// If the semaphore isn't acquired because the listener was closed, expect
+	// that this call to accept won't block, but immediately return an error.
+	c, err := l.Listener.Accept()
+	if err != nil {
+		if acquired {
+			l.release()
+		}
+		return nil, err
+	}
+	// the listener is closed while accepting, the conn can't be counted
+	if !acquired {
+		c.Close()
+		return nil, errListenerClosed
+	}
+	return &limitListenerConn{Conn: c, release: l.release}, nil
+}
+
+func (l *DynamicLimitListener) Close() error {
+	err := l.Listener.Close()
+	l.lock.Lock()
+	l.closed = true
+	l.lock.Unlock()
+	l.cond.Broadcast()
+	return err
+}
@@ -0,0 +1,45 @@
+package netutil
+
+import (
+	"net"
+	"testing"
+)
+
+// pipeListener accepts a pipe conn even after closed
+type pipeListener struct {
+	net.Listener
+	peer net.Conn
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	c, peer := net.Pipe()
+	l.peer = peer
+	return c, nil
+}
+
+func (l *pipeListener) Close() error { return nil }
+
+func TestDynamicLimitListenerClosed(t *testing.T) {
+	pl := &pipeListener{}
+	l := NewDynamicLimitListener(pl, 1)
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	c.Close()
+	if n := l.GetActive(); n != 0 {
+		t.Fatalf("active = %d", n)
+	}
+
+	l.Close()
+	if c, err := l.Accept(); err == nil || c != nil {
+		t.Fatal("expect an error after closed")
+	}
+	if n := l.GetActive(); n != 0 {
+		t.Errorf("active = %d", n)
+	}
+	if _, err := pl.peer.Write([]byte{0}); err == nil {
+		t.Error("expect the conn accepted after closed to be closed")
+	}
+}